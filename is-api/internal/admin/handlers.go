@@ -0,0 +1,412 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"instashorts-be/is-api/internal/auth"
+	"instashorts-be/is-api/internal/video"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// FailedTaskInspector lists, requeues and deletes failed tasks in asynq
+type FailedTaskInspector interface {
+	QueueFailures() ([]queue.QueueFailures, error)
+	ListFailedTasks(qname string, state queue.FailedState, page, pageSize int) ([]queue.FailedTask, error)
+	GetFailedTask(qname, id string) (queue.FailedTask, error)
+	RequeueTask(qname, id string) error
+	DeleteTask(qname, id string) error
+	DeleteTasks(qname string, ids []string) (int, error)
+	DeleteAll(qname string, state queue.FailedState) (int, error)
+}
+
+// VideoLookup finds the videos failed tasks belong to
+type VideoLookup interface {
+	GetVideosByIDs(ctx context.Context, ids []int) ([]video.Video, error)
+	GetSceneVideoIDs(ctx context.Context, sceneIDs []int) (map[int]int, error)
+}
+
+// VideoRetrier resumes failed videos from their first missing step
+type VideoRetrier interface {
+	RetryVideo(ctx context.Context, id int, reason string) (*video.RetryPlan, error)
+}
+
+type Handler struct {
+	tasks   FailedTaskInspector
+	videos  VideoLookup
+	retrier VideoRetrier
+}
+
+func NewHandler(tasks FailedTaskInspector, videos VideoLookup, retrier VideoRetrier) *Handler {
+	return &Handler{
+		tasks:   tasks,
+		videos:  videos,
+		retrier: retrier,
+	}
+}
+
+// ListQueues counts the archived and retrying tasks of every queue
+func (h *Handler) ListQueues(c *gin.Context) {
+	failures, err := h.tasks.QueueFailures()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to inspect queues"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"queues": failures})
+}
+
+// ListFailedTasks lists one page of the archived or retrying tasks of a queue,
+// each with the video it belongs to
+func (h *Handler) ListFailedTasks(c *gin.Context) {
+	qname := c.Param("queue")
+	state, err := queue.ParseFailedState(c.DefaultQuery("state", string(queue.FailedStateArchived)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := positiveQuery(c, "page", 1)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	pageSize, err := positiveQuery(c, "page_size", defaultPageSize)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	tasks, err := h.tasks.ListFailedTasks(qname, state, page, pageSize)
+	if err != nil {
+		h.taskError(c, err, "Failed to list tasks")
+		return
+	}
+
+	responses, err := h.withVideos(c.Request.Context(), tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up task videos"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"queue":     qname,
+		"state":     state,
+		"page":      page,
+		"page_size": pageSize,
+		"tasks":     responses,
+	})
+}
+
+// RequeueTask moves one failed task back to pending. The task of a failed
+// video resumes the video through its retry instead.
+func (h *Handler) RequeueTask(c *gin.Context) {
+	qname, id := c.Param("queue"), c.Param("id")
+	task, err := h.tasks.GetFailedTask(qname, id)
+	if err != nil {
+		h.taskError(c, err, "Failed to requeue task")
+		return
+	}
+
+	result, err := h.requeue(c, qname, []queue.FailedTask{task})
+	if err != nil {
+		if errors.Is(err, video.ErrNothingToRetry) || errors.Is(err, repository.ErrStatusChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.taskError(c, err, "Failed to requeue task")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteTask deletes one failed task
+func (h *Handler) DeleteTask(c *gin.Context) {
+	qname, id := c.Param("queue"), c.Param("id")
+	if err := h.tasks.DeleteTask(qname, id); err != nil {
+		h.taskError(c, err, "Failed to delete task")
+		return
+	}
+
+	logAction(c, "deleted task %s in queue %s", id, qname)
+	c.JSON(http.StatusOK, gin.H{"deleted": 1})
+}
+
+// RequeueTasks requeues the listed tasks of a queue, or all of its tasks in a
+// state. Like RequeueTask, the tasks of failed videos resume their videos.
+func (h *Handler) RequeueTasks(c *gin.Context) {
+	qname := c.Param("queue")
+	selection, ok := parseBatch(c)
+	if !ok {
+		return
+	}
+
+	var tasks []queue.FailedTask
+	var err error
+	if selection.all {
+		tasks, err = h.allFailedTasks(qname, selection.state)
+	} else {
+		tasks, err = h.failedTasks(qname, selection.ids)
+	}
+	if err != nil {
+		h.taskError(c, err, "Failed to look up tasks")
+		return
+	}
+
+	result, err := h.requeue(c, qname, tasks)
+	if err != nil {
+		// Part of a batch may have gone through; report how much did
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":          err.Error(),
+			"requeued":       result.Requeued,
+			"retried_videos": result.RetriedVideos,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DeleteTasks deletes the listed tasks of a queue, or all of its tasks in a state
+func (h *Handler) DeleteTasks(c *gin.Context) {
+	qname := c.Param("queue")
+	selection, ok := parseBatch(c)
+	if !ok {
+		return
+	}
+
+	var n int
+	var err error
+	if selection.all {
+		n, err = h.tasks.DeleteAll(qname, selection.state)
+		if err == nil {
+			logAction(c, "deleted all %d %s tasks in queue %s", n, selection.state, qname)
+		}
+	} else {
+		n, err = h.tasks.DeleteTasks(qname, selection.ids)
+		logAction(c, "deleted %d of %d tasks in queue %s", n, len(selection.ids), qname)
+	}
+
+	if err != nil {
+		// Part of a batch may have gone through; report how much did
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, asynq.ErrQueueNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error(), "deleted": n})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deleted": n})
+}
+
+// batchSelection is what a batch request selects: the tasks with ids, or with
+// all every task in state
+type batchSelection struct {
+	ids   []string
+	all   bool
+	state queue.FailedState
+}
+
+// parseBatch reads a batch request, responding with 400 when it is invalid
+func parseBatch(c *gin.Context) (batchSelection, bool) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return batchSelection{}, false
+	}
+
+	switch {
+	case req.All && len(req.TaskIDs) > 0:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either task_ids or all, not both"})
+		return batchSelection{}, false
+	case req.All:
+		state, err := queue.ParseFailedState(req.State)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return batchSelection{}, false
+		}
+		return batchSelection{all: true, state: state}, true
+	case len(req.TaskIDs) > 0:
+		return batchSelection{ids: req.TaskIDs}, true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Set task_ids or all"})
+	return batchSelection{}, false
+}
+
+// failedTasks looks up the tasks with ids in qname, skipping the ones that
+// are gone or no longer failed
+func (h *Handler) failedTasks(qname string, ids []string) ([]queue.FailedTask, error) {
+	tasks := make([]queue.FailedTask, 0, len(ids))
+	for _, id := range ids {
+		task, err := h.tasks.GetFailedTask(qname, id)
+		if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, queue.ErrTaskNotFailed) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+// allFailedTasks lists every task of qname in state, a page at a time
+func (h *Handler) allFailedTasks(qname string, state queue.FailedState) ([]queue.FailedTask, error) {
+	var tasks []queue.FailedTask
+	for page := 1; ; page++ {
+		batch, err := h.tasks.ListFailedTasks(qname, state, page, maxPageSize)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, batch...)
+		if len(batch) < maxPageSize {
+			return tasks, nil
+		}
+	}
+}
+
+// requeue runs tasks again. A failed video already gave up on its tasks, so
+// running one would pay for work nothing waits on; the video is resumed
+// through its retry instead, once however many of its tasks were selected.
+func (h *Handler) requeue(c *gin.Context, qname string, tasks []queue.FailedTask) (RequeueResponse, error) {
+	result := RequeueResponse{}
+	responses, err := h.withVideos(c.Request.Context(), tasks)
+	if err != nil {
+		return result, err
+	}
+
+	retried := map[int]bool{}
+	var errs []error
+	for _, task := range responses {
+		if task.Video == nil || task.Video.Status != video.VideoStatusFailed {
+			if err := h.tasks.RequeueTask(qname, task.ID); err != nil {
+				if !errors.Is(err, asynq.ErrTaskNotFound) {
+					errs = append(errs, err)
+				}
+				continue
+			}
+			logAction(c, "requeued task %s in queue %s", task.ID, qname)
+			result.Requeued++
+			continue
+		}
+
+		if retried[task.Video.ID] {
+			continue
+		}
+		plan, err := h.retrier.RetryVideo(c.Request.Context(), task.Video.ID, "retried by an admin")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to retry video %d of task %s: %w", task.Video.ID, task.ID, err))
+			continue
+		}
+		retried[task.Video.ID] = true
+		logAction(c, "retried failed video %d from task %s in queue %s (%s)", task.Video.ID, task.ID, qname, plan.Status)
+		result.RetriedVideos = append(result.RetriedVideos, task.Video.ID)
+	}
+	return result, errors.Join(errs...)
+}
+
+// withVideos resolves the video of each task. Scene image tasks only carry a
+// scene ID, so their video is looked up through the scene.
+func (h *Handler) withVideos(ctx context.Context, tasks []queue.FailedTask) ([]FailedTaskResponse, error) {
+	var sceneIDs []int
+	for _, task := range tasks {
+		if task.VideoID == nil && task.SceneID != nil {
+			sceneIDs = append(sceneIDs, *task.SceneID)
+		}
+	}
+	if len(sceneIDs) > 0 {
+		sceneVideos, err := h.videos.GetSceneVideoIDs(ctx, sceneIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up scenes: %w", err)
+		}
+		for i, task := range tasks {
+			if task.VideoID != nil || task.SceneID == nil {
+				continue
+			}
+			if videoID, ok := sceneVideos[*task.SceneID]; ok {
+				tasks[i].VideoID = &videoID
+			}
+		}
+	}
+
+	var videoIDs []int
+	for _, task := range tasks {
+		if task.VideoID != nil {
+			videoIDs = append(videoIDs, *task.VideoID)
+		}
+	}
+	videos := map[int]*TaskVideo{}
+	if len(videoIDs) > 0 {
+		found, err := h.videos.GetVideosByIDs(ctx, videoIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up videos: %w", err)
+		}
+		for i := range found {
+			videos[found[i].ID] = newTaskVideo(&found[i])
+		}
+	}
+
+	responses := make([]FailedTaskResponse, 0, len(tasks))
+	for _, task := range tasks {
+		response := FailedTaskResponse{FailedTask: task}
+		if task.VideoID != nil {
+			response.Video = videos[*task.VideoID]
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// taskError responds to an inspector error, with 404 for unknown queues and
+// tasks and 409 for tasks that haven't failed
+func (h *Handler) taskError(c *gin.Context, err error, message string) {
+	if errors.Is(err, asynq.ErrQueueNotFound) || errors.Is(err, asynq.ErrTaskNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, queue.ErrTaskNotFailed) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	log.Printf("ERROR: %s: %v", message, err)
+	c.JSON(http.StatusServiceUnavailable, gin.H{"error": message})
+}
+
+// logAction records who changed which tasks, since requeues start paid work again
+func logAction(c *gin.Context, format string, args ...interface{}) {
+	admin := "unknown"
+	if user, exists := auth.GetUserFromContext(c); exists {
+		admin = user.Email
+	}
+	log.Printf("Admin %s %s", admin, fmt.Sprintf(format, args...))
+}
+
+// positiveQuery reads a positive integer query parameter, or fallback when it's missing
+func positiveQuery(c *gin.Context, name string, fallback int) (int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %s: %q", name, value)
+	}
+	return n, nil
+}
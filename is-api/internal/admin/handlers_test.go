@@ -0,0 +1,255 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"instashorts-be/is-api/internal/video"
+	"instashorts-be/pkg/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+)
+
+// fakeInspector serves tasks and records what the handlers asked it to do
+type fakeInspector struct {
+	tasks     []queue.FailedTask
+	requeued  []string
+	deleted   []string
+	allStates []queue.FailedState
+}
+
+func (f *fakeInspector) QueueFailures() ([]queue.QueueFailures, error) {
+	return []queue.QueueFailures{{Queue: queue.QueueDefault, Archived: len(f.tasks)}}, nil
+}
+
+func (f *fakeInspector) ListFailedTasks(qname string, state queue.FailedState, page, pageSize int) ([]queue.FailedTask, error) {
+	if qname != queue.QueueDefault {
+		return nil, fmt.Errorf("failed to list tasks: %w", asynq.ErrQueueNotFound)
+	}
+	return f.tasks, nil
+}
+
+func (f *fakeInspector) GetFailedTask(qname, id string) (queue.FailedTask, error) {
+	if id == "missing" {
+		return queue.FailedTask{}, fmt.Errorf("failed to get task %s: %w", id, asynq.ErrTaskNotFound)
+	}
+	if id == "pending" {
+		return queue.FailedTask{}, fmt.Errorf("task %s is pending: %w", id, queue.ErrTaskNotFailed)
+	}
+	for _, task := range f.tasks {
+		if task.ID == id {
+			return task, nil
+		}
+	}
+	return queue.FailedTask{ID: id, Queue: qname, State: queue.FailedStateArchived}, nil
+}
+
+func (f *fakeInspector) RequeueTask(qname, id string) error {
+	f.requeued = append(f.requeued, id)
+	return nil
+}
+
+func (f *fakeInspector) DeleteTask(qname, id string) error {
+	f.deleted = append(f.deleted, id)
+	return nil
+}
+
+func (f *fakeInspector) DeleteTasks(qname string, ids []string) (int, error) {
+	f.deleted = append(f.deleted, ids...)
+	return len(ids), nil
+}
+
+func (f *fakeInspector) DeleteAll(qname string, state queue.FailedState) (int, error) {
+	f.allStates = append(f.allStates, state)
+	return 3, nil
+}
+
+// fakeVideos knows video 42, whose scene 7 has an archived image task, and
+// the failed video 43
+type fakeVideos struct{}
+
+func (fakeVideos) GetVideosByIDs(ctx context.Context, ids []int) ([]video.Video, error) {
+	var videos []video.Video
+	for _, id := range ids {
+		if id == 42 {
+			videos = append(videos, video.Video{ID: 42, UserID: 3, Status: video.VideoStatusGeneratingImages})
+		}
+		if id == 43 {
+			videos = append(videos, video.Video{ID: 43, UserID: 3, Status: video.VideoStatusFailed})
+		}
+	}
+	return videos, nil
+}
+
+func (fakeVideos) GetSceneVideoIDs(ctx context.Context, sceneIDs []int) (map[int]int, error) {
+	return map[int]int{7: 42}, nil
+}
+
+// fakeRetrier records the videos it was asked to retry
+type fakeRetrier struct {
+	retried []int
+}
+
+func (f *fakeRetrier) RetryVideo(ctx context.Context, id int, reason string) (*video.RetryPlan, error) {
+	f.retried = append(f.retried, id)
+	return &video.RetryPlan{Status: video.VideoStatusReadyToRender, TaskTypes: []string{queue.TypeRenderVideo}}, nil
+}
+
+func newTestRouter(inspector *fakeInspector) *gin.Engine {
+	return newTestRouterWithRetrier(inspector, &fakeRetrier{})
+}
+
+func newTestRouterWithRetrier(inspector *fakeInspector, retrier *fakeRetrier) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	registerTaskRoutes(r.Group("/api/admin"), NewHandler(inspector, fakeVideos{}, retrier))
+	return r
+}
+
+func serve(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	return rr
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func TestListFailedTasksLinksVideos(t *testing.T) {
+	inspector := &fakeInspector{tasks: []queue.FailedTask{
+		{ID: "image", Type: queue.TypeGenerateSceneImage, SceneID: intPtr(7), LastErr: "quota exceeded"},
+		{ID: "render", Type: queue.TypeRenderVideo, VideoID: intPtr(42)},
+		{ID: "gone", Type: queue.TypeGenerateAudio, VideoID: intPtr(99)},
+	}}
+	rr := serve(newTestRouter(inspector), http.MethodGet, "/api/admin/queues/default/tasks?state=archived", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+
+	var body struct {
+		Tasks []FailedTaskResponse `json:"tasks"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(body.Tasks) != 3 {
+		t.Fatalf("Expected 3 tasks, got %d", len(body.Tasks))
+	}
+	image := body.Tasks[0]
+	if image.VideoID == nil || *image.VideoID != 42 || image.Video == nil || image.Video.Status != video.VideoStatusGeneratingImages {
+		t.Errorf("Expected the image task to link to video 42 through its scene, got %+v", image)
+	}
+	if body.Tasks[1].Video == nil || body.Tasks[1].Video.UserID != 3 {
+		t.Errorf("Expected the render task to link to video 42, got %+v", body.Tasks[1])
+	}
+	if body.Tasks[2].Video != nil {
+		t.Errorf("Expected no video for a task of an unknown video, got %+v", body.Tasks[2].Video)
+	}
+}
+
+func TestListFailedTasksValidation(t *testing.T) {
+	r := newTestRouter(&fakeInspector{})
+	tests := []struct {
+		path     string
+		expected int
+	}{
+		{"/api/admin/queues/default/tasks?state=pending", http.StatusBadRequest},
+		{"/api/admin/queues/default/tasks?page=0", http.StatusBadRequest},
+		{"/api/admin/queues/unknown/tasks", http.StatusNotFound},
+		{"/api/admin/queues/default/tasks?state=retry&page=2&page_size=500", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if rr := serve(r, http.MethodGet, tt.path, ""); rr.Code != tt.expected {
+			t.Errorf("GET %s: expected %d, got %d: %s", tt.path, tt.expected, rr.Code, rr.Body)
+		}
+	}
+}
+
+func TestRequeueAndDeleteTask(t *testing.T) {
+	inspector := &fakeInspector{}
+	r := newTestRouter(inspector)
+
+	if rr := serve(r, http.MethodPost, "/api/admin/queues/default/tasks/abc/requeue", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr := serve(r, http.MethodPost, "/api/admin/queues/default/tasks/missing/requeue", ""); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown task, got %d", rr.Code)
+	}
+	if rr := serve(r, http.MethodPost, "/api/admin/queues/default/tasks/pending/requeue", ""); rr.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a task that hasn't failed, got %d", rr.Code)
+	}
+	if rr := serve(r, http.MethodDelete, "/api/admin/queues/default/tasks/def", ""); rr.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if len(inspector.requeued) != 1 || inspector.requeued[0] != "abc" || len(inspector.deleted) != 1 || inspector.deleted[0] != "def" {
+		t.Errorf("Unexpected calls: requeued=%v deleted=%v", inspector.requeued, inspector.deleted)
+	}
+}
+
+func TestRequeueTaskOfFailedVideo(t *testing.T) {
+	inspector := &fakeInspector{tasks: []queue.FailedTask{
+		{ID: "render", Type: queue.TypeRenderVideo, VideoID: intPtr(43)},
+		{ID: "image", Type: queue.TypeGenerateSceneImage, SceneID: intPtr(7)},
+	}}
+	retrier := &fakeRetrier{}
+	r := newTestRouterWithRetrier(inspector, retrier)
+
+	// The video gave up on the task, so running it alone would pay for a render
+	// nothing moves on from; the video is retried instead
+	rr := serve(r, http.MethodPost, "/api/admin/queues/default/tasks/render/requeue", "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rr.Code, rr.Body)
+	}
+	if rr.Body.String() != `{"requeued":0,"retried_videos":[43]}` {
+		t.Errorf("Expected the video to be reported as retried, got %s", rr.Body)
+	}
+	if len(inspector.requeued) != 0 || len(retrier.retried) != 1 || retrier.retried[0] != 43 {
+		t.Errorf("Unexpected calls: requeued=%v retried=%v", inspector.requeued, retrier.retried)
+	}
+
+	// A batch runs the tasks of in-flight videos and retries each failed video once
+	retrier.retried = nil
+	rr = serve(r, http.MethodPost, "/api/admin/queues/default/tasks/requeue", `{"task_ids":["render","image","render"]}`)
+	if rr.Code != http.StatusOK || rr.Body.String() != `{"requeued":1,"retried_videos":[43]}` {
+		t.Errorf("Expected one task requeued and one video retried, got %d: %s", rr.Code, rr.Body)
+	}
+	if len(inspector.requeued) != 1 || inspector.requeued[0] != "image" || len(retrier.retried) != 1 {
+		t.Errorf("Unexpected calls: requeued=%v retried=%v", inspector.requeued, retrier.retried)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected int
+		response string
+	}{
+		{name: "requeue by ID", path: "requeue", body: `{"task_ids":["a","b"]}`, expected: http.StatusOK, response: `{"requeued":2}`},
+		{name: "delete all archived", path: "delete", body: `{"all":true,"state":"archived"}`, expected: http.StatusOK, response: `{"deleted":3}`},
+		{name: "all without a state", path: "requeue", body: `{"all":true}`, expected: http.StatusBadRequest},
+		{name: "both IDs and all", path: "delete", body: `{"all":true,"state":"retry","task_ids":["a"]}`, expected: http.StatusBadRequest},
+		{name: "nothing selected", path: "requeue", body: `{}`, expected: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := serve(newTestRouter(&fakeInspector{}), http.MethodPost, "/api/admin/queues/default/tasks/"+tt.path, tt.body)
+			if rr.Code != tt.expected {
+				t.Fatalf("Expected %d, got %d: %s", tt.expected, rr.Code, rr.Body)
+			}
+			if tt.response != "" && rr.Body.String() != tt.response {
+				t.Errorf("Expected %s, got %s", tt.response, rr.Body)
+			}
+		})
+	}
+}
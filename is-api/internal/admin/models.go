@@ -0,0 +1,49 @@
+package admin
+
+import (
+	"instashorts-be/is-api/internal/video"
+	"instashorts-be/pkg/queue"
+)
+
+// BatchRequest selects the failed tasks of a queue to requeue or delete:
+// the tasks in TaskIDs, or with All every task in State
+type BatchRequest struct {
+	TaskIDs []string `json:"task_ids"`
+	All     bool     `json:"all"`
+	State   string   `json:"state"`
+}
+
+// RequeueResponse reports a requeue. The tasks of failed videos aren't run;
+// their videos are retried from the first missing step instead.
+type RequeueResponse struct {
+	Requeued      int   `json:"requeued"`
+	RetriedVideos []int `json:"retried_videos,omitempty"`
+}
+
+// TaskVideo is the video a failed task works on
+type TaskVideo struct {
+	ID            int               `json:"id"`
+	UserID        int               `json:"user_id"`
+	Title         *string           `json:"title,omitempty"`
+	Status        video.VideoStatus `json:"status"`
+	FailureReason *string           `json:"failure_reason,omitempty"`
+	Deleted       bool              `json:"deleted"`
+}
+
+func newTaskVideo(v *video.Video) *TaskVideo {
+	return &TaskVideo{
+		ID:            v.ID,
+		UserID:        v.UserID,
+		Title:         v.Title,
+		Status:        v.Status,
+		FailureReason: v.FailureReason,
+		Deleted:       v.DeletedAt.Valid,
+	}
+}
+
+// FailedTaskResponse is a failed task with the video it belongs to, when it
+// still exists
+type FailedTaskResponse struct {
+	queue.FailedTask
+	Video *TaskVideo `json:"video,omitempty"`
+}
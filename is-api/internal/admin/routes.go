@@ -0,0 +1,27 @@
+package admin
+
+import (
+	"instashorts-be/is-api/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes registers the admin routes, open to the users in adminEmails
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler, authRepo *auth.Repository, adminEmails []string) {
+	admin := router.Group("/admin")
+	admin.Use(auth.RequireAuth(authRepo), auth.RequireAdmin(adminEmails))
+	registerTaskRoutes(admin, handler)
+}
+
+// registerTaskRoutes registers the dead-letter routes for failed tasks
+func registerTaskRoutes(router *gin.RouterGroup, handler *Handler) {
+	queues := router.Group("/queues")
+	{
+		queues.GET("", handler.ListQueues)
+		queues.GET("/:queue/tasks", handler.ListFailedTasks)
+		queues.POST("/:queue/tasks/requeue", handler.RequeueTasks)
+		queues.POST("/:queue/tasks/delete", handler.DeleteTasks)
+		queues.POST("/:queue/tasks/:id/requeue", handler.RequeueTask)
+		queues.DELETE("/:queue/tasks/:id", handler.DeleteTask)
+	}
+}
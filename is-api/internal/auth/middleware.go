@@ -2,6 +2,7 @@ package auth
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -51,6 +52,41 @@ func RequireAuth(repo *Repository) gin.HandlerFunc {
 	}
 }
 
+// ParseAdminEmails reads the comma-separated ADMIN_EMAILS value
+func ParseAdminEmails(value string) []string {
+	var emails []string
+	for _, email := range strings.Split(value, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// RequireAdmin is a middleware that only lets through users whose email is in
+// adminEmails. It must run after RequireAuth; with no admins, nobody gets in.
+func RequireAdmin(adminEmails []string) gin.HandlerFunc {
+	admins := make(map[string]bool, len(adminEmails))
+	for _, email := range adminEmails {
+		admins[strings.ToLower(email)] = true
+	}
+	return func(c *gin.Context) {
+		user, exists := GetUserFromContext(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+			c.Abort()
+			return
+		}
+		if !admins[strings.ToLower(user.Email)] {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // GetUserFromContext retrieves the authenticated user from the context
 func GetUserFromContext(c *gin.Context) (*User, bool) {
 	user, exists := c.Get("user")
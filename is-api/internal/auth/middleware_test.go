@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseAdminEmails(t *testing.T) {
+	got := ParseAdminEmails(" Ops@Example.com, ,oncall@example.com,")
+	if !reflect.DeepEqual(got, []string{"ops@example.com", "oncall@example.com"}) {
+		t.Errorf("Unexpected admin emails: %v", got)
+	}
+	if got := ParseAdminEmails(""); len(got) != 0 {
+		t.Errorf("Expected no admins, got %v", got)
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []struct {
+		name     string
+		user     *User
+		expected int
+	}{
+		{name: "admin", user: &User{Email: "OPS@example.com"}, expected: http.StatusOK},
+		{name: "other user", user: &User{Email: "someone@example.com"}, expected: http.StatusForbidden},
+		{name: "not authenticated", expected: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.user != nil {
+					c.Set("user", tt.user)
+				}
+			})
+			r.GET("/", RequireAdmin([]string{"ops@example.com"}), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rr.Code != tt.expected {
+				t.Errorf("Expected %d, got %d", tt.expected, rr.Code)
+			}
+		})
+	}
+}
@@ -3,8 +3,10 @@ package server
 import (
 	"net/http"
 
+	"instashorts-be/is-api/internal/admin"
 	"instashorts-be/is-api/internal/auth"
 	"instashorts-be/is-api/internal/video"
+	"instashorts-be/is-api/internal/voice"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
@@ -30,6 +32,12 @@ func (s *Server) RegisterRoutes() http.Handler {
 	// Register video routes
 	video.RegisterRoutes(api, s.videoHandler, s.authRepo)
 
+	// Register voice routes
+	voice.RegisterRoutes(api, s.voiceHandler, s.authRepo)
+
+	// Register admin routes
+	admin.RegisterRoutes(api, s.adminHandler, s.authRepo, s.adminEmails)
+
 	return r
 }
 
@@ -1,7 +1,9 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -9,10 +11,14 @@ import (
 
 	_ "github.com/joho/godotenv/autoload"
 
+	"instashorts-be/is-api/internal/admin"
 	"instashorts-be/is-api/internal/auth"
 	"instashorts-be/pkg/database"
+	"instashorts-be/pkg/events"
 	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/storage"
 	"instashorts-be/is-api/internal/video"
+	"instashorts-be/is-api/internal/voice"
 )
 
 type Server struct {
@@ -20,10 +26,13 @@ type Server struct {
 
 	db           database.Service
 	queueClient  *queue.Client
+	adminHandler *admin.Handler
+	adminEmails  []string
 	authHandler  *auth.Handler
 	authRepo     *auth.Repository
 	videoHandler *video.Handler
 	videoRepo    *video.Repository
+	voiceHandler *voice.Handler
 }
 
 func NewServer() *http.Server {
@@ -43,21 +52,52 @@ func NewServer() *http.Server {
 	oauthService := auth.NewOAuthService(oauthConfig, authRepo)
 	authHandler := auth.NewHandler(oauthConfig, oauthService, authRepo)
 
-	// Initialize queue client
+	// Initialize queue client, and the inspector that removes a cancelled video's
+	// tasks and backs the admin dead-letter routes
 	queueClient := queue.NewClient()
+	queueInspector := queue.NewInspector()
 
-	// Initialize video module with GORM DB
-	videoRepo := video.NewRepository(db.GetDB())
-	videoHandler := video.NewHandler(videoRepo, queueClient)
+	// Initialize voice module with GORM DB
+	voiceRepo := voice.NewRepository(db.GetDB())
+	voiceHandler := voice.NewHandler(voiceRepo)
+	voiceCatalog := voice.NewCatalog(voiceRepo, voice.NewStaticCatalogFromEnv())
+
+	// Initialize the blob store the worker writes media to; video responses
+	// carry URLs signed from it for SIGNED_URL_TTL
+	store, err := storage.NewBlobStore(context.Background(), getEnvOrDefault("STORAGE_BACKEND", storage.DefaultBackend))
+	if err != nil {
+		log.Fatalf("could not create storage backend: %v", err)
+	}
+	signedURLTTL, err := storage.SignedURLTTLFromEnv()
+	if err != nil {
+		log.Fatalf("could not read signed URL TTL: %v", err)
+	}
+
+	// Initialize video module with GORM DB. Status changes are published over
+	// Redis, where the worker publishes its own; the hub fans them out to the
+	// open event streams with the progress of their video.
+	redisAddr := fmt.Sprintf("%s:%s", getEnvOrDefault("REDIS_HOST", "localhost"), getEnvOrDefault("REDIS_PORT", "6379"))
+	videoRepo := video.NewRepository(db.GetDB(), events.NewRedisPublisher(redisAddr))
+	hub := video.NewHub(videoRepo)
+	go listenForEvents(redisAddr, hub)
+	mediaSigner := storage.NewSigner(store, signedURLTTL)
+	videoHandler := video.NewHandler(videoRepo, queueClient, queueInspector, voiceCatalog, mediaSigner, hub)
+
+	// Initialize admin module; only the users in ADMIN_EMAILS can reach it.
+	// Requeueing a failed video's task retries the video the way its user would.
+	adminHandler := admin.NewHandler(queueInspector, videoRepo, video.NewRetrier(videoRepo, queueInspector, mediaSigner))
 
 	NewServer := &Server{
 		port:         port,
 		db:           db,
 		queueClient:  queueClient,
+		adminHandler: adminHandler,
+		adminEmails:  auth.ParseAdminEmails(os.Getenv("ADMIN_EMAILS")),
 		authHandler:  authHandler,
 		authRepo:     authRepo,
 		videoHandler: videoHandler,
 		videoRepo:    videoRepo,
+		voiceHandler: voiceHandler,
 	}
 
 	// Declare Server config
@@ -71,3 +111,24 @@ func NewServer() *http.Server {
 
 	return server
 }
+
+// eventListenerRetryDelay is how long the event listener waits before
+// subscribing again after losing Redis
+const eventListenerRetryDelay = 5 * time.Second
+
+// listenForEvents hands every published video event to hub for as long as
+// the server runs
+func listenForEvents(redisAddr string, hub *video.Hub) {
+	for {
+		err := events.Listen(context.Background(), redisAddr, hub.Publish)
+		log.Printf("ERROR: Video event listener stopped, retrying in %s: %v", eventListenerRetryDelay, err)
+		time.Sleep(eventListenerRetryDelay)
+	}
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
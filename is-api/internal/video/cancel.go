@@ -0,0 +1,25 @@
+package video
+
+import "instashorts-be/pkg/models"
+
+// TaskCanceller removes the queued tasks of a video so no more work is paid for
+type TaskCanceller interface {
+	CancelVideoTasks(videoID int, sceneIDs []int) (int, error)
+	// DeleteArchivedVideoTasks frees the task IDs of a failed video's dead tasks for its retry
+	DeleteArchivedVideoTasks(videoID int, sceneIDs []int) (int, error)
+}
+
+// canCancel reports whether a video in status may still have work to stop.
+// Failed videos can still be cancelled, since their tasks may be waiting to retry.
+func canCancel(status VideoStatus) bool {
+	return models.CanTransition(status, VideoStatusCancelled)
+}
+
+// sceneIDs returns the IDs of a video's scenes, whose image tasks only carry a scene ID
+func sceneIDs(video *Video) []int {
+	ids := make([]int, 0, len(video.Scenes))
+	for _, scene := range video.Scenes {
+		ids = append(ids, scene.ID)
+	}
+	return ids
+}
@@ -0,0 +1,35 @@
+package video
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanCancel(t *testing.T) {
+	tests := []struct {
+		status   VideoStatus
+		expected bool
+	}{
+		{VideoStatusPending, true},
+		{VideoStatusGeneratingAudio, true},
+		{VideoStatusRendering, true},
+		{VideoStatusFailed, true},
+		{VideoStatusCompleted, false},
+		{VideoStatusCancelled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status), func(t *testing.T) {
+			if got := canCancel(tt.status); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSceneIDs(t *testing.T) {
+	video := &Video{Scenes: []VideoScene{{ID: 3}, {ID: 5}}}
+	if got := sceneIDs(video); !reflect.DeepEqual(got, []int{3, 5}) {
+		t.Errorf("Expected [3 5], got %v", got)
+	}
+}
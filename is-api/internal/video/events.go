@@ -0,0 +1,262 @@
+package video
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"instashorts-be/is-api/internal/auth"
+	"instashorts-be/pkg/events"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamBuffer is how many events a stream may fall behind before it misses some
+	streamBuffer = 32
+	// keepAliveInterval is how often an idle stream sends a comment, so
+	// proxies don't close it
+	keepAliveInterval = 15 * time.Second
+	// hubWorkers is how many goroutines load progress and deliver events. Each
+	// video's events go to the same worker, so they arrive in order.
+	hubWorkers = 4
+	// hubBacklog is how many events each worker may fall behind before new
+	// ones are dropped rather than holding up the event listener
+	hubBacklog = 256
+)
+
+// Hub fans the events of each user's videos out to that user's open streams,
+// with the progress of the event's video
+type Hub struct {
+	mu      sync.Mutex
+	streams map[int]map[chan streamEvent]struct{}
+	// progress loads a video and its progress
+	progress func(ctx context.Context, videoID int) (*Video, *Progress, error)
+	// backlogs feed the workers, one each
+	backlogs []chan events.Event
+}
+
+// streamEvent is an event and the progress of its video after it. Progress is
+// nil when it couldn't be loaded.
+type streamEvent struct {
+	Event    events.Event
+	Progress gin.H
+}
+
+func NewHub(repo *Repository) *Hub {
+	return newHub(func(ctx context.Context, videoID int) (*Video, *Progress, error) {
+		video, err := repo.GetVideoByID(ctx, videoID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get video: %w", err)
+		}
+		progress, err := repo.GetVideoProgress(ctx, video)
+		if err != nil {
+			return nil, nil, err
+		}
+		return video, progress, nil
+	})
+}
+
+func newHub(progress func(ctx context.Context, videoID int) (*Video, *Progress, error)) *Hub {
+	h := &Hub{streams: make(map[int]map[chan streamEvent]struct{}), progress: progress}
+	for i := 0; i < hubWorkers; i++ {
+		backlog := make(chan events.Event, hubBacklog)
+		h.backlogs = append(h.backlogs, backlog)
+		go func() {
+			for event := range backlog {
+				h.deliver(event)
+			}
+		}()
+	}
+	return h
+}
+
+// Subscribe returns the events of a user's videos until unsubscribe is called
+func (h *Hub) Subscribe(userID int) (<-chan streamEvent, func()) {
+	stream := make(chan streamEvent, streamBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.streams[userID] == nil {
+		h.streams[userID] = make(map[chan streamEvent]struct{})
+	}
+	h.streams[userID][stream] = struct{}{}
+
+	return stream, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.streams[userID], stream)
+		if len(h.streams[userID]) == 0 {
+			delete(h.streams, userID)
+		}
+	}
+}
+
+// Publish hands an event to the worker of its video, if its user has a stream
+// open. It never blocks on the database, since it runs on the event listener:
+// when the worker has fallen too far behind, the event is dropped.
+func (h *Hub) Publish(event events.Event) {
+	if !h.watched(event.UserID) {
+		return
+	}
+
+	backlog := h.backlogs[uint(event.VideoID)%uint(len(h.backlogs))]
+	select {
+	case backlog <- event:
+	default:
+		log.Printf("Dropped %s event for video_id=%d, the event workers fell behind", event.Type, event.VideoID)
+	}
+}
+
+// deliver sends an event to the streams of its user. The progress of its video
+// is loaded once for all of them, and only when the user still has a stream
+// open. A stream that has fallen too far behind misses the event rather than
+// holding up the others.
+func (h *Hub) deliver(event events.Event) {
+	if !h.watched(event.UserID) {
+		return
+	}
+
+	update := streamEvent{Event: event}
+	video, progress, err := h.progress(context.Background(), event.VideoID)
+	switch {
+	case err != nil:
+		log.Printf("ERROR: Failed to compute progress of video_id=%d: %v", event.VideoID, err)
+	case video.UserID == event.UserID:
+		update.Progress = progressEvent(video, progress)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for stream := range h.streams[event.UserID] {
+		select {
+		case stream <- update:
+		default:
+			log.Printf("Dropped %s event for video_id=%d on a stream of user %d that fell behind", event.Type, event.VideoID, event.UserID)
+		}
+	}
+}
+
+// watched reports whether userID has a stream open
+func (h *Hub) watched(userID int) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.streams[userID]) > 0
+}
+
+// StreamVideoEvents streams the status, step and progress events of a video
+// as server-sent events, starting with its current progress
+func (h *Handler) StreamVideoEvents(c *gin.Context) {
+	// Get authenticated user
+	user, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	// Parse video ID
+	videoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	// Get video
+	video, err := h.repo.GetVideoByID(c.Request.Context(), videoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	// Check if user owns the video
+	if video.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this video"})
+		return
+	}
+
+	h.stream(c, user.ID, video)
+}
+
+// StreamEvents streams the status, step and progress events of all the
+// user's videos as server-sent events
+func (h *Handler) StreamEvents(c *gin.Context) {
+	// Get authenticated user
+	user, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	h.stream(c, user.ID, nil)
+}
+
+// stream writes the events of a user's videos, or of video alone when given,
+// until the client goes away. Every event is followed by the progress of its
+// video, as computed by the hub.
+func (h *Handler) stream(c *gin.Context, userID int, video *Video) {
+	// Subscribe first so nothing is missed while the current progress loads
+	stream, unsubscribe := h.hub.Subscribe(userID)
+	defer unsubscribe()
+
+	// Streams stay open far longer than the server's write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("ERROR: Failed to lift the write deadline of an event stream: %v", err)
+	}
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if video != nil {
+		h.writeProgress(c, video)
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+	ctx := c.Request.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			io.WriteString(c.Writer, ": keep-alive\n\n")
+		case update := <-stream:
+			if video != nil && update.Event.VideoID != video.ID {
+				continue
+			}
+			c.SSEvent(string(update.Event.Type), update.Event)
+			if update.Progress != nil {
+				c.SSEvent("progress", update.Progress)
+			}
+		}
+		c.Writer.Flush()
+	}
+}
+
+// writeProgress writes a progress event with the current state of video
+func (h *Handler) writeProgress(c *gin.Context, video *Video) {
+	progress, err := h.repo.GetVideoProgress(c.Request.Context(), video)
+	if err != nil {
+		log.Printf("ERROR: Failed to compute progress of video_id=%d: %v", video.ID, err)
+		return
+	}
+	c.SSEvent("progress", progressEvent(video, progress))
+}
+
+// progressEvent is the data of the progress event of video
+func progressEvent(video *Video, progress *Progress) gin.H {
+	return gin.H{
+		"video_id":    video.ID,
+		"status":      video.Status,
+		"artifacts":   progress.Artifacts,
+		"progress":    progress.Percent,
+		"eta_seconds": progress.ETASeconds,
+	}
+}
@@ -0,0 +1,153 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"instashorts-be/pkg/events"
+)
+
+// staticProgress is a hub progress loader that counts its calls and returns
+// a video of user 1
+func staticProgress(calls *int) func(ctx context.Context, videoID int) (*Video, *Progress, error) {
+	return func(ctx context.Context, videoID int) (*Video, *Progress, error) {
+		*calls++
+		return &Video{ID: videoID, UserID: 1}, &Progress{Percent: 50}, nil
+	}
+}
+
+func TestHubDeliversEventsToTheirUser(t *testing.T) {
+	var calls int
+	hub := newHub(staticProgress(&calls))
+	mine, unsubscribe := hub.Subscribe(1)
+	theirs, unsubscribeTheirs := hub.Subscribe(2)
+	defer unsubscribeTheirs()
+
+	hub.deliver(events.Event{Type: events.TypeStatus, UserID: 1, VideoID: 10})
+
+	select {
+	case update := <-mine:
+		if update.Event.VideoID != 10 {
+			t.Errorf("Expected the event of video 10, got %+v", update.Event)
+		}
+		if update.Progress == nil || update.Progress["progress"] != 50 {
+			t.Errorf("Expected the progress of video 10, got %v", update.Progress)
+		}
+	default:
+		t.Fatal("Expected the event on the stream of its user")
+	}
+	select {
+	case update := <-theirs:
+		t.Errorf("Expected nothing on another user's stream, got %+v", update)
+	default:
+	}
+
+	unsubscribe()
+	hub.deliver(events.Event{Type: events.TypeStatus, UserID: 1, VideoID: 10})
+	if len(mine) != 0 {
+		t.Error("Expected nothing after unsubscribing")
+	}
+	if calls != 1 {
+		t.Errorf("Expected no progress to be loaded for a user without streams, got %d loads", calls)
+	}
+}
+
+func TestHubLoadsProgressOncePerEvent(t *testing.T) {
+	var calls int
+	hub := newHub(staticProgress(&calls))
+	first, unsubscribeFirst := hub.Subscribe(1)
+	defer unsubscribeFirst()
+	second, unsubscribeSecond := hub.Subscribe(1)
+	defer unsubscribeSecond()
+
+	hub.deliver(events.Event{Type: events.TypeStep, UserID: 1, VideoID: 10})
+
+	if calls != 1 {
+		t.Errorf("Expected the progress to be loaded once for both streams, got %d loads", calls)
+	}
+	for _, stream := range []<-chan streamEvent{first, second} {
+		if update := <-stream; update.Progress == nil {
+			t.Errorf("Expected the progress on every stream, got %+v", update)
+		}
+	}
+}
+
+func TestHubSendsEventsWithoutProgressItCouldNotLoad(t *testing.T) {
+	hub := newHub(func(ctx context.Context, videoID int) (*Video, *Progress, error) {
+		if videoID == 10 {
+			return nil, nil, errors.New("connection refused")
+		}
+		// An event on the wrong channel never shows the progress of another user's video
+		return &Video{ID: videoID, UserID: 2}, &Progress{}, nil
+	})
+	stream, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+
+	hub.deliver(events.Event{Type: events.TypeStatus, UserID: 1, VideoID: 10})
+	hub.deliver(events.Event{Type: events.TypeStatus, UserID: 1, VideoID: 11})
+
+	for _, videoID := range []int{10, 11} {
+		update := <-stream
+		if update.Event.VideoID != videoID || update.Progress != nil {
+			t.Errorf("Expected the event of video %d without progress, got %+v", videoID, update)
+		}
+	}
+}
+
+func TestHubDropsEventsForStreamsThatFellBehind(t *testing.T) {
+	var calls int
+	hub := newHub(staticProgress(&calls))
+	slow, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+
+	// Delivering never blocks on a stream nobody reads
+	for i := 0; i < streamBuffer+5; i++ {
+		hub.deliver(events.Event{Type: events.TypeStep, UserID: 1, VideoID: i})
+	}
+
+	if len(slow) != streamBuffer {
+		t.Errorf("Expected %d buffered events, got %d", streamBuffer, len(slow))
+	}
+	if first := <-slow; first.Event.VideoID != 0 {
+		t.Errorf("Expected the oldest event first, got %+v", first)
+	}
+}
+
+func TestHubPublishDoesNotWaitForProgress(t *testing.T) {
+	loading := make(chan struct{})
+	hub := newHub(func(ctx context.Context, videoID int) (*Video, *Progress, error) {
+		<-loading
+		return &Video{ID: videoID, UserID: 1}, &Progress{}, nil
+	})
+	stream, unsubscribe := hub.Subscribe(1)
+	defer unsubscribe()
+
+	// The listener moves on while the progress of the first event loads
+	published := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			hub.Publish(events.Event{Type: events.TypeStep, UserID: 1, VideoID: 10, Step: string(rune('a' + i))})
+		}
+		close(published)
+	}()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Publish to return before the progress loaded")
+	}
+	close(loading)
+
+	// Events of one video keep their order
+	for i := 0; i < 3; i++ {
+		select {
+		case update := <-stream:
+			if update.Event.Step != string(rune('a'+i)) {
+				t.Errorf("Expected step %c, got %+v", 'a'+i, update.Event)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected event %d on the stream", i)
+		}
+	}
+}
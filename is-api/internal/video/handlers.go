@@ -1,25 +1,44 @@
 package video
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
 	"instashorts-be/is-api/internal/auth"
 	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
 
 	"github.com/gin-gonic/gin"
 )
 
+// VoiceCatalog knows which voice IDs videos can be created with
+type VoiceCatalog interface {
+	HasVoice(ctx context.Context, voiceID string) (bool, error)
+}
+
 type Handler struct {
 	repo        *Repository
 	queueClient *queue.Client
+	tasks       TaskCanceller
+	voices      VoiceCatalog
+	media       MediaSigner
+	hub         *Hub
+	retrier     *Retrier
 }
 
-func NewHandler(repo *Repository, queueClient *queue.Client) *Handler {
+func NewHandler(repo *Repository, queueClient *queue.Client, tasks TaskCanceller, voices VoiceCatalog, media MediaSigner, hub *Hub) *Handler {
 	return &Handler{
 		repo:        repo,
 		queueClient: queueClient,
+		tasks:       tasks,
+		voices:      voices,
+		media:       media,
+		hub:         hub,
+		retrier:     NewRetrier(repo, tasks, media),
 	}
 }
 
@@ -39,28 +58,33 @@ func (h *Handler) CreateVideo(c *gin.Context) {
 		return
 	}
 
+	// Reject unknown voices up front instead of failing in the worker
+	known, err := h.voices.HasVoice(c.Request.Context(), req.VoiceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate voice"})
+		return
+	}
+	if !known {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown voice_id: %s", req.VoiceID)})
+		return
+	}
+
 	// Create video
 	video := &Video{
-		UserID:  user.ID,
-		Title:   req.Title,
-		Theme:   req.Theme,
-		VoiceID: req.VoiceID,
-		Status:  VideoStatusPending,
+		UserID:        user.ID,
+		Title:         req.Title,
+		Theme:         req.Theme,
+		VoiceID:       req.VoiceID,
+		VoiceSettings: req.VoiceSettings,
+		Status:        VideoStatusPending,
 	}
 
-	if err := h.repo.CreateVideo(c.Request.Context(), video); err != nil {
+	// The script task and the ones after it carry the requesting user
+	if err := h.repo.CreateVideo(queue.WithUserID(c.Request.Context(), user.ID), video); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create video"})
 		return
 	}
 
-	// Enqueue video script generation task
-	if err := h.queueClient.EnqueueGenerateVideoScript(queue.GenerateVideoScriptPayload{
-		VideoID: video.ID,
-	}); err != nil {
-		// Log the error but don't fail the request - video is already created
-		fmt.Printf("Failed to enqueue video script generation task: %v\n", err)
-	}
-
 	c.JSON(http.StatusCreated, gin.H{
 		"video":   video,
 		"message": "Video created successfully",
@@ -96,6 +120,12 @@ func (h *Handler) GetVideo(c *gin.Context) {
 		return
 	}
 
+	// Stored media is private; hand out URLs that are only valid for a while
+	if err := signMediaURLs(c.Request.Context(), h.media, video); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign media URLs"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"video": video})
 }
 
@@ -128,11 +158,184 @@ func (h *Handler) GetVideoStatus(c *gin.Context) {
 		return
 	}
 
+	progress, err := h.repo.GetVideoProgress(c.Request.Context(), video)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve video progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":    video.ID,
+		"status":      video.Status,
+		"theme":       video.Theme,
+		"script":      video.Script,
+		"artifacts":   progress.Artifacts,
+		"progress":    progress.Percent,
+		"eta_seconds": progress.ETASeconds,
+	})
+}
+
+// GetVideoTimeline retrieves the per-step pipeline history of a video
+func (h *Handler) GetVideoTimeline(c *gin.Context) {
+	// Get authenticated user
+	user, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	// Parse video ID
+	videoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	// Get video
+	video, err := h.repo.GetVideoByID(c.Request.Context(), videoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	// Check if user owns the video
+	if video.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this video"})
+		return
+	}
+
+	steps, err := h.repo.GetPipelineSteps(c.Request.Context(), video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve video timeline"})
+		return
+	}
+
+	transitions, err := h.repo.GetStatusTransitions(c.Request.Context(), video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve video timeline"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
+		"video_id":    video.ID,
+		"status":      video.Status,
+		"steps":       steps,
+		"transitions": transitions,
+	})
+}
+
+// RetryVideo resumes a failed video from the first missing artifact
+func (h *Handler) RetryVideo(c *gin.Context) {
+	// Get authenticated user
+	user, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	// Parse video ID
+	videoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	// Get video with its scenes
+	video, err := h.repo.GetVideoByID(c.Request.Context(), videoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	// Check if user owns the video
+	if video.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this video"})
+		return
+	}
+
+	// Only failed videos can be resumed; anything else is still in flight or done
+	if video.Status != VideoStatusFailed {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Only failed videos can be retried (status: %s)", video.Status)})
+		return
+	}
+
+	plan, err := h.retrier.Retry(c.Request.Context(), video, "retried by the user")
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrNothingToRetry):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video has no missing steps to retry"})
+		case errors.Is(err, repository.ErrStatusChanged):
+			c.JSON(http.StatusConflict, gin.H{"error": "Video changed while it was being retried, try again"})
+		default:
+			log.Printf("ERROR: Failed to retry video %d: %v", video.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry video"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
 		"video_id": video.ID,
-		"status":   video.Status,
-		"theme":    video.Theme,
-		"script":   video.Script,
+		"retry":    plan,
+		"message":  "Video retry started",
+	})
+}
+
+// CancelVideo stops an in-flight video and removes its queued tasks
+func (h *Handler) CancelVideo(c *gin.Context) {
+	// Get authenticated user
+	user, exists := auth.GetUserFromContext(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Not authenticated"})
+		return
+	}
+
+	// Parse video ID
+	videoID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	// Get video with its scenes
+	video, err := h.repo.GetVideoByID(c.Request.Context(), videoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		return
+	}
+
+	// Check if user owns the video
+	if video.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You don't have access to this video"})
+		return
+	}
+
+	if !canCancel(video.Status) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Video can no longer be cancelled (status: %s)", video.Status)})
+		return
+	}
+
+	// The status is what stops the worker: every handler checks it before a paid call
+	cancelled, err := h.repo.CancelVideo(c.Request.Context(), video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel video"})
+		return
+	}
+	if !cancelled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Video finished before it could be cancelled"})
+		return
+	}
+
+	// Removing queued tasks is best-effort; any left over skip the cancelled video
+	removed, err := h.tasks.CancelVideoTasks(video.ID, sceneIDs(video))
+	if err != nil {
+		log.Printf("ERROR: Failed to remove queued tasks for video %d: %v", video.ID, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"video_id":        video.ID,
+		"status":          VideoStatusCancelled,
+		"cancelled_tasks": removed,
+		"message":         "Video cancelled",
 	})
 }
 
@@ -190,5 +393,10 @@ func (h *Handler) DeleteVideo(c *gin.Context) {
 		return
 	}
 
+	// Stop paying for a video nobody will see; the worker also skips deleted videos
+	if _, err := h.tasks.CancelVideoTasks(video.ID, sceneIDs(video)); err != nil {
+		log.Printf("ERROR: Failed to remove queued tasks for video %d: %v", video.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Video deleted successfully"})
 }
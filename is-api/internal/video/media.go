@@ -0,0 +1,65 @@
+package video
+
+import (
+	"context"
+	"fmt"
+
+	"instashorts-be/pkg/queue"
+)
+
+// MediaSigner signs short-lived read URLs for media stored by bucket and key
+type MediaSigner interface {
+	SignURL(ctx context.Context, bucket string, key string) (string, error)
+}
+
+// signMediaURLs fills in AudioURL and each scene's ImageURL from their stored keys.
+// Videos generated before keys were stored keep the URLs saved with them.
+func signMediaURLs(ctx context.Context, signer MediaSigner, video *Video) error {
+	if !isBlank(video.AudioKey) {
+		url, err := signer.SignURL(ctx, deref(video.AudioBucket), *video.AudioKey)
+		if err != nil {
+			return fmt.Errorf("failed to sign audio URL: %w", err)
+		}
+		video.AudioURL = &url
+	}
+
+	for i := range video.Scenes {
+		scene := &video.Scenes[i]
+		if isBlank(scene.ImageKey) {
+			continue
+		}
+		url, err := signer.SignURL(ctx, deref(scene.ImageBucket), *scene.ImageKey)
+		if err != nil {
+			return fmt.Errorf("failed to sign image URL for scene %d: %w", scene.Index, err)
+		}
+		scene.ImageURL = &url
+	}
+
+	return nil
+}
+
+// renderPayload builds the render task of video, carrying the signed media URLs
+// the renderer service can't sign itself
+func renderPayload(ctx context.Context, signer MediaSigner, video *Video) (queue.RenderVideoPayload, error) {
+	signed := *video
+	signed.Scenes = append([]VideoScene(nil), video.Scenes...)
+	if err := signMediaURLs(ctx, signer, &signed); err != nil {
+		return queue.RenderVideoPayload{}, err
+	}
+
+	payload := queue.RenderVideoPayload{VideoID: video.ID, AudioURL: deref(signed.AudioURL)}
+	for _, scene := range signed.Scenes {
+		if isBlank(scene.ImageURL) {
+			continue
+		}
+		payload.SceneImages = append(payload.SceneImages, queue.RenderSceneImage{SceneID: scene.ID, ImageURL: *scene.ImageURL})
+	}
+	return payload, nil
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,82 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeSigner struct {
+	err error
+}
+
+func (f fakeSigner) SignURL(ctx context.Context, bucket string, key string) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	return "https://signed.example.com/" + bucket + "/" + key, nil
+}
+
+func TestSignMediaURLs(t *testing.T) {
+	video := &Video{
+		AudioBucket: strPtr("media"),
+		AudioKey:    strPtr("audio/1/1.mp3"),
+		AudioURL:    strPtr("https://expired.example.com/a.mp3"),
+		Scenes: []VideoScene{
+			{Index: 0, ImageBucket: strPtr("media"), ImageKey: strPtr("images/1/scene_0.png")},
+			{Index: 1, ImageURL: strPtr("https://legacy.example.com/1.png")},
+			{Index: 2},
+		},
+	}
+
+	if err := signMediaURLs(context.Background(), fakeSigner{}, video); err != nil {
+		t.Fatalf("signMediaURLs returned error: %v", err)
+	}
+
+	if got := deref(video.AudioURL); got != "https://signed.example.com/media/audio/1/1.mp3" {
+		t.Errorf("Expected freshly signed audio URL, got %s", got)
+	}
+	if got := deref(video.Scenes[0].ImageURL); got != "https://signed.example.com/media/images/1/scene_0.png" {
+		t.Errorf("Expected freshly signed image URL, got %s", got)
+	}
+	if got := deref(video.Scenes[1].ImageURL); got != "https://legacy.example.com/1.png" {
+		t.Errorf("Expected legacy image URL to be kept, got %s", got)
+	}
+	if video.Scenes[2].ImageURL != nil {
+		t.Errorf("Expected no URL for a scene without an image, got %s", *video.Scenes[2].ImageURL)
+	}
+}
+
+func TestSignMediaURLsError(t *testing.T) {
+	video := &Video{AudioKey: strPtr("audio/1/1.mp3")}
+	if err := signMediaURLs(context.Background(), fakeSigner{err: errors.New("no credentials")}, video); err == nil {
+		t.Error("Expected error when signing fails")
+	}
+}
+
+func TestRenderPayloadCarriesSignedMedia(t *testing.T) {
+	video := &Video{
+		ID:          1,
+		AudioBucket: strPtr("media"),
+		AudioKey:    strPtr("audio/1/1.mp3"),
+		Scenes: []VideoScene{
+			{ID: 7, ImageBucket: strPtr("media"), ImageKey: strPtr("images/1/scene_0.png")},
+			{ID: 8, ImageURL: strPtr("https://legacy.example.com/1.png")},
+		},
+	}
+
+	payload, err := renderPayload(context.Background(), fakeSigner{}, video)
+	if err != nil {
+		t.Fatalf("renderPayload returned error: %v", err)
+	}
+
+	if payload.VideoID != 1 || payload.AudioURL != "https://signed.example.com/media/audio/1/1.mp3" {
+		t.Errorf("Expected the signed audio URL of video 1, got %+v", payload)
+	}
+	if len(payload.SceneImages) != 2 || payload.SceneImages[0].SceneID != 7 || payload.SceneImages[1].ImageURL != "https://legacy.example.com/1.png" {
+		t.Errorf("Expected an image URL for both scenes, got %+v", payload.SceneImages)
+	}
+	if video.AudioURL != nil {
+		t.Error("Expected the video itself to be left unsigned")
+	}
+}
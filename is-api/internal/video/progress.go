@@ -0,0 +1,186 @@
+package video
+
+import (
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+)
+
+// ArtifactState is how far the step that produces an artifact has got
+type ArtifactState string
+
+const (
+	ArtifactPending   ArtifactState = "pending"
+	ArtifactRunning   ArtifactState = "running"
+	ArtifactRetrying  ArtifactState = "retrying"
+	ArtifactCompleted ArtifactState = "completed"
+	ArtifactFailed    ArtifactState = "failed"
+)
+
+// ImageProgress is the state of a video's scene images, Done of Total
+type ImageProgress struct {
+	State ArtifactState `json:"state"`
+	Done  int           `json:"done"`
+	Total int           `json:"total"`
+}
+
+// Artifacts is the state of everything a video is built from. The audio
+// branch (audio, captions) and the scene branch (scenes, images) run in
+// parallel, which a single status can't show.
+type Artifacts struct {
+	Script   ArtifactState `json:"script"`
+	Audio    ArtifactState `json:"audio"`
+	Captions ArtifactState `json:"captions"`
+	Scenes   ArtifactState `json:"scenes"`
+	Images   ImageProgress `json:"images"`
+	Render   ArtifactState `json:"render"`
+}
+
+// Progress is how far a video has got and how long it probably has left
+type Progress struct {
+	Artifacts Artifacts `json:"artifacts"`
+	// Percent runs from 0 to 100
+	Percent int `json:"progress"`
+	// ETASeconds is nil for finished videos and when a remaining step has no history yet
+	ETASeconds *int `json:"eta_seconds"`
+}
+
+// progressWeights is how much of the progress percentage each step is worth
+var progressWeights = map[string]float64{
+	queue.TypeGenerateVideoScript: 10,
+	queue.TypeGenerateAudio:       20,
+	queue.TypeGenerateCaptions:    10,
+	queue.TypeGenerateScenes:      10,
+	queue.TypeGenerateSceneImage:  30,
+	queue.TypeRenderVideo:         20,
+}
+
+// computeProgress derives a video's progress from the artifacts it has, the
+// state of its scenes and its pipeline history. averages holds the usual
+// duration of each step, from which the ETA is estimated.
+func computeProgress(video *Video, steps []PipelineStep, averages map[string]time.Duration, now time.Time) *Progress {
+	latest := make(map[string]*PipelineStep)
+	for i := range steps {
+		latest[steps[i].Step] = &steps[i]
+	}
+	state := func(done bool, step string) ArtifactState {
+		if done {
+			return ArtifactCompleted
+		}
+		return stepState(video, latest[step])
+	}
+
+	images := ImageProgress{Total: len(video.Scenes), State: ArtifactPending}
+	for _, scene := range video.Scenes {
+		switch {
+		case !isBlank(scene.ImageKey) || !isBlank(scene.ImageURL):
+			images.Done++
+		case scene.Status == models.SceneStatusFailed:
+			images.State = ArtifactFailed
+		case scene.Status == models.SceneStatusGenerating && images.State != ArtifactFailed:
+			images.State = ArtifactRunning
+		}
+	}
+	if images.Total > 0 && images.Done == images.Total {
+		images.State = ArtifactCompleted
+	}
+
+	render := state(!isBlank(video.VideoURL), queue.TypeRenderVideo)
+	if render != ArtifactCompleted && video.Status == VideoStatusRendering {
+		// The TypeScript renderer renders without recording pipeline steps
+		render = ArtifactRunning
+	}
+
+	progress := &Progress{
+		Artifacts: Artifacts{
+			Script:   state(!isBlank(video.Script), queue.TypeGenerateVideoScript),
+			Audio:    state(!isBlank(video.AudioKey) || !isBlank(video.AudioURL), queue.TypeGenerateAudio),
+			Captions: state(!isBlank(video.Captions), queue.TypeGenerateCaptions),
+			Scenes:   state(len(video.Scenes) > 0, queue.TypeGenerateScenes),
+			Images:   images,
+			Render:   render,
+		},
+	}
+
+	artifacts := progress.Artifacts
+	done := map[string]float64{
+		queue.TypeGenerateVideoScript: completed(artifacts.Script),
+		queue.TypeGenerateAudio:       completed(artifacts.Audio),
+		queue.TypeGenerateCaptions:    completed(artifacts.Captions),
+		queue.TypeGenerateScenes:      completed(artifacts.Scenes),
+		queue.TypeGenerateSceneImage:  0,
+		queue.TypeRenderVideo:         completed(artifacts.Render),
+	}
+	if images.Total > 0 {
+		done[queue.TypeGenerateSceneImage] = float64(images.Done) / float64(images.Total)
+	}
+	percent := 0.0
+	for step, weight := range progressWeights {
+		percent += weight * done[step]
+	}
+	progress.Percent = int(percent)
+
+	if eta, ok := estimateRemaining(video, latest, done, averages, now); ok {
+		seconds := int(eta.Round(time.Second).Seconds())
+		progress.ETASeconds = &seconds
+	}
+	return progress
+}
+
+// stepState maps the latest attempt of a step to the state of its artifact
+func stepState(video *Video, step *PipelineStep) ArtifactState {
+	if step == nil {
+		return ArtifactPending
+	}
+	switch step.Outcome {
+	case PipelineStepRunning:
+		return ArtifactRunning
+	case PipelineStepFailed:
+		// asynq retries a failed attempt until the video is marked failed
+		if video.Status == VideoStatusFailed {
+			return ArtifactFailed
+		}
+		return ArtifactRetrying
+	}
+	return ArtifactPending
+}
+
+func completed(state ArtifactState) float64 {
+	if state == ArtifactCompleted {
+		return 1
+	}
+	return 0
+}
+
+// estimateRemaining adds up the usual durations of the steps left, taking
+// the slower of the audio and scene branches since they run in parallel.
+// Scene images run in parallel too, so they count once. A running step is
+// credited with the time it has already taken.
+func estimateRemaining(video *Video, latest map[string]*PipelineStep, done map[string]float64, averages map[string]time.Duration, now time.Time) (time.Duration, bool) {
+	switch video.Status {
+	case VideoStatusCompleted, VideoStatusFailed, VideoStatusCancelled:
+		return 0, false
+	}
+
+	known := true
+	remaining := func(step string) time.Duration {
+		if done[step] == 1 {
+			return 0
+		}
+		average, ok := averages[step]
+		if !ok {
+			known = false
+			return 0
+		}
+		if attempt := latest[step]; attempt != nil && attempt.Outcome == PipelineStepRunning {
+			average -= now.Sub(attempt.StartedAt)
+		}
+		return max(average, 0)
+	}
+
+	audio := remaining(queue.TypeGenerateAudio) + remaining(queue.TypeGenerateCaptions)
+	scenes := remaining(queue.TypeGenerateScenes) + remaining(queue.TypeGenerateSceneImage)
+	eta := remaining(queue.TypeGenerateVideoScript) + max(audio, scenes) + remaining(queue.TypeRenderVideo)
+	return eta, known
+}
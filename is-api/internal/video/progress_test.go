@@ -0,0 +1,170 @@
+package video
+
+import (
+	"testing"
+	"time"
+
+	"instashorts-be/pkg/queue"
+)
+
+func TestComputeProgress(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	averages := map[string]time.Duration{
+		queue.TypeGenerateVideoScript: 10 * time.Second,
+		queue.TypeGenerateAudio:       30 * time.Second,
+		queue.TypeGenerateCaptions:    20 * time.Second,
+		queue.TypeGenerateScenes:      15 * time.Second,
+		queue.TypeGenerateSceneImage:  25 * time.Second,
+		queue.TypeRenderVideo:         60 * time.Second,
+	}
+	running := func(step string, elapsed time.Duration) PipelineStep {
+		return PipelineStep{Step: step, Outcome: PipelineStepRunning, StartedAt: now.Add(-elapsed)}
+	}
+	failed := func(step string) PipelineStep {
+		return PipelineStep{Step: step, Outcome: PipelineStepFailed, StartedAt: now.Add(-time.Minute)}
+	}
+	image := func(status string, done bool) VideoScene {
+		scene := VideoScene{Status: status}
+		if done {
+			scene.ImageKey = strPtr("images/1/a.png")
+		}
+		return scene
+	}
+	seconds := func(s int) *int { return &s }
+
+	tests := []struct {
+		name      string
+		video     Video
+		steps     []PipelineStep
+		averages  map[string]time.Duration
+		artifacts Artifacts
+		percent   int
+		eta       *int
+	}{
+		{
+			name:  "new video",
+			video: Video{Status: VideoStatusPending},
+			artifacts: Artifacts{
+				Script: ArtifactPending, Audio: ArtifactPending, Captions: ArtifactPending, Scenes: ArtifactPending,
+				Images: ImageProgress{State: ArtifactPending}, Render: ArtifactPending,
+			},
+			percent: 0,
+			// script + the audio branch (50s, slower than the scene branch's 40s) + render
+			eta: seconds(120),
+		},
+		{
+			name: "audio and images running in parallel",
+			video: Video{
+				Status: VideoStatusGeneratingImages,
+				Script: strPtr("script"),
+				Scenes: []VideoScene{image("completed", true), image("generating", false)},
+			},
+			steps: []PipelineStep{
+				{Step: queue.TypeGenerateVideoScript, Outcome: PipelineStepSucceeded},
+				running(queue.TypeGenerateAudio, 25*time.Second),
+				running(queue.TypeGenerateSceneImage, 5*time.Second),
+			},
+			artifacts: Artifacts{
+				Script: ArtifactCompleted, Audio: ArtifactRunning, Captions: ArtifactPending, Scenes: ArtifactCompleted,
+				Images: ImageProgress{State: ArtifactRunning, Done: 1, Total: 2}, Render: ArtifactPending,
+			},
+			percent: 35,
+			// the rest of the audio and the captions (5s + 20s) outlast the last image (20s)
+			eta: seconds(85),
+		},
+		{
+			name: "captions retrying",
+			video: Video{
+				Status:   VideoStatusGeneratingImages,
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1.mp3"),
+				Scenes:   []VideoScene{image("completed", true)},
+			},
+			steps: []PipelineStep{failed(queue.TypeGenerateCaptions)},
+			artifacts: Artifacts{
+				Script: ArtifactCompleted, Audio: ArtifactCompleted, Captions: ArtifactRetrying, Scenes: ArtifactCompleted,
+				Images: ImageProgress{State: ArtifactCompleted, Done: 1, Total: 1}, Render: ArtifactPending,
+			},
+			percent: 70,
+			eta:     seconds(80),
+		},
+		{
+			name: "failed",
+			video: Video{
+				Status: VideoStatusFailed,
+				Script: strPtr("script"),
+				Scenes: []VideoScene{image("failed", false), image("completed", true)},
+			},
+			steps: []PipelineStep{failed(queue.TypeGenerateAudio)},
+			artifacts: Artifacts{
+				Script: ArtifactCompleted, Audio: ArtifactFailed, Captions: ArtifactPending, Scenes: ArtifactCompleted,
+				Images: ImageProgress{State: ArtifactFailed, Done: 1, Total: 2}, Render: ArtifactPending,
+			},
+			percent: 35,
+		},
+		{
+			name: "rendered by the TypeScript renderer",
+			video: Video{
+				Status:   VideoStatusRendering,
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1.mp3"),
+				Captions: strPtr("[]"),
+				Scenes:   []VideoScene{image("completed", true)},
+			},
+			artifacts: Artifacts{
+				Script: ArtifactCompleted, Audio: ArtifactCompleted, Captions: ArtifactCompleted, Scenes: ArtifactCompleted,
+				Images: ImageProgress{State: ArtifactCompleted, Done: 1, Total: 1}, Render: ArtifactRunning,
+			},
+			percent: 80,
+			eta:     seconds(60),
+		},
+		{
+			name: "completed",
+			video: Video{
+				Status:   VideoStatusCompleted,
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1.mp3"),
+				Captions: strPtr("[]"),
+				Scenes:   []VideoScene{image("completed", true)},
+				VideoURL: strPtr("https://example.com/1.mp4"),
+			},
+			artifacts: Artifacts{
+				Script: ArtifactCompleted, Audio: ArtifactCompleted, Captions: ArtifactCompleted, Scenes: ArtifactCompleted,
+				Images: ImageProgress{State: ArtifactCompleted, Done: 1, Total: 1}, Render: ArtifactCompleted,
+			},
+			percent: 100,
+		},
+		{
+			name:     "no history for a remaining step",
+			video:    Video{Status: VideoStatusPending},
+			averages: map[string]time.Duration{queue.TypeGenerateVideoScript: time.Second},
+			artifacts: Artifacts{
+				Script: ArtifactPending, Audio: ArtifactPending, Captions: ArtifactPending, Scenes: ArtifactPending,
+				Images: ImageProgress{State: ArtifactPending}, Render: ArtifactPending,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stepAverages := averages
+			if tt.averages != nil {
+				stepAverages = tt.averages
+			}
+			progress := computeProgress(&tt.video, tt.steps, stepAverages, now)
+
+			if progress.Artifacts != tt.artifacts {
+				t.Errorf("Expected artifacts %+v, got %+v", tt.artifacts, progress.Artifacts)
+			}
+			if progress.Percent != tt.percent {
+				t.Errorf("Expected %d%%, got %d%%", tt.percent, progress.Percent)
+			}
+			switch {
+			case tt.eta == nil && progress.ETASeconds != nil:
+				t.Errorf("Expected no ETA, got %ds", *progress.ETASeconds)
+			case tt.eta != nil && (progress.ETASeconds == nil || *progress.ETASeconds != *tt.eta):
+				t.Errorf("Expected an ETA of %ds, got %v", *tt.eta, progress.ETASeconds)
+			}
+		})
+	}
+}
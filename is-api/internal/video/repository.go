@@ -2,21 +2,46 @@ package video
 
 import (
 	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"instashorts-be/pkg/events"
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
 
 	"gorm.io/gorm"
 )
 
 type Repository struct {
 	db *gorm.DB
+	// videos makes status changes the way the worker does, through the
+	// transition table, and publishes them to publisher
+	videos repository.VideoStore
+	// averages keeps the step durations between reads of the step history
+	averages *averagesCache
 }
 
-func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+func NewRepository(db *gorm.DB, publisher events.Publisher) *Repository {
+	return &Repository{
+		db:       db,
+		videos:   repository.WithEvents(repository.NewGormVideoStore(db), publisher),
+		averages: &averagesCache{ttl: stepAveragesTTL},
+	}
 }
 
-// CreateVideo creates a new video in the database
+// CreateVideo creates a new video in the database and queues its script
+// generation through the outbox, so the video can't be left pending
 func (r *Repository) CreateVideo(ctx context.Context, video *Video) error {
-	return r.db.WithContext(ctx).Create(video).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(video).Error; err != nil {
+			return err
+		}
+		return queue.WriteOutbox(tx, queue.TypeGenerateVideoScript, queue.GenerateVideoScriptPayload{
+			VideoID: video.ID,
+		})
+	})
 }
 
 // GetVideoByID retrieves a video by its ID with its scenes
@@ -56,6 +81,175 @@ func (r *Repository) UpdateVideoScript(ctx context.Context, id int, script strin
 		Update("script", script).Error
 }
 
+// RetryVideo moves a failed video to the status of plan, resets the scenes it
+// regenerates, completes the ones whose image is already saved and writes its
+// tasks to the outbox, all in one transaction, so
+// the video can't be left waiting for tasks that were never queued.
+// Retrying a failed video clears its failure reason.
+func (r *Repository) RetryVideo(ctx context.Context, id int, plan *RetryPlan, tasks []queue.Payload, reason string) error {
+	return r.videos.Transaction(ctx, func(tx repository.VideoStore) error {
+		for _, sceneID := range plan.SceneIDs {
+			if err := tx.SetSceneStatus(ctx, sceneID, models.SceneStatusPending); err != nil {
+				return err
+			}
+		}
+		for _, sceneID := range plan.CompletedSceneIDs {
+			if err := tx.SetSceneStatus(ctx, sceneID, models.SceneStatusCompleted); err != nil {
+				return err
+			}
+		}
+		if err := tx.Transition(ctx, id, VideoStatusFailed, plan.Status, reason); err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := tx.WriteOutbox(ctx, task.TaskType(), task); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CancelVideo moves a video to cancelled unless it already finished or was
+// cancelled. It reports whether the video was cancelled by this call.
+func (r *Repository) CancelVideo(ctx context.Context, id int) (bool, error) {
+	return r.videos.CancelVideo(ctx, id, "cancelled by the user")
+}
+
+// GetPipelineSteps retrieves the pipeline step history of a video in the order it ran
+func (r *Repository) GetPipelineSteps(ctx context.Context, videoID int) ([]PipelineStep, error) {
+	var steps []PipelineStep
+	err := r.db.WithContext(ctx).
+		Where("video_id = ?", videoID).
+		Order("started_at ASC, id ASC").
+		Find(&steps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range steps {
+		if steps[i].FinishedAt != nil {
+			duration := steps[i].FinishedAt.Sub(steps[i].StartedAt).Milliseconds()
+			steps[i].DurationMS = &duration
+		}
+	}
+	return steps, nil
+}
+
+// stepDurationSamples is how many recent successful attempts of each step
+// GetAverageStepDurations averages
+const stepDurationSamples = 50
+
+// stepAveragesTTL is how long step averages are reused. They barely move
+// between reads, and every status request and streamed event needs them.
+const stepAveragesTTL = time.Minute
+
+// averagesCache holds the step averages last read until they expire
+type averagesCache struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	averages  map[string]time.Duration
+	expiresAt time.Time
+}
+
+// get returns the cached averages, reading them with load once they expire.
+// A failed read is returned and not cached.
+func (c *averagesCache) get(ctx context.Context, now time.Time, load func(context.Context) (map[string]time.Duration, error)) (map[string]time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.averages != nil && now.Before(c.expiresAt) {
+		return c.averages, nil
+	}
+	averages, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.averages, c.expiresAt = averages, now.Add(c.ttl)
+	return averages, nil
+}
+
+// GetAverageStepDurations returns how long each pipeline step recently took
+// on average when it succeeded, as of at most stepAveragesTTL ago. The map is
+// shared between callers and must not be changed.
+func (r *Repository) GetAverageStepDurations(ctx context.Context) (map[string]time.Duration, error) {
+	return r.averages.get(ctx, time.Now(), r.readAverageStepDurations)
+}
+
+// readAverageStepDurations averages the recent successful attempts of each step
+func (r *Repository) readAverageStepDurations(ctx context.Context) (map[string]time.Duration, error) {
+	var rows []struct {
+		Step      string
+		AverageMS float64
+	}
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT step, AVG(EXTRACT(EPOCH FROM (finished_at - started_at)) * 1000) AS average_ms
+		FROM (
+			SELECT step, started_at, finished_at,
+				ROW_NUMBER() OVER (PARTITION BY step ORDER BY finished_at DESC) AS recent
+			FROM video_pipeline_steps
+			WHERE outcome = ? AND finished_at IS NOT NULL
+		) steps
+		WHERE recent <= ?
+		GROUP BY step`, PipelineStepSucceeded, stepDurationSamples).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	averages := make(map[string]time.Duration, len(rows))
+	for _, row := range rows {
+		averages[row.Step] = time.Duration(row.AverageMS * float64(time.Millisecond))
+	}
+	return averages, nil
+}
+
+// GetVideoProgress computes the progress of a video from its pipeline history
+func (r *Repository) GetVideoProgress(ctx context.Context, video *Video) (*Progress, error) {
+	steps, err := r.GetPipelineSteps(ctx, video.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pipeline steps: %w", err)
+	}
+	averages, err := r.GetAverageStepDurations(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get average step durations: %w", err)
+	}
+	return computeProgress(video, steps, averages, time.Now().UTC()), nil
+}
+
+// GetStatusTransitions retrieves the status changes of a video in order
+func (r *Repository) GetStatusTransitions(ctx context.Context, videoID int) ([]VideoStatusTransition, error) {
+	return r.videos.ListStatusTransitions(ctx, videoID)
+}
+
+// GetVideosByIDs retrieves videos without their scenes, including deleted ones
+func (r *Repository) GetVideosByIDs(ctx context.Context, ids []int) ([]Video, error) {
+	var videos []Video
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("id IN ?", ids).
+		Find(&videos).Error
+	return videos, err
+}
+
+// GetSceneVideoIDs maps scene IDs to the IDs of their videos, including deleted scenes
+func (r *Repository) GetSceneVideoIDs(ctx context.Context, sceneIDs []int) (map[int]int, error) {
+	var scenes []VideoScene
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Select("id", "video_id").
+		Where("id IN ?", sceneIDs).
+		Find(&scenes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	videoIDs := make(map[int]int, len(scenes))
+	for _, scene := range scenes {
+		videoIDs[scene.ID] = scene.VideoID
+	}
+	return videoIDs, nil
+}
+
 // DeleteVideo soft deletes a video
 func (r *Repository) DeleteVideo(ctx context.Context, id int) error {
 	return r.db.WithContext(ctx).Delete(&Video{}, id).Error
@@ -0,0 +1,42 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAveragesCache(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := &averagesCache{ttl: time.Minute}
+	reads := 0
+	var readErr error
+	load := func(ctx context.Context) (map[string]time.Duration, error) {
+		reads++
+		if readErr != nil {
+			return nil, readErr
+		}
+		return map[string]time.Duration{"video:render": time.Duration(reads) * time.Second}, nil
+	}
+
+	first, err := cache.get(context.Background(), now, load)
+	if err != nil {
+		t.Fatalf("get returned error: %v", err)
+	}
+	again, _ := cache.get(context.Background(), now.Add(59*time.Second), load)
+	if reads != 1 || again["video:render"] != first["video:render"] {
+		t.Errorf("Expected the averages to be reused within the TTL, got %d reads", reads)
+	}
+
+	// A failed read leaves the next request to try again
+	readErr = errors.New("connection refused")
+	if _, err := cache.get(context.Background(), now.Add(time.Minute), load); !errors.Is(err, readErr) {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+	readErr = nil
+	fresh, err := cache.get(context.Background(), now.Add(time.Minute), load)
+	if err != nil || reads != 3 || fresh["video:render"] != 3*time.Second {
+		t.Errorf("Expected fresh averages after the TTL, got %v after %d reads (%v)", fresh, reads, err)
+	}
+}
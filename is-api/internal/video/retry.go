@@ -0,0 +1,187 @@
+package video
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+)
+
+// ErrNothingToRetry is returned for a failed video whose artifacts all exist
+var ErrNothingToRetry = errors.New("video has no missing steps to retry")
+
+// RetryPlan describes which pipeline steps to re-enqueue for a failed video
+// and the status the video should be moved to before they run
+type RetryPlan struct {
+	Status    VideoStatus `json:"status"`
+	TaskTypes []string    `json:"task_types"`
+	SceneIDs  []int       `json:"scene_ids,omitempty"`
+	// CompletedSceneIDs are scenes whose image was saved but whose task failed
+	// before marking them completed. The retry marks them completed, since the
+	// render waits for every scene to be.
+	CompletedSceneIDs []int `json:"completed_scene_ids,omitempty"`
+}
+
+// Empty reports whether every artifact already exists
+func (p *RetryPlan) Empty() bool {
+	return len(p.TaskTypes) == 0
+}
+
+// Retrier resumes failed videos from their first missing step. The user's
+// retry and the admin requeue of a failed video's task both go through it.
+type Retrier struct {
+	repo  *Repository
+	tasks TaskCanceller
+	media MediaSigner
+}
+
+func NewRetrier(repo *Repository, tasks TaskCanceller, media MediaSigner) *Retrier {
+	return &Retrier{
+		repo:  repo,
+		tasks: tasks,
+		media: media,
+	}
+}
+
+// Retry moves video, which must be failed and carry its scenes, to the status
+// of its retry plan and queues the plan's tasks. It returns ErrNothingToRetry
+// when every artifact exists, and repository.ErrStatusChanged when the video
+// is no longer failed.
+func (r *Retrier) Retry(ctx context.Context, video *Video, reason string) (*RetryPlan, error) {
+	plan := planRetry(video)
+	if plan.Empty() {
+		return nil, ErrNothingToRetry
+	}
+
+	tasks, err := retryTasks(ctx, r.media, video, plan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build retry tasks: %w", err)
+	}
+
+	// Scene image tasks are named after their scene, and an archived one would
+	// collide with the retry's task for the same scene when it is relayed
+	if _, err := r.tasks.DeleteArchivedVideoTasks(video.ID, sceneIDs(video)); err != nil {
+		log.Printf("ERROR: Failed to delete archived tasks for video %d: %v", video.ID, err)
+	}
+
+	// The tasks go through the outbox with the status change, so the video
+	// can't be left waiting on tasks that never reached the queue
+	ctx = queue.WithUserID(ctx, video.UserID)
+	if err := r.repo.RetryVideo(ctx, video.ID, plan, tasks, reason); err != nil {
+		return nil, fmt.Errorf("failed to retry video %d: %w", video.ID, err)
+	}
+	return plan, nil
+}
+
+// RetryVideo loads the video id with its scenes and retries it like Retry
+func (r *Retrier) RetryVideo(ctx context.Context, id int, reason string) (*RetryPlan, error) {
+	video, err := r.repo.GetVideoByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get video %d: %w", id, err)
+	}
+	return r.Retry(ctx, video, reason)
+}
+
+// planRetry inspects the artifacts that already exist on a video and returns
+// the first missing step of each pipeline branch, so already-paid work is reused.
+//
+// The pipeline is script -> (audio -> captions) + (scenes -> scene images) -> render,
+// where the audio and scene branches run in parallel.
+func planRetry(video *Video) *RetryPlan {
+	if isBlank(video.Script) {
+		return &RetryPlan{
+			Status:    VideoStatusPending,
+			TaskTypes: []string{queue.TypeGenerateVideoScript},
+		}
+	}
+
+	plan := &RetryPlan{}
+
+	// Audio branch: audio generation enqueues captions itself
+	switch {
+	case isBlank(video.AudioKey) && isBlank(video.AudioURL):
+		plan.TaskTypes = append(plan.TaskTypes, queue.TypeGenerateAudio)
+		plan.Status = VideoStatusGeneratingAudio
+	case isBlank(video.Captions):
+		plan.TaskTypes = append(plan.TaskTypes, queue.TypeGenerateCaptions)
+		plan.Status = VideoStatusGeneratingImages
+	}
+
+	// Scene branch: scene generation enqueues one image task per scene
+	if len(video.Scenes) == 0 {
+		plan.TaskTypes = append(plan.TaskTypes, queue.TypeGenerateScenes)
+		if plan.Status == "" {
+			plan.Status = VideoStatusGeneratingScenes
+		}
+	} else {
+		for _, scene := range video.Scenes {
+			switch {
+			case isBlank(scene.ImageKey) && isBlank(scene.ImageURL):
+				plan.SceneIDs = append(plan.SceneIDs, scene.ID)
+			case scene.Status != models.SceneStatusCompleted:
+				plan.CompletedSceneIDs = append(plan.CompletedSceneIDs, scene.ID)
+			}
+		}
+		if len(plan.SceneIDs) > 0 {
+			plan.TaskTypes = append(plan.TaskTypes, queue.TypeGenerateSceneImage)
+		}
+		// Scenes already exist, so the scene branch is past "generating_images",
+		// the status the worker waits for before it renders
+		if len(plan.TaskTypes) > 0 {
+			plan.Status = VideoStatusGeneratingImages
+		}
+	}
+
+	if !plan.Empty() {
+		return plan
+	}
+
+	// Every input exists, only the final render is missing
+	if isBlank(video.VideoURL) {
+		return &RetryPlan{
+			Status:            VideoStatusReadyToRender,
+			TaskTypes:         []string{queue.TypeRenderVideo},
+			CompletedSceneIDs: plan.CompletedSceneIDs,
+		}
+	}
+
+	return plan
+}
+
+// retryTasks builds the payloads of the tasks plan enqueues for video. The
+// render payload carries media URLs signed with signer.
+func retryTasks(ctx context.Context, signer MediaSigner, video *Video, plan *RetryPlan) ([]queue.Payload, error) {
+	var tasks []queue.Payload
+	for _, taskType := range plan.TaskTypes {
+		switch taskType {
+		case queue.TypeGenerateVideoScript:
+			tasks = append(tasks, queue.GenerateVideoScriptPayload{VideoID: video.ID})
+		case queue.TypeGenerateAudio:
+			tasks = append(tasks, queue.GenerateAudioPayload{VideoID: video.ID})
+		case queue.TypeGenerateCaptions:
+			tasks = append(tasks, queue.GenerateCaptionsPayload{VideoID: video.ID})
+		case queue.TypeGenerateScenes:
+			tasks = append(tasks, queue.GenerateScenesPayload{VideoID: video.ID})
+		case queue.TypeGenerateSceneImage:
+			for _, sceneID := range plan.SceneIDs {
+				tasks = append(tasks, queue.GenerateSceneImagePayload{SceneID: sceneID})
+			}
+		case queue.TypeRenderVideo:
+			payload, err := renderPayload(ctx, signer, video)
+			if err != nil {
+				return nil, err
+			}
+			tasks = append(tasks, payload)
+		default:
+			return nil, fmt.Errorf("unknown task type %s", taskType)
+		}
+	}
+	return tasks, nil
+}
+
+func isBlank(s *string) bool {
+	return s == nil || *s == ""
+}
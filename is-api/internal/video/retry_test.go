@@ -0,0 +1,171 @@
+package video
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func TestPlanRetry(t *testing.T) {
+	doneScene := VideoScene{ID: 1, Status: models.SceneStatusCompleted, ImageURL: strPtr("https://example.com/1.png")}
+	missingScene := VideoScene{ID: 2}
+
+	tests := []struct {
+		name  string
+		video Video
+		want  RetryPlan
+	}{
+		{
+			name:  "missing script restarts from the beginning",
+			video: Video{AudioURL: strPtr("https://example.com/a.mp3")},
+			want:  RetryPlan{Status: VideoStatusPending, TaskTypes: []string{queue.TypeGenerateVideoScript}},
+		},
+		{
+			name:  "missing audio and scenes resumes both branches",
+			video: Video{Script: strPtr("script")},
+			want: RetryPlan{
+				Status:    VideoStatusGeneratingAudio,
+				TaskTypes: []string{queue.TypeGenerateAudio, queue.TypeGenerateScenes},
+			},
+		},
+		{
+			name: "missing audio with existing scenes waits on images",
+			video: Video{
+				Script: strPtr("script"),
+				Scenes: []VideoScene{doneScene},
+			},
+			want: RetryPlan{Status: VideoStatusGeneratingImages, TaskTypes: []string{queue.TypeGenerateAudio}},
+		},
+		{
+			name: "missing captions reuses audio",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioURL: strPtr("https://example.com/a.mp3"),
+				Scenes:   []VideoScene{doneScene},
+			},
+			want: RetryPlan{Status: VideoStatusGeneratingImages, TaskTypes: []string{queue.TypeGenerateCaptions}},
+		},
+		{
+			name: "only scenes without images are regenerated",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioURL: strPtr("https://example.com/a.mp3"),
+				Captions: strPtr("[]"),
+				Scenes:   []VideoScene{doneScene, missingScene},
+			},
+			want: RetryPlan{
+				Status:    VideoStatusGeneratingImages,
+				TaskTypes: []string{queue.TypeGenerateSceneImage},
+				SceneIDs:  []int{2},
+			},
+		},
+		{
+			name: "all artifacts present only renders",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioURL: strPtr("https://example.com/a.mp3"),
+				Captions: strPtr("[]"),
+				Scenes:   []VideoScene{doneScene},
+			},
+			want: RetryPlan{Status: VideoStatusReadyToRender, TaskTypes: []string{queue.TypeRenderVideo}},
+		},
+		{
+			name: "stored keys count as existing artifacts",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1/1.mp3"),
+				Captions: strPtr("[]"),
+				Scenes:   []VideoScene{{ID: 3, Status: models.SceneStatusCompleted, ImageKey: strPtr("images/1/scene_0.png")}},
+			},
+			want: RetryPlan{Status: VideoStatusReadyToRender, TaskTypes: []string{queue.TypeRenderVideo}},
+		},
+		{
+			name: "saved images of unfinished scenes are kept and completed",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1/1.mp3"),
+				Captions: strPtr("[]"),
+				Scenes: []VideoScene{
+					doneScene,
+					{ID: 4, Status: models.SceneStatusFailed, ImageKey: strPtr("images/1/scene_1.png")},
+				},
+			},
+			want: RetryPlan{
+				Status:            VideoStatusReadyToRender,
+				TaskTypes:         []string{queue.TypeRenderVideo},
+				CompletedSceneIDs: []int{4},
+			},
+		},
+		{
+			name: "unfinished scenes are completed alongside regenerated ones",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioKey: strPtr("audio/1/1.mp3"),
+				Captions: strPtr("[]"),
+				Scenes: []VideoScene{
+					missingScene,
+					{ID: 4, Status: models.SceneStatusGenerating, ImageKey: strPtr("images/1/scene_1.png")},
+				},
+			},
+			want: RetryPlan{
+				Status:            VideoStatusGeneratingImages,
+				TaskTypes:         []string{queue.TypeGenerateSceneImage},
+				SceneIDs:          []int{2},
+				CompletedSceneIDs: []int{4},
+			},
+		},
+		{
+			name: "rendered video has nothing to retry",
+			video: Video{
+				Script:   strPtr("script"),
+				AudioURL: strPtr("https://example.com/a.mp3"),
+				Captions: strPtr("[]"),
+				VideoURL: strPtr("https://example.com/v.mp4"),
+				Scenes:   []VideoScene{doneScene},
+			},
+			want: RetryPlan{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planRetry(&tt.video)
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("planRetry() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryTasks(t *testing.T) {
+	video := &Video{ID: 7, Scenes: []VideoScene{{ID: 3}, {ID: 4}}}
+	plan := &RetryPlan{
+		Status:    VideoStatusGeneratingImages,
+		TaskTypes: []string{queue.TypeGenerateCaptions, queue.TypeGenerateSceneImage},
+		SceneIDs:  []int{3, 4},
+	}
+
+	tasks, err := retryTasks(context.Background(), fakeSigner{}, video, plan)
+	if err != nil {
+		t.Fatalf("retryTasks returned error: %v", err)
+	}
+	want := []queue.Payload{
+		queue.GenerateCaptionsPayload{VideoID: 7},
+		queue.GenerateSceneImagePayload{SceneID: 3},
+		queue.GenerateSceneImagePayload{SceneID: 4},
+	}
+	if !reflect.DeepEqual(tasks, want) {
+		t.Errorf("retryTasks() = %+v, want %+v", tasks, want)
+	}
+
+	if _, err := retryTasks(context.Background(), fakeSigner{}, video, &RetryPlan{TaskTypes: []string{"video:unknown"}}); err == nil {
+		t.Error("Expected an unknown task type to be rejected")
+	}
+}
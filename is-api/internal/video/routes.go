@@ -16,6 +16,13 @@ func RegisterRoutes(router *gin.RouterGroup, handler *Handler, authRepo *auth.Re
 		videos.GET("", handler.GetMyVideos)
 		videos.GET("/:id", handler.GetVideo)
 		videos.GET("/:id/status", handler.GetVideoStatus)
+		videos.GET("/:id/timeline", handler.GetVideoTimeline)
+		videos.GET("/:id/events", handler.StreamVideoEvents)
+		videos.POST("/:id/retry", handler.RetryVideo)
+		videos.POST("/:id/cancel", handler.CancelVideo)
 		videos.DELETE("/:id", handler.DeleteVideo)
 	}
+
+	// Live events of all the user's videos
+	router.GET("/events", auth.RequireAuth(authRepo), handler.StreamEvents)
 }
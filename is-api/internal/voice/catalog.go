@@ -0,0 +1,71 @@
+package voice
+
+import (
+	"context"
+	"os"
+	"strings"
+)
+
+// DefaultVoiceID is the ElevenLabs voice used before voices were selectable
+const DefaultVoiceID = "NNl6r8mD7vthiJatiJt1"
+
+// Catalog validates voice IDs against the synced voices table. Until the
+// worker has synced the table at least once it falls back to a static list.
+type Catalog struct {
+	repo     *Repository
+	fallback *StaticCatalog
+}
+
+// NewCatalog creates a catalog backed by the voices table
+func NewCatalog(repo *Repository, fallback *StaticCatalog) *Catalog {
+	return &Catalog{repo: repo, fallback: fallback}
+}
+
+// HasVoice reports whether the voice ID can be used for new videos
+func (c *Catalog) HasVoice(ctx context.Context, voiceID string) (bool, error) {
+	found, err := c.repo.HasVoice(ctx, voiceID)
+	if err != nil || found {
+		return found, err
+	}
+
+	count, err := c.repo.CountVoices(ctx)
+	if err != nil {
+		return false, err
+	}
+	if count == 0 {
+		return c.fallback.HasVoice(ctx, voiceID)
+	}
+	return false, nil
+}
+
+// StaticCatalog is a fixed set of voice IDs that videos may be created with
+type StaticCatalog struct {
+	voiceIDs map[string]struct{}
+}
+
+// NewStaticCatalog creates a catalog containing the given voice IDs
+func NewStaticCatalog(voiceIDs ...string) *StaticCatalog {
+	catalog := &StaticCatalog{voiceIDs: make(map[string]struct{}, len(voiceIDs))}
+	for _, id := range voiceIDs {
+		if id = strings.TrimSpace(id); id != "" {
+			catalog.voiceIDs[id] = struct{}{}
+		}
+	}
+	return catalog
+}
+
+// NewStaticCatalogFromEnv creates a catalog from the comma-separated
+// ELEVENLABS_VOICE_IDS environment variable, falling back to DefaultVoiceID
+func NewStaticCatalogFromEnv() *StaticCatalog {
+	ids := os.Getenv("ELEVENLABS_VOICE_IDS")
+	if ids == "" {
+		return NewStaticCatalog(DefaultVoiceID)
+	}
+	return NewStaticCatalog(strings.Split(ids, ",")...)
+}
+
+// HasVoice reports whether the voice ID is in the catalog
+func (c *StaticCatalog) HasVoice(ctx context.Context, voiceID string) (bool, error) {
+	_, ok := c.voiceIDs[voiceID]
+	return ok, nil
+}
@@ -0,0 +1,33 @@
+package voice
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Handler struct {
+	repo *Repository
+}
+
+func NewHandler(repo *Repository) *Handler {
+	return &Handler{repo: repo}
+}
+
+// ListVoices returns the voices that can be used to create videos
+func (h *Handler) ListVoices(c *gin.Context) {
+	var filter ListVoicesFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	voices, err := h.repo.ListVoices(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve voices"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"voices": voices})
+}
@@ -0,0 +1,32 @@
+package voice
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Voice represents an ElevenLabs voice in the catalog
+type Voice struct {
+	ID          int            `json:"-" gorm:"primaryKey"`
+	VoiceID     string         `json:"voice_id" gorm:"uniqueIndex;not null"`
+	Name        string         `json:"name" gorm:"not null"`
+	Category    *string        `json:"category,omitempty"`
+	Language    *string        `json:"language,omitempty" gorm:"index"`
+	Gender      *string        `json:"gender,omitempty"`
+	Accent      *string        `json:"accent,omitempty"`
+	Age         *string        `json:"age,omitempty"`
+	Description *string        `json:"description,omitempty" gorm:"type:text"`
+	PreviewURL  *string        `json:"preview_url,omitempty" gorm:"type:text"`
+	SyncedAt    time.Time      `json:"synced_at"`
+	CreatedAt   time.Time      `json:"-"`
+	UpdatedAt   time.Time      `json:"-"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// ListVoicesFilter narrows the voice list
+type ListVoicesFilter struct {
+	Language string `form:"language"`
+	Gender   string `form:"gender"`
+	Accent   string `form:"accent"`
+}
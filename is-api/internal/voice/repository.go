@@ -0,0 +1,50 @@
+package voice
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ListVoices retrieves the voices in the catalog matching the filter
+func (r *Repository) ListVoices(ctx context.Context, filter ListVoicesFilter) ([]Voice, error) {
+	query := r.db.WithContext(ctx)
+	if filter.Language != "" {
+		query = query.Where("language = ?", filter.Language)
+	}
+	if filter.Gender != "" {
+		query = query.Where("gender = ?", filter.Gender)
+	}
+	if filter.Accent != "" {
+		query = query.Where("accent = ?", filter.Accent)
+	}
+
+	var voices []Voice
+	err := query.Order("name ASC").Find(&voices).Error
+	return voices, err
+}
+
+// CountVoices returns the number of voices in the catalog
+func (r *Repository) CountVoices(ctx context.Context) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&Voice{}).Count(&count).Error
+	return count, err
+}
+
+// HasVoice reports whether the voice ID is in the catalog
+func (r *Repository) HasVoice(ctx context.Context, voiceID string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&Voice{}).
+		Where("voice_id = ?", voiceID).
+		Count(&count).Error
+	return count > 0, err
+}
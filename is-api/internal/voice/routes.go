@@ -0,0 +1,16 @@
+package voice
+
+import (
+	"instashorts-be/is-api/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes registers all voice routes
+func RegisterRoutes(router *gin.RouterGroup, handler *Handler, authRepo *auth.Repository) {
+	voices := router.Group("/voices")
+	voices.Use(auth.RequireAuth(authRepo))
+	{
+		voices.GET("", handler.ListVoices)
+	}
+}
@@ -0,0 +1,5 @@
+-- Drop video_pipeline_steps table and indexes
+DROP INDEX IF EXISTS idx_video_pipeline_steps_outcome;
+DROP INDEX IF EXISTS idx_video_pipeline_steps_step;
+DROP INDEX IF EXISTS idx_video_pipeline_steps_video_id;
+DROP TABLE IF EXISTS video_pipeline_steps;
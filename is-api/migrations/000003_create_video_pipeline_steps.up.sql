@@ -0,0 +1,20 @@
+-- Create video_pipeline_steps table
+-- One row per attempt of a pipeline step (task), written by the worker
+CREATE TABLE IF NOT EXISTS video_pipeline_steps (
+    id SERIAL PRIMARY KEY,
+    video_id INTEGER NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    scene_id INTEGER REFERENCES video_scenes(id) ON DELETE CASCADE,
+    step VARCHAR(100) NOT NULL,
+    attempt INTEGER NOT NULL DEFAULT 1,
+    task_id VARCHAR(255),
+    outcome VARCHAR(50) NOT NULL DEFAULT 'running',
+    error TEXT,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Create indexes for video_pipeline_steps
+CREATE INDEX idx_video_pipeline_steps_video_id ON video_pipeline_steps(video_id);
+CREATE INDEX idx_video_pipeline_steps_step ON video_pipeline_steps(step);
+CREATE INDEX idx_video_pipeline_steps_outcome ON video_pipeline_steps(outcome);
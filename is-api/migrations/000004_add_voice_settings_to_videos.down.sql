@@ -0,0 +1,2 @@
+-- Drop voice_settings column from videos
+ALTER TABLE videos DROP COLUMN IF EXISTS voice_settings;
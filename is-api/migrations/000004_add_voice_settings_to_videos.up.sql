@@ -0,0 +1,3 @@
+-- Per-video ElevenLabs voice settings (stability, similarity_boost, style, use_speaker_boost)
+-- NULL means the worker's defaults are used
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS voice_settings JSONB;
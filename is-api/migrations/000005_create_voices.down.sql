@@ -0,0 +1,4 @@
+-- Drop voices table and indexes
+DROP INDEX IF EXISTS idx_voices_deleted_at;
+DROP INDEX IF EXISTS idx_voices_language;
+DROP TABLE IF EXISTS voices;
@@ -0,0 +1,22 @@
+-- Create voices table
+-- Catalog of ElevenLabs voices, synced periodically by the worker (voices:sync)
+CREATE TABLE IF NOT EXISTS voices (
+    id SERIAL PRIMARY KEY,
+    voice_id VARCHAR(255) UNIQUE NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    category VARCHAR(50),
+    language VARCHAR(50),
+    gender VARCHAR(50),
+    accent VARCHAR(100),
+    age VARCHAR(50),
+    description TEXT,
+    preview_url TEXT,
+    synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    deleted_at TIMESTAMP WITH TIME ZONE
+);
+
+-- Create indexes for voices
+CREATE INDEX idx_voices_language ON voices(language);
+CREATE INDEX idx_voices_deleted_at ON voices(deleted_at);
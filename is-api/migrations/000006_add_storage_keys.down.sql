@@ -0,0 +1,6 @@
+-- Drop storage keys from videos and video_scenes
+ALTER TABLE video_scenes DROP COLUMN IF EXISTS image_key;
+ALTER TABLE video_scenes DROP COLUMN IF EXISTS image_bucket;
+
+ALTER TABLE videos DROP COLUMN IF EXISTS audio_key;
+ALTER TABLE videos DROP COLUMN IF EXISTS audio_bucket;
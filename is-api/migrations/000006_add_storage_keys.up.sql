@@ -0,0 +1,8 @@
+-- Store where generated media lives instead of short-lived signed URLs.
+-- URLs are signed when a video is read; audio_url and image_url remain for
+-- rows written before this migration.
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS audio_bucket VARCHAR(255);
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS audio_key TEXT;
+
+ALTER TABLE video_scenes ADD COLUMN IF NOT EXISTS image_bucket VARCHAR(255);
+ALTER TABLE video_scenes ADD COLUMN IF NOT EXISTS image_key TEXT;
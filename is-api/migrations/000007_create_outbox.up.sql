@@ -0,0 +1,17 @@
+-- Tasks written in the same transaction as the state change that calls for
+-- them. The worker's outbox relay enqueues them to asynq and marks them
+-- dispatched.
+CREATE TABLE IF NOT EXISTS outbox (
+    id BIGSERIAL PRIMARY KEY,
+    task_type VARCHAR(100) NOT NULL,
+    payload BYTEA NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    available_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    dispatched_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The relay only ever scans tasks that are still waiting
+CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(available_at, id) WHERE dispatched_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_outbox_dispatched_at ON outbox(dispatched_at) WHERE dispatched_at IS NOT NULL;
@@ -0,0 +1,2 @@
+-- Drop failure_reason from videos
+ALTER TABLE videos DROP COLUMN IF EXISTS failure_reason;
@@ -0,0 +1,2 @@
+-- Why a video was failed by the stuck-video reaper, shown until it is retried
+ALTER TABLE videos ADD COLUMN IF NOT EXISTS failure_reason TEXT;
@@ -0,0 +1,2 @@
+-- Drop the one-scene-per-index constraint; removed duplicates aren't restored
+ALTER TABLE video_scenes DROP CONSTRAINT IF EXISTS video_scenes_video_id_index_key;
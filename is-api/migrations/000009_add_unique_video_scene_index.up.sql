@@ -0,0 +1,16 @@
+-- A video has one scene per index, so a redelivered scenes task can't insert
+-- the scenes, and pay for their images, twice.
+-- Keep a completed copy of each duplicated scene, else the oldest.
+DELETE FROM video_scenes
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, ROW_NUMBER() OVER (
+            PARTITION BY video_id, index
+            ORDER BY (status = 'completed') DESC, id
+        ) AS rn
+        FROM video_scenes
+    ) ranked
+    WHERE rn > 1
+);
+
+ALTER TABLE video_scenes ADD CONSTRAINT video_scenes_video_id_index_key UNIQUE (video_id, index);
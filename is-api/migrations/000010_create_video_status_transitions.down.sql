@@ -0,0 +1,2 @@
+-- Drop video_status_transitions table
+DROP TABLE IF EXISTS video_status_transitions;
@@ -0,0 +1,26 @@
+-- Create video_status_transitions table
+-- One row per status change of a video, with the reason it moved
+CREATE TABLE IF NOT EXISTS video_status_transitions (
+    id SERIAL PRIMARY KEY,
+    video_id INTEGER NOT NULL REFERENCES videos(id) ON DELETE CASCADE,
+    from_status VARCHAR(50) NOT NULL,
+    to_status VARCHAR(50) NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_video_status_transitions_video_id ON video_status_transitions(video_id);
+
+-- The script and audio steps used to mark in-flight videos completed before
+-- they were rendered. Nothing is queued for them any more, so fail them with a
+-- reason and let the user retry them from their first missing step.
+WITH stranded AS (
+    UPDATE videos
+    SET status = 'failed',
+        failure_reason = 'marked completed before it was rendered',
+        updated_at = CURRENT_TIMESTAMP
+    WHERE status = 'completed' AND (video_url IS NULL OR video_url = '')
+    RETURNING id, failure_reason
+)
+INSERT INTO video_status_transitions (video_id, from_status, to_status, reason)
+SELECT id, 'completed', 'failed', failure_reason FROM stranded;
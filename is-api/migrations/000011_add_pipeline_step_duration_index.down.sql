@@ -0,0 +1,2 @@
+-- Drop the index on recent successful pipeline steps
+DROP INDEX IF EXISTS idx_video_pipeline_steps_succeeded_step_finished_at;
@@ -0,0 +1,5 @@
+-- The status endpoint averages the most recent successful attempts of each
+-- step; this serves them without scanning the whole step history
+CREATE INDEX IF NOT EXISTS idx_video_pipeline_steps_succeeded_step_finished_at
+    ON video_pipeline_steps(step, finished_at DESC)
+    WHERE outcome = 'succeeded';
@@ -1,5 +1,6 @@
 import pg from 'pg';
 import dotenv from 'dotenv';
+import { publishVideoStatusEvent } from '../queue/client.js';
 
 dotenv.config();
 
@@ -63,20 +64,63 @@ export async function fetchVideoScenes(videoId: number): Promise<VideoScene[]> {
   return result.rows;
 }
 
-export async function updateVideoStatus(videoId: number, status: string): Promise<void> {
-  const db = getDatabasePool();
-  await db.query('UPDATE videos SET status = $1, updated_at = NOW() WHERE id = $2', [
-    status,
-    videoId,
-  ]);
-}
+// The moves the renderer makes, a subset of the transition table in
+// pkg/models/status.go that the Go services enforce
+const renderTransitions: Record<string, string[]> = {
+  ready_to_render: ['rendering', 'failed'],
+  rendering: ['completed', 'failed'],
+};
+
+// Moves a video from one status to another with the same compare-and-swap
+// UPDATE as the worker, recording the transition and its reason and
+// publishing it for the API's event streams. Failing sets the failure reason,
+// and completing records the video URL. Returns false when the video is no
+// longer in `from`; a video already in `to` counts as moved, so a redelivered
+// task can make the same move again.
+export async function transitionVideoStatus(
+  videoId: number,
+  from: string,
+  to: string,
+  reason: string,
+  videoUrl?: string
+): Promise<boolean> {
+  if (!renderTransitions[from]?.includes(to)) {
+    throw new Error(`Illegal video status transition: ${from} -> ${to}`);
+  }
 
-export async function updateVideoUrl(videoId: number, videoUrl: string): Promise<void> {
   const db = getDatabasePool();
-  await db.query(
-    'UPDATE videos SET video_url = $1, status = $2, updated_at = NOW() WHERE id = $3',
-    [videoUrl, 'completed', videoId]
+  const result = await db.query(
+    `WITH moved AS (
+       UPDATE videos
+       SET status = $3::varchar,
+           failure_reason = CASE WHEN $3::varchar = 'failed' THEN $4::text ELSE failure_reason END,
+           video_url = COALESCE($5::text, video_url),
+           completed_at = CASE WHEN $3::varchar = 'completed' THEN NOW() ELSE completed_at END,
+           updated_at = NOW()
+       WHERE id = $1 AND status = $2::varchar AND deleted_at IS NULL
+       RETURNING id, user_id
+     ),
+     recorded AS (
+       INSERT INTO video_status_transitions (video_id, from_status, to_status, reason, created_at)
+       SELECT id, $2::varchar, $3::varchar, $4::text, NOW() FROM moved
+     )
+     SELECT user_id FROM moved`,
+    [videoId, from, to, reason, videoUrl ?? null]
   );
+  if (result.rowCount === 1) {
+    await publishVideoStatusEvent({
+      type: 'status',
+      user_id: result.rows[0].user_id,
+      video_id: videoId,
+      status: to,
+      reason,
+      at: new Date().toISOString(),
+    });
+    return true;
+  }
+
+  const current = await fetchVideoData(videoId);
+  return current?.status === to;
 }
 
 export async function closeDatabase(): Promise<void> {
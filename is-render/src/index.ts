@@ -1,25 +1,29 @@
 import dotenv from 'dotenv';
-import { listenForRenderVideoTasks, enqueueVideoComplete, closeRedis } from './queue/client.js';
+import { listenForRenderVideoTasks, enqueueVideoComplete, closeRedis, type RenderVideoPayload } from './queue/client.js';
 import {
   fetchVideoData,
   fetchVideoScenes,
-  updateVideoStatus,
-  updateVideoUrl,
+  transitionVideoStatus,
   closeDatabase,
   type Caption,
 } from './database/client.js';
 import { renderVideo } from './renderer/video-renderer.js';
+import { startRenderServer } from './server.js';
 
 dotenv.config();
 
-async function handleRenderVideoTask(payload: { video_id: number }): Promise<void> {
+async function handleRenderVideoTask(payload: RenderVideoPayload): Promise<void> {
   const { video_id } = payload;
+  const traceId = payload._meta?.trace_id;
   
-  console.log(`\n=== Processing render_video task for video_id: ${video_id} ===`);
+  console.log(`\n=== Processing render_video task for video_id: ${video_id}${traceId ? ` (trace ${traceId})` : ''} ===`);
   
   try {
-    // Update status to rendering
-    await updateVideoStatus(video_id, 'rendering');
+    // Only render a video that is still waiting for it; a cancelled or failed one stays as is
+    if (!(await transitionVideoStatus(video_id, 'ready_to_render', 'rendering', 'render started'))) {
+      console.log(`Video ${video_id} is no longer ready to render, skipping`);
+      return;
+    }
     
     // Fetch video data from database
     const video = await fetchVideoData(video_id);
@@ -27,8 +31,10 @@ async function handleRenderVideoTask(payload: { video_id: number }): Promise<voi
       throw new Error(`Video not found: ${video_id}`);
     }
     
-    if (!video.audio_url) {
-      throw new Error(`Video has no audio_url: ${video_id}`);
+    // Prefer the signed URLs in the task; older videos have theirs in the database
+    const audioUrl = payload.audio_url ?? video.audio_url;
+    if (!audioUrl) {
+      throw new Error(`Video has no audio URL: ${video_id}`);
     }
     
     if (!video.captions) {
@@ -44,7 +50,11 @@ async function handleRenderVideoTask(payload: { video_id: number }): Promise<voi
     }
     
     // Fetch scenes
-    const scenes = await fetchVideoScenes(video_id);
+    const imageUrls = new Map((payload.scene_images ?? []).map((i) => [i.scene_id, i.image_url]));
+    const scenes = (await fetchVideoScenes(video_id)).map((s) => ({
+      ...s,
+      image_url: imageUrls.get(s.id) ?? s.image_url,
+    }));
     if (scenes.length === 0) {
       throw new Error(`No scenes found for video: ${video_id}`);
     }
@@ -62,18 +72,28 @@ async function handleRenderVideoTask(payload: { video_id: number }): Promise<voi
       videoId: video_id,
       scenes,
       captions,
-      audioUrl: video.audio_url,
+      audioUrl,
     });
     
     console.log(`Video rendered successfully: ${videoUrl}`);
     
     // Update database with video URL
-    await updateVideoUrl(video_id, videoUrl);
+    if (!(await transitionVideoStatus(video_id, 'rendering', 'completed', 'video rendered', videoUrl))) {
+      console.log(`Video ${video_id} changed while it rendered, dropping ${videoUrl}`);
+      return;
+    }
     
     // Enqueue video_complete task
+    // Continue the render's trace so the worker can follow the video end to end
     await enqueueVideoComplete({
       video_id,
       video_url: videoUrl,
+      _meta: {
+        v: 2,
+        trace_id: traceId,
+        enqueued_at: new Date().toISOString(),
+        user_id: payload._meta?.user_id,
+      },
     });
     
     console.log(`✓ Successfully completed render for video_id: ${video_id}`);
@@ -82,7 +102,8 @@ async function handleRenderVideoTask(payload: { video_id: number }): Promise<voi
     
     // Update status to failed
     try {
-      await updateVideoStatus(video_id, 'failed');
+      const reason = error instanceof Error ? error.message : String(error);
+      await transitionVideoStatus(video_id, 'rendering', 'failed', reason);
     } catch (updateErr) {
       console.error('Failed to update video status to failed:', updateErr);
     }
@@ -91,7 +112,6 @@ async function handleRenderVideoTask(payload: { video_id: number }): Promise<voi
 
 async function main() {
   console.log('Starting Instashorts Renderer...');
-  console.log('Listening for render_video tasks...');
   
   // Set up graceful shutdown
   const shutdown = async () => {
@@ -104,7 +124,15 @@ async function main() {
   process.on('SIGINT', shutdown);
   process.on('SIGTERM', shutdown);
   
+  // In HTTP mode the Go worker owns video:render and calls us directly
+  const httpPort = process.env.RENDER_HTTP_PORT;
+  if (httpPort) {
+    startRenderServer(parseInt(httpPort));
+    return;
+  }
+  
   // Start listening for tasks
+  console.log('Listening for render_video tasks...');
   try {
     await listenForRenderVideoTasks(handleRenderVideoTask);
   } catch (error) {
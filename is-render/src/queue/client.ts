@@ -10,17 +10,50 @@ export const TASK_TYPES = {
   VIDEO_COMPLETE: 'video:complete',
 } as const;
 
+// Task metadata written by pkg/queue next to the payload fields.
+// Payloads without it are version 1; see pkg/queue/envelope.go
+export interface TaskMeta {
+  v: number;
+  trace_id?: string;
+  enqueued_at: string;
+  user_id?: number;
+}
+
+// The JSON shape is pinned by pkg/queue/testdata/contracts
+// Media URLs are signed by the worker, since the renderer can't sign them;
+// tasks queued before they were added carry none
 export interface RenderVideoPayload {
   video_id: number;
+  audio_url?: string;
+  scene_images?: RenderSceneImage[];
+  _meta?: TaskMeta;
+}
+
+export interface RenderSceneImage {
+  scene_id: number;
+  image_url: string;
 }
 
 export interface VideoCompletePayload {
   video_id: number;
   video_url: string;
+  _meta?: TaskMeta;
+}
+
+// A video status change, published on the channel of the video's owner for
+// the API's live event streams. The JSON shape matches pkg/events.Event.
+export interface VideoStatusEvent {
+  type: 'status';
+  user_id: number;
+  video_id: number;
+  status: string;
+  reason: string;
+  at: string;
 }
 
 let redisClient: Redis | null = null;
 let redisSubscriber: Redis | null = null;
+let redisPublisher: Redis | null = null;
 
 export function getRedisClient(): Redis {
   if (!redisClient) {
@@ -70,6 +103,38 @@ export function getRedisSubscriber(): Redis {
   return redisSubscriber;
 }
 
+// The task listener blocks its connection in BLPOP, so events are published
+// on a connection of their own
+function getRedisPublisher(): Redis {
+  if (!redisPublisher) {
+    const host = process.env.REDIS_HOST || 'localhost';
+    const port = parseInt(process.env.REDIS_PORT || '6379');
+    redisPublisher = new Redis({
+      host,
+      port,
+      retryStrategy: (times) => {
+        const delay = Math.min(times * 50, 2000);
+        return delay;
+      },
+    });
+
+    redisPublisher.on('error', (err) => {
+      console.error('Redis Publisher Error:', err);
+    });
+  }
+  return redisPublisher;
+}
+
+// Publishes a status change on its user's channel, named as in
+// pkg/events.Channel. Events are best-effort, so failures are only logged.
+export async function publishVideoStatusEvent(event: VideoStatusEvent): Promise<void> {
+  try {
+    await getRedisPublisher().publish(`instashorts:events:user:${event.user_id}`, JSON.stringify(event));
+  } catch (err) {
+    console.error(`Failed to publish status event for video_id: ${event.video_id}:`, err);
+  }
+}
+
 // asynq stores tasks in Redis with specific keys
 // Tasks are stored in sorted sets and lists with patterns like:
 // asynq:queues:{queue}:pending, asynq:queues:{queue}:active, etc.
@@ -174,4 +239,8 @@ export async function closeRedis(): Promise<void> {
     await redisSubscriber.quit();
     redisSubscriber = null;
   }
+  if (redisPublisher) {
+    await redisPublisher.quit();
+    redisPublisher = null;
+  }
 }
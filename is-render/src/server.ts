@@ -0,0 +1,107 @@
+import http from 'http';
+import { renderVideo } from './renderer/video-renderer.js';
+import type { Caption, VideoScene } from './database/client.js';
+
+// Request/response contract shared with the Go worker (render.RemotionLambdaRequest)
+export interface RenderRequest {
+  scenes: { image_url: string; index: number }[];
+  captions: Caption[];
+  audioUrl: string;
+  videoDuration: number;
+  videoId: number;
+}
+
+export interface RenderResponse {
+  success: boolean;
+  videoUrl?: string;
+  renderId?: string;
+  error?: string;
+}
+
+function sendJson(res: http.ServerResponse, status: number, body: RenderResponse): void {
+  res.writeHead(status, { 'Content-Type': 'application/json' });
+  res.end(JSON.stringify(body));
+}
+
+async function readBody(req: http.IncomingMessage): Promise<string> {
+  const chunks: Buffer[] = [];
+  for await (const chunk of req) {
+    chunks.push(chunk as Buffer);
+  }
+  return Buffer.concat(chunks).toString('utf-8');
+}
+
+async function handleRender(req: http.IncomingMessage, res: http.ServerResponse): Promise<void> {
+  const authToken = process.env.RENDERER_AUTH_TOKEN;
+  if (authToken && req.headers.authorization !== `Bearer ${authToken}`) {
+    sendJson(res, 401, { success: false, error: 'unauthorized' });
+    return;
+  }
+
+  let body: RenderRequest;
+  try {
+    body = JSON.parse(await readBody(req));
+  } catch (err) {
+    sendJson(res, 400, { success: false, error: `invalid JSON body: ${err}` });
+    return;
+  }
+
+  if (!body.videoId || !body.audioUrl || !body.scenes?.length) {
+    sendJson(res, 400, { success: false, error: 'videoId, audioUrl and scenes are required' });
+    return;
+  }
+
+  console.log(`\n=== Processing HTTP render request for video_id: ${body.videoId} ===`);
+
+  const scenes: VideoScene[] = body.scenes.map((s) => ({
+    id: s.index,
+    video_id: body.videoId,
+    image_url: s.image_url,
+    index: s.index,
+    prompt: '',
+    status: 'completed',
+  }));
+
+  try {
+    const videoUrl = await renderVideo({
+      videoId: body.videoId,
+      scenes,
+      captions: body.captions || [],
+      audioUrl: body.audioUrl,
+    });
+    console.log(`✓ Successfully rendered video_id: ${body.videoId}`);
+    sendJson(res, 200, { success: true, videoUrl });
+  } catch (err) {
+    console.error(`✗ Error rendering video_id ${body.videoId}:`, err);
+    sendJson(res, 500, { success: false, error: String(err) });
+  }
+}
+
+// startRenderServer serves POST /render for the Go worker's HTTP renderer
+export function startRenderServer(port: number): http.Server {
+  const server = http.createServer((req, res) => {
+    if (req.method === 'GET' && req.url === '/health') {
+      res.writeHead(200, { 'Content-Type': 'application/json' });
+      res.end(JSON.stringify({ status: 'ok', service: 'renderer' }));
+      return;
+    }
+
+    if (req.method === 'POST' && req.url === '/render') {
+      handleRender(req, res).catch((err) => {
+        console.error('Unhandled render error:', err);
+        sendJson(res, 500, { success: false, error: String(err) });
+      });
+      return;
+    }
+
+    res.writeHead(404);
+    res.end();
+  });
+
+  // Renders take minutes; don't let node drop the connection
+  server.requestTimeout = 0;
+  server.listen(port, () => {
+    console.log(`Renderer HTTP server listening on :${port}`);
+  });
+  return server;
+}
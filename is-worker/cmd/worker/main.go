@@ -1,20 +1,28 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/hibiken/asynq"
 	_ "github.com/joho/godotenv/autoload"
 
 	// Updated imports for monorepo
+	"instashorts-be/is-worker/internal/ai"
+	_ "instashorts-be/is-worker/internal/ai/gemini" // registers the gemini LLM and imagen image providers
 	"instashorts-be/is-worker/internal/handlers"
+	"instashorts-be/is-worker/internal/render"
 	"instashorts-be/pkg/database"
+	"instashorts-be/pkg/events"
 	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
 )
 
 func main() {
@@ -29,6 +37,11 @@ func main() {
 	db := database.New()
 	gormDB := db.GetDB()
 
+	// Status changes and pipeline steps are published for the API's live event streams
+	publisher := events.NewRedisPublisher(redisAddr)
+	defer publisher.Close()
+	videos := repository.WithEvents(repository.NewGormVideoStore(gormDB), publisher)
+
 	// Create asynq server
 	srv := asynq.NewServer(
 		asynq.RedisClientOpt{Addr: redisAddr},
@@ -37,25 +50,123 @@ func main() {
 			Concurrency: 10,
 			// Optionally specify multiple queues with different priority levels
 			Queues: map[string]int{
-				"critical": 6, // processed 60% of the time
-				"default":  3, // processed 30% of the time
-				"low":      1, // processed 10% of the time
+				queue.QueueCritical: 6, // processed 60% of the time
+				queue.QueueDefault:  3, // processed 30% of the time
+				queue.QueueLow:      1, // processed 10% of the time
 			},
+			// Rate-limited provider calls wait as long as the provider asked
+			RetryDelayFunc: handlers.RetryDelay,
 			// See the godoc for other configuration options
 		},
 	)
 
-	// Create mux to map task types to handlers
+	// Create the text-to-speech provider used for narration
+	ttsProvider := getEnvOrDefault("TTS_PROVIDER", ai.DefaultTTSProvider)
+	tts, err := ai.NewTextToSpeech(context.Background(), ttsProvider)
+	if err != nil {
+		log.Fatalf("could not create text-to-speech provider: %v", err)
+	}
+	log.Printf("Using %s text-to-speech provider", ttsProvider)
+
+	// Create the LLMs for the script and scene steps. Each step can use its own
+	// provider and model; both default to LLM_PROVIDER and the provider's models.
+	llmProvider := getEnvOrDefault("LLM_PROVIDER", ai.DefaultLLMProvider)
+	scriptLLMProvider := getEnvOrDefault("SCRIPT_LLM_PROVIDER", llmProvider)
+	scriptWriter, err := ai.NewLLM(context.Background(), scriptLLMProvider, os.Getenv("SCRIPT_LLM_MODEL"))
+	if err != nil {
+		log.Fatalf("could not create script LLM: %v", err)
+	}
+	sceneLLMProvider := getEnvOrDefault("SCENE_LLM_PROVIDER", llmProvider)
+	sceneDirector, err := ai.NewLLM(context.Background(), sceneLLMProvider, os.Getenv("SCENE_LLM_MODEL"))
+	if err != nil {
+		log.Fatalf("could not create scene LLM: %v", err)
+	}
+	log.Printf("Using %s LLM for scripts and %s LLM for scenes", scriptLLMProvider, sceneLLMProvider)
+
+	// Create the image providers for scene images, tried in order until one succeeds
+	images, err := ai.NewImageGeneratorChain(context.Background(), getEnvOrDefault("IMAGE_PROVIDERS", ai.DefaultImageProviders))
+	if err != nil {
+		log.Fatalf("could not create image providers: %v", err)
+	}
+	log.Printf("Using image providers: %s", strings.Join(images.Providers(), " -> "))
+
+	// Create the blob store that generated media is uploaded to
+	storageBackend := getEnvOrDefault("STORAGE_BACKEND", storage.DefaultBackend)
+	store, err := storage.NewBlobStore(context.Background(), storageBackend)
+	if err != nil {
+		log.Fatalf("could not create storage backend: %v", err)
+	}
+	log.Printf("Using %s storage backend", storageBackend)
+
+	// Media URLs handed to the renderer are signed for this long
+	signedURLTTL, err := storage.SignedURLTTLFromEnv()
+	if err != nil {
+		log.Fatalf("could not read signed URL TTL: %v", err)
+	}
+
+	// The outbox relay publishes tasks written alongside state changes
+	outboxInterval, err := queue.OutboxPollIntervalFromEnv()
+	if err != nil {
+		log.Fatalf("could not read outbox poll interval: %v", err)
+	}
+	relay := queue.NewOutboxRelay(gormDB, queue.GetClient(), outboxInterval, queue.DefaultOutboxRetention)
+
+	// The reaper compares stuck videos against the tasks asynq still holds for them
+	stuckTimeouts, err := handlers.ParseStuckVideoTimeouts(os.Getenv("STUCK_VIDEO_TIMEOUTS"))
+	if err != nil {
+		log.Fatalf("could not read stuck video timeouts: %v", err)
+	}
+	inspector := queue.NewInspector()
+	defer inspector.Close()
+
+	// Create mux to map task types to handlers; every task is logged, timed and
+	// recovered from panics, and marks its video failed once out of retries
 	mux := asynq.NewServeMux()
+	mux.Use(handlers.Middleware(videos)...)
 
 	// Register task handlers (using new 'handlers' package)
-	mux.HandleFunc(queue.TypeGenerateVideoScript, handlers.NewHandleGenerateVideoScript(gormDB))
-	mux.HandleFunc(queue.TypeGenerateAudio, handlers.NewHandleGenerateAudio(gormDB))
-	mux.HandleFunc(queue.TypeGenerateCaptions, handlers.NewHandleGenerateCaptions(gormDB))
-	mux.HandleFunc(queue.TypeGenerateScenes, handlers.NewHandleGenerateScenes(gormDB))
-	mux.HandleFunc(queue.TypeGenerateSceneImage, handlers.NewHandleGenerateSceneImage(gormDB))
-	// Note: TypeRenderVideo is now handled by the TypeScript renderer service
-	mux.HandleFunc(queue.TypeVideoComplete, handlers.NewHandleVideoComplete(gormDB))
+	mux.HandleFunc(queue.TypeGenerateVideoScript, handlers.NewHandleGenerateVideoScript(videos, scriptWriter))
+	mux.HandleFunc(queue.TypeGenerateAudio, handlers.NewHandleGenerateAudio(videos, tts, store))
+	mux.HandleFunc(queue.TypeGenerateCaptions, handlers.NewHandleGenerateCaptions(videos, store, signedURLTTL))
+	mux.HandleFunc(queue.TypeGenerateScenes, handlers.NewHandleGenerateScenes(videos, sceneDirector))
+	mux.HandleFunc(queue.TypeGenerateSceneImage, handlers.NewHandleGenerateSceneImage(videos, images, store, signedURLTTL))
+	// Render through an HTTP renderer when one is configured; otherwise the
+	// TypeScript renderer service consumes TypeRenderVideo directly from Redis
+	renderer, err := render.NewHTTPRenderer()
+	if err != nil {
+		log.Printf("Render handler disabled: %v", err)
+	} else {
+		mux.HandleFunc(queue.TypeRenderVideo, handlers.NewHandleRenderVideo(videos, renderer, store, signedURLTTL))
+	}
+	mux.HandleFunc(queue.TypeVideoComplete, handlers.NewHandleVideoComplete(videos))
+	mux.HandleFunc(queue.TypeSyncVoices, handlers.NewHandleSyncVoices(gormDB))
+	mux.HandleFunc(queue.TypeReapStuckVideos, handlers.NewHandleReapStuckVideos(videos, inspector, stuckTimeouts, store, signedURLTTL))
+
+	// Create scheduler for periodic tasks
+	scheduler := asynq.NewScheduler(asynq.RedisClientOpt{Addr: redisAddr}, nil)
+
+	// Keep the voice catalog in sync with ElevenLabs; other providers have no catalog to sync
+	syncVoices := ttsProvider == "elevenlabs"
+	if syncVoices {
+		voiceSyncSpec := getEnvOrDefault("VOICE_SYNC_SCHEDULE", "@every 6h")
+		task, err := queue.NewScheduledTask(queue.SyncVoicesPayload{})
+		if err != nil {
+			log.Fatalf("could not create voice sync task: %v", err)
+		}
+		if _, err := scheduler.Register(voiceSyncSpec, task); err != nil {
+			log.Fatalf("could not register voice sync task: %v", err)
+		}
+	}
+
+	// Recover or fail videos stuck in a non-terminal status
+	reaperSpec := getEnvOrDefault("REAPER_SCHEDULE", "@every 5m")
+	reaperTask, err := queue.NewScheduledTask(queue.ReapStuckVideosPayload{})
+	if err != nil {
+		log.Fatalf("could not create stuck video reaper task: %v", err)
+	}
+	if _, err := scheduler.Register(reaperSpec, reaperTask); err != nil {
+		log.Fatalf("could not register stuck video reaper: %v", err)
+	}
 
 	// Set up signal handling for graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -68,6 +179,13 @@ func main() {
 		w.Write([]byte(`{"status":"ok","service":"worker"}`))
 	})
 
+	// Serve the local storage backend's signed URLs next to the health check
+	if local, ok := store.(*storage.LocalStore); ok {
+		filesPath := strings.TrimSuffix(local.URLPath(), "/")
+		http.Handle(filesPath+"/", http.StripPrefix(filesPath, local.Handler()))
+		log.Printf("Serving local storage at %s/", filesPath)
+	}
+
 	go func() {
 		log.Printf("Worker health check server listening on :%s", healthPort)
 		if err := http.ListenAndServe(":"+healthPort, nil); err != nil {
@@ -75,6 +193,27 @@ func main() {
 		}
 	}()
 
+	// Sync the voice catalog once on startup so it isn't empty until the first tick
+	if syncVoices {
+		if _, err := queue.Enqueue(context.Background(), queue.GetClient(), queue.SyncVoicesPayload{}); err != nil {
+			log.Printf("Failed to enqueue initial voice sync: %v", err)
+		}
+	}
+
+	// Start scheduler (non-blocking)
+	if err := scheduler.Start(); err != nil {
+		log.Fatalf("could not start scheduler: %v", err)
+	}
+
+	// Relay outbox tasks until shutdown
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		log.Printf("Outbox relay polling every %s", outboxInterval)
+		relay.Run(relayCtx)
+	}()
+
 	// Run worker in a goroutine
 	go func() {
 		log.Printf("Worker connected to Redis at %s", redisAddr)
@@ -89,7 +228,10 @@ func main() {
 	log.Printf("Received signal: %v", sig)
 	log.Println("Shutting down worker gracefully...")
 
-	// Shutdown the server gracefully
+	// Shutdown the relay, scheduler and server gracefully
+	stopRelay()
+	<-relayDone
+	scheduler.Shutdown()
 	srv.Shutdown()
 
 	log.Println("Worker shutdown complete")
@@ -16,6 +16,12 @@ type ElevenLabsService struct {
 	httpClient *http.Client
 }
 
+func init() {
+	RegisterTextToSpeech("elevenlabs", func(ctx context.Context) (TextToSpeech, error) {
+		return NewElevenLabsService()
+	})
+}
+
 // NewElevenLabsService creates a new ElevenLabs service
 func NewElevenLabsService() (*ElevenLabsService, error) {
 	apiKey := os.Getenv("ELEVENLABS_API_KEY")
@@ -29,33 +35,65 @@ func NewElevenLabsService() (*ElevenLabsService, error) {
 	}, nil
 }
 
+// VoiceSettings controls how ElevenLabs renders a voice.
+// Nil fields fall back to DefaultVoiceSettings.
+type VoiceSettings struct {
+	Stability       *float64 `json:"stability,omitempty"`
+	SimilarityBoost *float64 `json:"similarity_boost,omitempty"`
+	Style           *float64 `json:"style,omitempty"`
+	UseSpeakerBoost *bool    `json:"use_speaker_boost,omitempty"`
+}
+
+// DefaultVoiceSettings returns the settings used when a video doesn't override them
+func DefaultVoiceSettings() VoiceSettings {
+	stability, similarityBoost, style, speakerBoost := 0.5, 0.75, 0.0, true
+	return VoiceSettings{
+		Stability:       &stability,
+		SimilarityBoost: &similarityBoost,
+		Style:           &style,
+		UseSpeakerBoost: &speakerBoost,
+	}
+}
+
+// WithDefaults fills every unset field from DefaultVoiceSettings
+func (v VoiceSettings) WithDefaults() VoiceSettings {
+	defaults := DefaultVoiceSettings()
+	if v.Stability == nil {
+		v.Stability = defaults.Stability
+	}
+	if v.SimilarityBoost == nil {
+		v.SimilarityBoost = defaults.SimilarityBoost
+	}
+	if v.Style == nil {
+		v.Style = defaults.Style
+	}
+	if v.UseSpeakerBoost == nil {
+		v.UseSpeakerBoost = defaults.UseSpeakerBoost
+	}
+	return v
+}
+
 // TextToSpeechRequest represents the request body for ElevenLabs text-to-speech API
 type TextToSpeechRequest struct {
-	Text          string                 `json:"text"`
-	ModelID       string                 `json:"model_id"`
-	VoiceSettings map[string]interface{} `json:"voice_settings,omitempty"`
+	Text          string        `json:"text"`
+	ModelID       string        `json:"model_id"`
+	VoiceSettings VoiceSettings `json:"voice_settings"`
 }
 
-// GenerateAudio generates audio from text using the specified voice ID
-// Returns the audio data as a byte slice
-func (s *ElevenLabsService) GenerateAudio(ctx context.Context, text string, voiceID string) ([]byte, error) {
+// GenerateAudio generates MP3 audio from text using the specified voice ID and settings
+func (s *ElevenLabsService) GenerateAudio(ctx context.Context, text string, voiceID string, settings VoiceSettings) (*Audio, error) {
 	if text == "" {
-		return nil, fmt.Errorf("text cannot be empty")
+		return nil, fmt.Errorf("text cannot be empty: %w", ErrInvalidInput)
 	}
 	if voiceID == "" {
-		return nil, fmt.Errorf("voiceID cannot be empty")
+		return nil, fmt.Errorf("voiceID cannot be empty: %w", ErrInvalidInput)
 	}
 
 	// Prepare request body
 	requestBody := TextToSpeechRequest{
-		Text:    text,
-		ModelID: "eleven_multilingual_v2", // or eleven_v3 or eleven_ttv_v3
-		VoiceSettings: map[string]interface{}{
-			"stability":         0.5,
-			"similarity_boost":  0.75,
-			"style":             0.0,
-			"use_speaker_boost": true,
-		},
+		Text:          text,
+		ModelID:       "eleven_multilingual_v2", // or eleven_v3 or eleven_ttv_v3
+		VoiceSettings: settings.WithDefaults(),
 	}
 
 	jsonBody, err := json.Marshal(requestBody)
@@ -64,7 +102,8 @@ func (s *ElevenLabsService) GenerateAudio(ctx context.Context, text string, voic
 	}
 
 	// Create HTTP request
-	url := fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s", voiceID)
+	// The stream endpoint sends audio as it is generated instead of after the whole script
+	url := fmt.Sprintf("https://api.elevenlabs.io/v1/text-to-speech/%s/stream", voiceID)
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -79,18 +118,94 @@ func (s *ElevenLabsService) GenerateAudio(ctx context.Context, text string, voic
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	// Check status code
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		return nil, NewAPIError("ElevenLabs", resp.StatusCode, resp.Header, string(body))
+	}
+
+	// Hand the response body to the caller, so the audio is streamed to storage
+	return &Audio{Body: resp.Body, ContentType: AudioContentTypeMP3}, nil
+}
+
+// Voice represents a voice available to the ElevenLabs account
+type Voice struct {
+	VoiceID     string
+	Name        string
+	Category    string
+	Language    string
+	Gender      string
+	Accent      string
+	Age         string
+	Description string
+	PreviewURL  string
+}
+
+// listVoicesResponse represents the response body of the ElevenLabs voices API
+type listVoicesResponse struct {
+	Voices []struct {
+		VoiceID    string            `json:"voice_id"`
+		Name       string            `json:"name"`
+		Category   string            `json:"category"`
+		Labels     map[string]string `json:"labels"`
+		PreviewURL string            `json:"preview_url"`
+		// Newer voices list the languages they were verified for
+		VerifiedLanguages []struct {
+			Language string `json:"language"`
+			Accent   string `json:"accent"`
+		} `json:"verified_languages"`
+	} `json:"voices"`
+}
+
+// ListVoices returns every voice available to the account
+func (s *ElevenLabsService) ListVoices(ctx context.Context) ([]Voice, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.elevenlabs.io/v1/voices", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	req.Header.Set("xi-api-key", s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
 
-	// Check status code
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("ElevenLabs API returned status %d: %s", resp.StatusCode, string(body))
+		body, _ := io.ReadAll(resp.Body)
+		return nil, NewAPIError("ElevenLabs", resp.StatusCode, resp.Header, string(body))
+	}
+
+	var body listVoicesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode voices response: %w", err)
+	}
+
+	voices := make([]Voice, 0, len(body.Voices))
+	for _, v := range body.Voices {
+		voice := Voice{
+			VoiceID:     v.VoiceID,
+			Name:        v.Name,
+			Category:    v.Category,
+			Language:    v.Labels["language"],
+			Gender:      v.Labels["gender"],
+			Accent:      v.Labels["accent"],
+			Age:         v.Labels["age"],
+			Description: v.Labels["description"],
+			PreviewURL:  v.PreviewURL,
+		}
+		if len(v.VerifiedLanguages) > 0 {
+			if voice.Language == "" {
+				voice.Language = v.VerifiedLanguages[0].Language
+			}
+			if voice.Accent == "" {
+				voice.Accent = v.VerifiedLanguages[0].Accent
+			}
+		}
+		voices = append(voices, voice)
 	}
 
-	return body, nil
+	return voices, nil
 }
@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Classes of provider errors. Providers wrap them so callers can tell errors
+// worth retrying from ones that will fail the same way every time.
+var (
+	// ErrRateLimited means the provider wants fewer requests; RetryAfter says
+	// how long it asked to wait, when it did
+	ErrRateLimited = errors.New("rate limited")
+	// ErrQuotaExceeded means the account is out of credits or quota
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrContentBlocked means a safety filter rejected the prompt or output
+	ErrContentBlocked = errors.New("content blocked")
+	// ErrInvalidInput means the provider rejected the request itself, like an
+	// unknown voice ID
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// permanentErrors are the classes retrying can't fix
+var permanentErrors = []error{ErrQuotaExceeded, ErrContentBlocked, ErrInvalidInput}
+
+// APIError is an error response from a provider's HTTP API
+type APIError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// Class is one of the Err* classes, or nil when a retry may succeed
+	Class error
+	// RetryAfter is how long a rate-limited provider asked to wait, if it said
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s API returned status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Class
+}
+
+// NewAPIError classifies an error response from provider by its status code
+// and message. header may be nil when the client doesn't expose it.
+func NewAPIError(provider string, statusCode int, header http.Header, message string) *APIError {
+	apiErr := &APIError{Provider: provider, StatusCode: statusCode, Message: message}
+	lowerMessage := strings.ToLower(message)
+	switch {
+	case statusCode == http.StatusTooManyRequests && strings.Contains(lowerMessage, "insufficient_quota"):
+		// OpenAI reports an exhausted balance as a 429
+		apiErr.Class = ErrQuotaExceeded
+	case statusCode == http.StatusTooManyRequests:
+		apiErr.Class = ErrRateLimited
+		apiErr.RetryAfter = parseRetryAfter(header.Get("Retry-After"), time.Now())
+	case statusCode == http.StatusPaymentRequired,
+		// ElevenLabs reports an exhausted character quota as a 401
+		statusCode == http.StatusUnauthorized && strings.Contains(lowerMessage, "quota_exceeded"):
+		apiErr.Class = ErrQuotaExceeded
+	case statusCode == http.StatusBadRequest,
+		statusCode == http.StatusNotFound,
+		statusCode == http.StatusUnprocessableEntity:
+		apiErr.Class = ErrInvalidInput
+	}
+	return apiErr
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// IsPermanent reports whether err will fail the same way on every retry. An
+// error joining several, like the image fallback chain's, is permanent only
+// when all of them are.
+func IsPermanent(err error) bool {
+	for err != nil {
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			errs := joined.Unwrap()
+			for _, e := range errs {
+				if !IsPermanent(e) {
+					return false
+				}
+			}
+			return len(errs) > 0
+		}
+		for _, permanent := range permanentErrors {
+			if err == permanent {
+				return true
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+	return false
+}
+
+// RetryAfter returns how long a rate-limited provider asked to wait. It
+// reports false when err isn't a rate limit; a zero duration means the
+// provider didn't say.
+func RetryAfter(err error) (time.Duration, bool) {
+	if !errors.Is(err, ErrRateLimited) {
+		return 0, false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.RetryAfter, true
+	}
+	return 0, true
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		message string
+		class   error
+	}{
+		{name: "rate limit", status: http.StatusTooManyRequests, message: `{"error":{"code":"rate_limit_exceeded"}}`, class: ErrRateLimited},
+		{name: "out of balance", status: http.StatusTooManyRequests, message: `{"error":{"code":"insufficient_quota"}}`, class: ErrQuotaExceeded},
+		{name: "out of characters", status: http.StatusUnauthorized, message: `{"detail":{"status":"quota_exceeded"}}`, class: ErrQuotaExceeded},
+		{name: "unknown voice", status: http.StatusNotFound, message: `{"detail":{"status":"voice_not_found"}}`, class: ErrInvalidInput},
+		{name: "bad request", status: http.StatusBadRequest, message: `invalid prompt`, class: ErrInvalidInput},
+		{name: "bad API key", status: http.StatusUnauthorized, message: `invalid_api_key`},
+		{name: "server error", status: http.StatusBadGateway, message: `upstream down`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewAPIError("test", tt.status, nil, tt.message)
+			if err.Class != tt.class {
+				t.Errorf("Expected class %v, got %v", tt.class, err.Class)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"soon", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.expected {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	blocked := fmt.Errorf("imagen: image blocked by safety filter: %w", ErrContentBlocked)
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "wrapped invalid input", err: fmt.Errorf("failed to generate audio: %w", NewAPIError("ElevenLabs", http.StatusNotFound, nil, "")), expected: true},
+		{name: "rate limit", err: NewAPIError("ElevenLabs", http.StatusTooManyRequests, nil, ""), expected: false},
+		{name: "plain error", err: errors.New("connection reset"), expected: false},
+		{name: "every provider blocked", err: fmt.Errorf("all image providers failed: %w", errors.Join(blocked, fmt.Errorf("images: %w", ErrQuotaExceeded))), expected: true},
+		{name: "one provider may recover", err: fmt.Errorf("all image providers failed: %w", errors.Join(blocked, errors.New("images: timeout"))), expected: false},
+		{name: "cancelled chain", err: errors.Join(context.Canceled), expected: false},
+		{name: "nil", err: nil, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPermanent(tt.err); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	header := http.Header{"Retry-After": []string{"20"}}
+	err := fmt.Errorf("failed to generate audio: %w", NewAPIError("ElevenLabs", http.StatusTooManyRequests, header, "too many requests"))
+	if after, ok := RetryAfter(err); !ok || after != 20*time.Second {
+		t.Errorf("Expected a 20s wait, got %s (%v)", after, ok)
+	}
+	if _, ok := RetryAfter(errors.New("connection reset")); ok {
+		t.Error("Expected a plain error not to be a rate limit")
+	}
+}
+
+func TestOpenAIServiceClassifiesErrors(t *testing.T) {
+	service := newTestOpenAIService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"error":{"message":"Rate limit reached"}}`))
+	})
+
+	_, err := service.GenerateVideoScript(context.Background(), "octopuses")
+	if after, ok := RetryAfter(err); !ok || after != 7*time.Second {
+		t.Errorf("Expected a rate limit with a 7s wait, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+func init() {
+	RegisterLLM("fake", func(ctx context.Context, model string) (LLM, error) {
+		return &FakeLLM{}, nil
+	})
+}
+
+// FakeLLM returns canned scripts and scenes offline, so the pipeline and
+// tests can run without a model. Its output only depends on its input.
+type FakeLLM struct{}
+
+// GenerateVideoScript returns a short placeholder script about theme
+func (f *FakeLLM) GenerateVideoScript(ctx context.Context, theme string) (string, error) {
+	theme = strings.TrimSpace(theme)
+	if theme == "" {
+		return "", fmt.Errorf("theme cannot be empty: %w", ErrInvalidInput)
+	}
+
+	return fmt.Sprintf("Did you know there is more to %s than meets the eye? "+
+		"Most people never stop to think about it, but %s shapes the world around us every day. "+
+		"Next time you come across %s, take a closer look and see what you notice.",
+		theme, theme, theme), nil
+}
+
+// GenerateScenes returns one scene per sentence of script, at most three
+func (f *FakeLLM) GenerateScenes(ctx context.Context, script string) ([]ScenePrompt, error) {
+	var sentences []string
+	for _, sentence := range strings.FieldsFunc(script, func(r rune) bool {
+		return r == '.' || r == '?' || r == '!'
+	}) {
+		if sentence = strings.TrimSpace(sentence); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+	}
+	if len(sentences) == 0 {
+		return nil, fmt.Errorf("no scenes generated")
+	}
+	if len(sentences) > 3 {
+		sentences = sentences[:3]
+	}
+
+	scenes := make([]ScenePrompt, len(sentences))
+	for i, sentence := range sentences {
+		scenes[i] = ScenePrompt{
+			ImagePrompt: "Minimalist illustration, vibrant colors: " + sentence,
+			Index:       i,
+		}
+	}
+	return scenes, nil
+}
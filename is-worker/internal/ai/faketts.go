@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterTextToSpeech("fake", func(ctx context.Context) (TextToSpeech, error) {
+		return NewFakeTextToSpeech()
+	})
+}
+
+const (
+	fakeWAVSampleRate = 16000
+	fakeMP3SampleRate = 44100
+	// Samples per MPEG-1 Layer III frame
+	mp3FrameSamples = 1152
+)
+
+// silentMP3Frame is a 32kbps, 44.1kHz mono MPEG-1 Layer III frame.
+// Its side information is all zeros, so every decoder plays it as silence.
+var silentMP3Frame = func() []byte {
+	frame := make([]byte, 144*32000/fakeMP3SampleRate)
+	copy(frame, []byte{0xFF, 0xFB, 0x10, 0xC0})
+	return frame
+}()
+
+// FakeTextToSpeech generates deterministic placeholder narration offline.
+// The audio is as long as the script would take to read aloud, so the rest of
+// the pipeline can run without an API key.
+type FakeTextToSpeech struct {
+	// Format is "wav" or "mp3"
+	Format string
+	// ToneHz plays a sine tone instead of silence; only used for WAV
+	ToneHz float64
+	// WordsPerMinute is the speaking rate used to size the audio
+	WordsPerMinute float64
+}
+
+// NewFakeTextToSpeech creates a fake provider configured by FAKE_TTS_FORMAT,
+// FAKE_TTS_TONE_HZ and FAKE_TTS_WORDS_PER_MINUTE
+func NewFakeTextToSpeech() (*FakeTextToSpeech, error) {
+	fake := &FakeTextToSpeech{Format: "wav", WordsPerMinute: 150}
+
+	if format := os.Getenv("FAKE_TTS_FORMAT"); format != "" {
+		if format != "wav" && format != "mp3" {
+			return nil, fmt.Errorf("FAKE_TTS_FORMAT must be wav or mp3, got %q", format)
+		}
+		fake.Format = format
+	}
+	if tone := os.Getenv("FAKE_TTS_TONE_HZ"); tone != "" {
+		hz, err := strconv.ParseFloat(tone, 64)
+		if err != nil || hz < 0 {
+			return nil, fmt.Errorf("invalid FAKE_TTS_TONE_HZ %q", tone)
+		}
+		fake.ToneHz = hz
+	}
+	if wpm := os.Getenv("FAKE_TTS_WORDS_PER_MINUTE"); wpm != "" {
+		rate, err := strconv.ParseFloat(wpm, 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("invalid FAKE_TTS_WORDS_PER_MINUTE %q", wpm)
+		}
+		fake.WordsPerMinute = rate
+	}
+
+	return fake, nil
+}
+
+// GenerateAudio returns silent or tone audio lasting as long as text takes to read.
+// The audio is generated as Body is read, so long scripts use no extra memory.
+func (f *FakeTextToSpeech) GenerateAudio(ctx context.Context, text string, voiceID string, settings VoiceSettings) (*Audio, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text cannot be empty")
+	}
+	if voiceID == "" {
+		return nil, fmt.Errorf("voiceID cannot be empty")
+	}
+
+	duration := f.Duration(text)
+	if f.Format == "mp3" {
+		return &Audio{Body: io.NopCloser(silentMP3(duration)), ContentType: AudioContentTypeMP3}, nil
+	}
+	return &Audio{Body: io.NopCloser(toneWAV(duration, f.ToneHz)), ContentType: AudioContentTypeWAV}, nil
+}
+
+// Duration returns how long reading text aloud takes, at least one second
+func (f *FakeTextToSpeech) Duration(text string) time.Duration {
+	words := len(strings.Fields(text))
+	duration := time.Duration(float64(words) / f.WordsPerMinute * float64(time.Minute))
+	if duration < time.Second {
+		duration = time.Second
+	}
+	return duration
+}
+
+// silentMP3 repeats a silent frame until the audio lasts at least duration
+func silentMP3(duration time.Duration) io.Reader {
+	frames := int64(math.Ceil(duration.Seconds() * fakeMP3SampleRate / mp3FrameSamples))
+	return &repeatReader{data: silentMP3Frame, size: frames * int64(len(silentMP3Frame))}
+}
+
+// repeatReader reads data over and over until size bytes have been read
+type repeatReader struct {
+	data   []byte
+	size   int64
+	offset int64
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	if remaining := r.size - r.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n := 0
+	for n < len(p) {
+		n += copy(p[n:], r.data[(r.offset+int64(n))%int64(len(r.data)):])
+	}
+	r.offset += int64(n)
+	return n, nil
+}
+
+// toneWAV encodes 16-bit mono PCM of a sine tone, or silence when hz is 0
+func toneWAV(duration time.Duration, hz float64) io.Reader {
+	samples := int64(duration.Seconds() * fakeWAVSampleRate)
+	dataSize := samples * 2
+
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+dataSize))
+	copy(header[8:], "WAVE")
+	copy(header[12:], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)                  // fmt chunk size
+	binary.LittleEndian.PutUint16(header[20:], 1)                   // PCM
+	binary.LittleEndian.PutUint16(header[22:], 1)                   // mono
+	binary.LittleEndian.PutUint32(header[24:], fakeWAVSampleRate)   // sample rate
+	binary.LittleEndian.PutUint32(header[28:], fakeWAVSampleRate*2) // byte rate
+	binary.LittleEndian.PutUint16(header[32:], 2)                   // block align
+	binary.LittleEndian.PutUint16(header[34:], 16)                  // bits per sample
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(dataSize))
+
+	return io.MultiReader(bytes.NewReader(header), &toneReader{hz: hz, size: dataSize})
+}
+
+// toneReader generates little-endian 16-bit samples of a sine tone as it is read
+type toneReader struct {
+	hz     float64
+	size   int64
+	offset int64
+	sample uint16
+}
+
+func (t *toneReader) Read(p []byte) (int, error) {
+	if t.offset >= t.size {
+		return 0, io.EOF
+	}
+	if remaining := t.size - t.offset; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	if t.hz == 0 {
+		clear(p)
+		t.offset += int64(len(p))
+		return len(p), nil
+	}
+	for i := range p {
+		// Each sample is computed once, on its low byte
+		if t.offset%2 == 0 {
+			t.sample = uint16(int16(0.2 * math.MaxInt16 * math.Sin(2*math.Pi*t.hz*float64(t.offset/2)/fakeWAVSampleRate)))
+		}
+		p[i] = byte(t.sample >> (8 * (t.offset % 2)))
+		t.offset++
+	}
+	return len(p), nil
+}
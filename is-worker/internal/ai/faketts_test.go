@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+)
+
+func TestFakeTextToSpeechWAV(t *testing.T) {
+	fake := &FakeTextToSpeech{Format: "wav", ToneHz: 440, WordsPerMinute: 150}
+	script := strings.Repeat("word ", 150)
+
+	audio, err := fake.GenerateAudio(context.Background(), script, "voice", VoiceSettings{})
+	if err != nil {
+		t.Fatalf("GenerateAudio returned error: %v", err)
+	}
+	if audio.ContentType != AudioContentTypeWAV {
+		t.Errorf("Expected content type %s, got %s", AudioContentTypeWAV, audio.ContentType)
+	}
+
+	data := readAudio(t, audio)
+
+	// 150 words at 150 words per minute is one minute of 16-bit mono audio
+	dataSize := binary.LittleEndian.Uint32(data[40:44])
+	sampleRate := binary.LittleEndian.Uint32(data[24:28])
+	if got := time.Duration(float64(dataSize) / 2 / float64(sampleRate) * float64(time.Second)); got != time.Minute {
+		t.Errorf("Expected one minute of audio, got %s", got)
+	}
+	if len(data) != 44+int(dataSize) {
+		t.Errorf("Expected %d bytes, got %d", 44+dataSize, len(data))
+	}
+	if bytes.Count(data[44:], []byte{0}) == int(dataSize) {
+		t.Error("Expected a tone, got silence")
+	}
+	if got := detectAudioEncoding(data); got != speechpb.RecognitionConfig_ENCODING_UNSPECIFIED {
+		t.Errorf("Expected WAV to leave the encoding unspecified, got %s", got)
+	}
+
+	// Reading a byte at a time must produce the same samples as large reads
+	again, _ := fake.GenerateAudio(context.Background(), script, "voice", VoiceSettings{})
+	again.Body = io.NopCloser(iotest.OneByteReader(again.Body))
+	if !bytes.Equal(data, readAudio(t, again)) {
+		t.Error("Expected identical audio for identical input")
+	}
+}
+
+func readAudio(t *testing.T, audio *Audio) []byte {
+	t.Helper()
+	defer audio.Body.Close()
+	data, err := io.ReadAll(audio.Body)
+	if err != nil {
+		t.Fatalf("Failed to read audio: %v", err)
+	}
+	return data
+}
+
+func TestFakeTextToSpeechMP3(t *testing.T) {
+	fake := &FakeTextToSpeech{Format: "mp3", WordsPerMinute: 150}
+
+	audio, err := fake.GenerateAudio(context.Background(), strings.Repeat("word ", 25), "voice", VoiceSettings{})
+	if err != nil {
+		t.Fatalf("GenerateAudio returned error: %v", err)
+	}
+	if audio.ContentType != AudioContentTypeMP3 {
+		t.Errorf("Expected content type %s, got %s", AudioContentTypeMP3, audio.ContentType)
+	}
+
+	data := readAudio(t, audio)
+
+	// 25 words is 10 seconds, rounded up to whole frames
+	if len(data)%len(silentMP3Frame) != 0 || !bytes.Equal(data[len(data)-len(silentMP3Frame):], silentMP3Frame) {
+		t.Error("Expected whole silent frames")
+	}
+	frames := len(data) / len(silentMP3Frame)
+	if got := float64(frames*mp3FrameSamples) / fakeMP3SampleRate; got < 10 || got > 10.1 {
+		t.Errorf("Expected about 10 seconds of audio, got %.2fs", got)
+	}
+	if got := detectAudioEncoding(data); got != speechpb.RecognitionConfig_MP3 {
+		t.Errorf("Expected MP3 encoding, got %s", got)
+	}
+}
+
+func TestNewTextToSpeech(t *testing.T) {
+	tts, err := NewTextToSpeech(context.Background(), "fake")
+	if err != nil {
+		t.Fatalf("NewTextToSpeech returned error: %v", err)
+	}
+	if _, ok := tts.(*FakeTextToSpeech); !ok {
+		t.Errorf("Expected *FakeTextToSpeech, got %T", tts)
+	}
+
+	if _, err := NewTextToSpeech(context.Background(), "missing"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
@@ -2,16 +2,51 @@ package gemini
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"strings"
+
+	"instashorts-be/is-worker/internal/ai"
 
 	"google.golang.org/genai"
 )
 
+// Default Gemini models for each text pipeline step
+const (
+	DefaultScriptModel = "gemini-2.0-flash-exp"
+	DefaultSceneModel  = "gemini-2.5-pro"
+	DefaultImageModel  = "imagen-4.0-generate-001"
+)
+
+func init() {
+	ai.RegisterLLM("gemini", func(ctx context.Context, model string) (ai.LLM, error) {
+		service, err := NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if model != "" {
+			service.scriptModel = model
+			service.sceneModel = model
+		}
+		return service, nil
+	})
+	ai.RegisterImageGenerator("imagen", func(ctx context.Context) (ai.ImageGenerator, error) {
+		service, err := NewService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if model := os.Getenv("IMAGEN_MODEL"); model != "" {
+			service.imageModel = model
+		}
+		return service, nil
+	})
+}
+
 type Service struct {
-	client *genai.Client
+	client      *genai.Client
+	scriptModel string
+	sceneModel  string
+	imageModel  string
 }
 
 // NewService creates a new AI service with Vertex AI
@@ -38,120 +73,74 @@ func NewService(ctx context.Context) (*Service, error) {
 		return nil, fmt.Errorf("failed to create Vertex AI client: %w", err)
 	}
 
-	return &Service{client: client}, nil
+	return &Service{
+		client:      client,
+		scriptModel: DefaultScriptModel,
+		sceneModel:  DefaultSceneModel,
+		imageModel:  DefaultImageModel,
+	}, nil
 }
 
 // GenerateVideoScript generates a video script based on the theme
 // The script will be 250-300 words for approximately 70 seconds of narration
 func (s *Service) GenerateVideoScript(ctx context.Context, theme string) (string, error) {
-	prompt := fmt.Sprintf(`Generate a compelling and engaging video script about: %s
-
-Requirements:
-- The script should be between 50-100 words (approximately 10 seconds when narrated)
-- Write in a conversational and engaging tone suitable for short-form video content
-- The script should be in paragraph format with no headers or subheaders
-- Make it informative yet entertaining
-- Include a strong hook at the beginning to capture attention
-- Structure the content with a clear beginning, middle, and end
-- End with a thought-provoking conclusion
-- Write ONLY the script text, no additional formatting, labels, or stage directions
-
-
-Generate the script now:`, theme)
-	// - Include voice controls like: [laughs], [laughs harder], [starts laughing], [wheezing], [whispers], [sighs], [exhales],[sarcastic], [curious], [excited], [crying], [snorts], [mischievously]
-
 	result, err := s.client.Models.GenerateContent(
 		ctx,
-		"gemini-2.0-flash-exp",
-		genai.Text(prompt),
+		s.scriptModel,
+		genai.Text(ai.ScriptPrompt(theme)),
 		nil,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to generate content: %w", err)
+		return "", fmt.Errorf("failed to generate content: %w", classifyError(err))
 	}
 
 	script := result.Text()
 	if script == "" {
+		if blocked := promptBlocked(result); blocked != nil {
+			return "", blocked
+		}
 		return "", fmt.Errorf("generated script is empty")
 	}
 
 	return script, nil
 }
 
-// ScenePrompt represents a scene with its image generation prompt
-type ScenePrompt struct {
-	ImagePrompt string `json:"image_prompt"`
-	Index       int    `json:"index"`
-}
-
 // GenerateScenes generates 2-3 scene prompts based on the video script
-func (s *Service) GenerateScenes(ctx context.Context, script string) ([]ScenePrompt, error) {
-	prompt := fmt.Sprintf(`Based on the following video script, generate 2-3 scene descriptions that will be used to create images for the video.
-
-Script:
-%s
-
-Requirements:
-- Generate between 2-3 scenes that flow with the narration. 
-- the script should be in paragraph format with no headers or subheaders.
-- Each scene should be a detailed, descriptive image prompt that can be used for image generation. include consistent style and coloring across all scenes
-- Use consistent styling and coloring across all scenes (e.g., "cinematic style", "vibrant colors", "minimalist illustration")
-- Make each prompt very descriptive (3-4 sentences) to generate high-quality images
-- Prompts should include detailed descriptions of the scene, including the characters, objects, and background.
-- Order the scenes to match the progression of the script
-- Return ONLY a valid JSON array with no additional text, markdown formatting, or code blocks
-- Format: [{"image_prompt": "detailed description here", "index": 0}, {"image_prompt": "another detailed description", "index": 1}, ...]
-
-Generate the JSON array now:`, script)
-
+func (s *Service) GenerateScenes(ctx context.Context, script string) ([]ai.ScenePrompt, error) {
 	result, err := s.client.Models.GenerateContent(
 		ctx,
-		"gemini-2.5-pro",
-		genai.Text(prompt),
+		s.sceneModel,
+		genai.Text(ai.ScenesPrompt(script)),
 		nil,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate scenes: %w", err)
-	}
-
-	responseText := result.Text()
-	if responseText == "" {
-		return nil, fmt.Errorf("generated scenes response is empty")
+		return nil, fmt.Errorf("failed to generate scenes: %w", classifyError(err))
 	}
-
-	// Strip markdown code blocks if present (```json ... ```)
-	responseText = stripMarkdownCodeBlocks(responseText)
-
-	// Parse the JSON response
-	var scenes []ScenePrompt
-	if err := json.Unmarshal([]byte(responseText), &scenes); err != nil {
-		return nil, fmt.Errorf("failed to parse scenes JSON: %w (response: %s)", err, responseText)
+	if blocked := promptBlocked(result); blocked != nil {
+		return nil, blocked
 	}
 
-	if len(scenes) == 0 {
-		return nil, fmt.Errorf("no scenes generated")
-	}
-
-	return scenes, nil
+	return ai.ParseScenePrompts(result.Text())
 }
 
 // GenerateImage generates an image using the Imagen model based on a text prompt
 // Returns the image data as bytes
 func (s *Service) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
-	// Generate image using Imagen 4.0
 	config := &genai.GenerateImagesConfig{
 		NumberOfImages: 1,
 		AspectRatio:    "9:16", // Vertical format for short-form videos
+		// Report why a safety filter dropped the image instead of returning nothing
+		IncludeRAIReason: true,
 	}
 
 	response, err := s.client.Models.GenerateImages(
 		ctx,
-		"imagen-4.0-generate-001",
+		s.imageModel,
 		prompt,
 		config,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate image: %w", err)
+		return nil, fmt.Errorf("failed to generate image: %w", classifyError(err))
 	}
 
 	if len(response.GeneratedImages) == 0 {
@@ -159,7 +148,14 @@ func (s *Service) GenerateImage(ctx context.Context, prompt string) ([]byte, err
 	}
 
 	// Return the first (and only) generated image
-	imageBytes := response.GeneratedImages[0].Image.ImageBytes
+	generated := response.GeneratedImages[0]
+	if generated.Image == nil {
+		if generated.RAIFilteredReason != "" {
+			return nil, fmt.Errorf("image blocked by safety filter: %s: %w", generated.RAIFilteredReason, ai.ErrContentBlocked)
+		}
+		return nil, fmt.Errorf("no images generated")
+	}
+	imageBytes := generated.Image.ImageBytes
 	if len(imageBytes) == 0 {
 		return nil, fmt.Errorf("generated image has no data")
 	}
@@ -167,28 +163,21 @@ func (s *Service) GenerateImage(ctx context.Context, prompt string) ([]byte, err
 	return imageBytes, nil
 }
 
-// stripMarkdownCodeBlocks removes markdown code block formatting from a string
-// Handles cases like ```json ... ``` or ``` ... ```
-func stripMarkdownCodeBlocks(text string) string {
-	// Trim whitespace
-	text = strings.TrimSpace(text)
-
-	// Check if it starts with ``` and ends with ```
-	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") {
-		// Remove the opening ```
-		text = strings.TrimPrefix(text, "```")
-
-		// Remove language identifier if present (e.g., "json")
-		if idx := strings.Index(text, "\n"); idx != -1 {
-			text = text[idx+1:]
-		}
-
-		// Remove the closing ```
-		text = strings.TrimSuffix(text, "```")
-
-		// Trim any remaining whitespace
-		text = strings.TrimSpace(text)
+// classifyError turns a Vertex AI error response into an ai.APIError, so rate
+// limits and rejected requests can be told apart from transient failures
+func classifyError(err error) error {
+	var apiErr genai.APIError
+	if !errors.As(err, &apiErr) {
+		return err
 	}
+	return ai.NewAPIError("Vertex AI", apiErr.Code, nil, apiErr.Message)
+}
 
-	return text
+// promptBlocked returns ai.ErrContentBlocked when a safety filter dropped the
+// response to the prompt
+func promptBlocked(result *genai.GenerateContentResponse) error {
+	if result.PromptFeedback == nil || result.PromptFeedback.BlockReason == "" {
+		return nil
+	}
+	return fmt.Errorf("prompt blocked by safety filter: %s: %w", result.PromptFeedback.BlockReason, ai.ErrContentBlocked)
 }
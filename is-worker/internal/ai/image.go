@@ -0,0 +1,146 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultImageProviders is used when IMAGE_PROVIDERS is not set
+const DefaultImageProviders = "imagen,placeholder"
+
+// ImageGenerator turns a scene prompt into a 9:16 PNG image
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, prompt string) ([]byte, error)
+}
+
+// ImageGeneratorFactory creates an ImageGenerator from its environment configuration
+type ImageGeneratorFactory func(ctx context.Context) (ImageGenerator, error)
+
+var (
+	imageMu        sync.RWMutex
+	imageProviders = map[string]ImageGeneratorFactory{}
+)
+
+// RegisterImageGenerator makes an ImageGenerator available under name
+func RegisterImageGenerator(name string, factory ImageGeneratorFactory) {
+	imageMu.Lock()
+	defer imageMu.Unlock()
+	if _, exists := imageProviders[name]; exists {
+		panic(fmt.Sprintf("ai: image provider %q registered twice", name))
+	}
+	imageProviders[name] = factory
+}
+
+// ImageGeneratorProviders returns the names of every registered provider
+func ImageGeneratorProviders() []string {
+	imageMu.RLock()
+	defer imageMu.RUnlock()
+	names := make([]string, 0, len(imageProviders))
+	for name := range imageProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewImageGenerator creates the ImageGenerator registered under name
+func NewImageGenerator(ctx context.Context, name string) (ImageGenerator, error) {
+	imageMu.RLock()
+	factory, ok := imageProviders[name]
+	imageMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown image provider %q (available: %s)", name, strings.Join(ImageGeneratorProviders(), ", "))
+	}
+
+	generator, err := factory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s image provider: %w", name, err)
+	}
+	return generator, nil
+}
+
+// NewImageGeneratorChain creates a FallbackImageGenerator from a comma-separated
+// list of provider names, tried in order
+func NewImageGeneratorChain(ctx context.Context, names string) (*FallbackImageGenerator, error) {
+	chain := &FallbackImageGenerator{}
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		generator, err := NewImageGenerator(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		chain.Add(name, generator)
+	}
+	if len(chain.generators) == 0 {
+		return nil, fmt.Errorf("no image providers configured")
+	}
+	return chain, nil
+}
+
+// namedImageGenerator is an ImageGenerator with the name it is logged under
+type namedImageGenerator struct {
+	name      string
+	generator ImageGenerator
+}
+
+// FallbackImageGenerator tries each of its generators in order and returns the
+// first image produced. A safety block or quota error from one provider falls
+// through to the next instead of failing the scene; any other error, a rate
+// limit included, fails it right away, so the task's retry tries the same
+// provider again after the delay it asked for.
+type FallbackImageGenerator struct {
+	generators []namedImageGenerator
+}
+
+// Add appends generator to the end of the chain
+func (f *FallbackImageGenerator) Add(name string, generator ImageGenerator) {
+	f.generators = append(f.generators, namedImageGenerator{name: name, generator: generator})
+}
+
+// Providers returns the names of the generators in the order they are tried
+func (f *FallbackImageGenerator) Providers() []string {
+	names := make([]string, len(f.generators))
+	for i, g := range f.generators {
+		names[i] = g.name
+	}
+	return names
+}
+
+// GenerateImage returns the first image any generator produces. It stops at
+// the first error that another provider can't get around, and otherwise
+// returns every generator's error when they all fail.
+func (f *FallbackImageGenerator) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	var errs []error
+	for _, g := range f.generators {
+		// Don't burn through the rest of the chain once the task is cancelled
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		image, err := g.generator.GenerateImage(ctx, prompt)
+		if err == nil {
+			return image, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", g.name, err))
+		if !fallsThrough(err) {
+			return nil, fmt.Errorf("image provider failed: %w", errors.Join(errs...))
+		}
+		log.Printf("WARNING: %s image provider failed, trying next provider: %v", g.name, err)
+	}
+	return nil, fmt.Errorf("all image providers failed: %w", errors.Join(errs...))
+}
+
+// fallsThrough reports whether err is specific to the provider that returned
+// it, so the next provider in the chain may still produce the image
+func fallsThrough(err error) bool {
+	return errors.Is(err, ErrContentBlocked) || errors.Is(err, ErrQuotaExceeded)
+}
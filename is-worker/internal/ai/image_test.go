@@ -0,0 +1,208 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubImageGenerator struct {
+	image []byte
+	err   error
+	calls int
+}
+
+func (s *stubImageGenerator) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	s.calls++
+	return s.image, s.err
+}
+
+func TestFallbackImageGenerator(t *testing.T) {
+	blocked := &stubImageGenerator{err: fmt.Errorf("image blocked by safety filter: %w", ErrContentBlocked)}
+	quota := &stubImageGenerator{err: &APIError{Provider: "images", StatusCode: 402, Message: "quota exceeded", Class: ErrQuotaExceeded}}
+	working := &stubImageGenerator{image: []byte("png")}
+	unused := &stubImageGenerator{image: []byte("other")}
+
+	chain := &FallbackImageGenerator{}
+	chain.Add("imagen", blocked)
+	chain.Add("openai", quota)
+	chain.Add("placeholder", working)
+	chain.Add("unused", unused)
+
+	image, err := chain.GenerateImage(context.Background(), "a reef")
+	if err != nil {
+		t.Fatalf("GenerateImage returned error: %v", err)
+	}
+	if string(image) != "png" {
+		t.Errorf("Expected image from the first working provider, got %q", image)
+	}
+	if blocked.calls != 1 || quota.calls != 1 || working.calls != 1 || unused.calls != 0 {
+		t.Errorf("Unexpected calls: blocked=%d quota=%d working=%d unused=%d", blocked.calls, quota.calls, working.calls, unused.calls)
+	}
+}
+
+func TestFallbackImageGeneratorAllFail(t *testing.T) {
+	chain := &FallbackImageGenerator{}
+	chain.Add("imagen", &stubImageGenerator{err: fmt.Errorf("image blocked by safety filter: %w", ErrContentBlocked)})
+	chain.Add("openai", &stubImageGenerator{err: &APIError{Provider: "images", StatusCode: 402, Message: "out of credits", Class: ErrQuotaExceeded}})
+
+	_, err := chain.GenerateImage(context.Background(), "a reef")
+	if err == nil {
+		t.Fatal("Expected error when every provider fails")
+	}
+	for _, want := range []string{"imagen: image blocked", "openai: images API returned status 402"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to contain %q, got %v", want, err)
+		}
+	}
+}
+
+func TestFallbackImageGeneratorStopsOnOtherErrors(t *testing.T) {
+	blocked := &stubImageGenerator{err: fmt.Errorf("image blocked by safety filter: %w", ErrContentBlocked)}
+	down := &stubImageGenerator{err: errors.New("connection reset")}
+	placeholder := &stubImageGenerator{image: []byte("png")}
+	chain := &FallbackImageGenerator{}
+	chain.Add("imagen", blocked)
+	chain.Add("openai", down)
+	chain.Add("placeholder", placeholder)
+
+	// A transient failure is retried by the task rather than settled with a placeholder
+	_, err := chain.GenerateImage(context.Background(), "a reef")
+	if err == nil || !strings.Contains(err.Error(), "openai: connection reset") {
+		t.Fatalf("Expected the error of the provider that went down, got %v", err)
+	}
+	if IsPermanent(err) {
+		t.Errorf("Expected the error to be retried, got %v", err)
+	}
+	if placeholder.calls != 0 {
+		t.Error("Expected the chain to stop at an error another provider can't get around")
+	}
+}
+
+func TestFallbackImageGeneratorStopsOnRateLimit(t *testing.T) {
+	limited := &stubImageGenerator{err: NewAPIError("Vertex AI", 429, http.Header{"Retry-After": []string{"30"}}, "slow down")}
+	placeholder := &stubImageGenerator{image: []byte("png")}
+	chain := &FallbackImageGenerator{}
+	chain.Add("imagen", limited)
+	chain.Add("placeholder", placeholder)
+
+	// A placeholder is no answer to a busy provider; the task waits and retries it
+	_, err := chain.GenerateImage(context.Background(), "a reef")
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("Expected the rate limit, got %v", err)
+	}
+	if delay, ok := RetryAfter(err); !ok || delay != 30*time.Second {
+		t.Errorf("Expected the provider's Retry-After to survive, got %s (%v)", delay, ok)
+	}
+	if placeholder.calls != 0 {
+		t.Error("Expected a rate limit not to reach the next provider")
+	}
+}
+
+func TestFallbackImageGeneratorCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &stubImageGenerator{err: fmt.Errorf("out of credits: %w", ErrQuotaExceeded)}
+	second := &stubImageGenerator{image: []byte("png")}
+	chain := &FallbackImageGenerator{}
+	chain.Add("first", &cancellingImageGenerator{stubImageGenerator: first, cancel: cancel})
+	chain.Add("second", second)
+
+	if _, err := chain.GenerateImage(ctx, "a reef"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if second.calls != 0 {
+		t.Error("Expected the chain to stop once the context is cancelled")
+	}
+}
+
+type cancellingImageGenerator struct {
+	*stubImageGenerator
+	cancel context.CancelFunc
+}
+
+func (c *cancellingImageGenerator) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	c.cancel()
+	return c.stubImageGenerator.GenerateImage(ctx, prompt)
+}
+
+func TestNewImageGeneratorChain(t *testing.T) {
+	chain, err := NewImageGeneratorChain(context.Background(), " placeholder , ")
+	if err != nil {
+		t.Fatalf("NewImageGeneratorChain returned error: %v", err)
+	}
+	if got := fmt.Sprint(chain.Providers()); got != "[placeholder]" {
+		t.Errorf("Expected [placeholder], got %s", got)
+	}
+
+	if _, err := NewImageGeneratorChain(context.Background(), "placeholder,missing"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+	if _, err := NewImageGeneratorChain(context.Background(), ""); err == nil {
+		t.Error("Expected error for empty chain")
+	}
+}
+
+func TestPlaceholderImageGenerator(t *testing.T) {
+	generator := &PlaceholderImageGenerator{}
+	prompt := strings.Repeat("A cinematic shot of a coral reef at dawn, vibrant colors. ", 40)
+
+	data, err := generator.GenerateImage(context.Background(), prompt)
+	if err != nil {
+		t.Fatalf("GenerateImage returned error: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Expected a PNG image: %v", err)
+	}
+	if bounds := img.Bounds(); bounds.Dx() != 1080 || bounds.Dy() != 1920 {
+		t.Errorf("Expected 1080x1920, got %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	again, _ := generator.GenerateImage(context.Background(), prompt)
+	if !bytes.Equal(data, again) {
+		t.Error("Expected identical images for identical prompts")
+	}
+
+	if _, err := generator.GenerateImage(context.Background(), " "); err == nil {
+		t.Error("Expected error for empty prompt")
+	}
+}
+
+func TestWrapText(t *testing.T) {
+	lines := wrapText("a coral reef supercalifragilistic at dawn", 10)
+	want := []string{"a coral", "reef", "supercalif", "ragilistic", "at dawn"}
+	if fmt.Sprint(lines) != fmt.Sprint(want) {
+		t.Errorf("Expected %q, got %q", want, lines)
+	}
+}
+
+func TestOpenAIImageService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/images/generations" {
+			t.Errorf("Expected /v1/images/generations, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+		fmt.Fprintf(w, `{"data":[{"b64_json":%q}]}`, base64.StdEncoding.EncodeToString([]byte("png")))
+	}))
+	defer srv.Close()
+
+	service := NewOpenAIImageServiceWithClient(srv.URL+"/v1", "secret", "dall-e-3", srv.Client())
+	image, err := service.GenerateImage(context.Background(), "a reef")
+	if err != nil {
+		t.Fatalf("GenerateImage returned error: %v", err)
+	}
+	if string(image) != "png" {
+		t.Errorf("Expected decoded image, got %q", image)
+	}
+}
@@ -0,0 +1,169 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultLLMProvider is used when LLM_PROVIDER is not set
+const DefaultLLMProvider = "gemini"
+
+// ScriptWriter writes the narration script for a video theme
+type ScriptWriter interface {
+	GenerateVideoScript(ctx context.Context, theme string) (string, error)
+}
+
+// ScenePrompt represents a scene with its image generation prompt
+type ScenePrompt struct {
+	ImagePrompt string `json:"image_prompt"`
+	Index       int    `json:"index"`
+}
+
+// SceneDirector splits a video script into image prompts for its scenes
+type SceneDirector interface {
+	GenerateScenes(ctx context.Context, script string) ([]ScenePrompt, error)
+}
+
+// LLM is a language model backend that can drive both text pipeline steps
+type LLM interface {
+	ScriptWriter
+	SceneDirector
+}
+
+// LLMFactory creates an LLM backend for model. An empty model selects the
+// backend's default for each step.
+type LLMFactory func(ctx context.Context, model string) (LLM, error)
+
+var (
+	llmMu        sync.RWMutex
+	llmProviders = map[string]LLMFactory{}
+)
+
+// RegisterLLM makes an LLM backend available under name
+func RegisterLLM(name string, factory LLMFactory) {
+	llmMu.Lock()
+	defer llmMu.Unlock()
+	if _, exists := llmProviders[name]; exists {
+		panic(fmt.Sprintf("ai: LLM provider %q registered twice", name))
+	}
+	llmProviders[name] = factory
+}
+
+// LLMProviders returns the names of every registered LLM backend
+func LLMProviders() []string {
+	llmMu.RLock()
+	defer llmMu.RUnlock()
+	names := make([]string, 0, len(llmProviders))
+	for name := range llmProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewLLM creates the LLM backend registered under name for model
+func NewLLM(ctx context.Context, name string, model string) (LLM, error) {
+	llmMu.RLock()
+	factory, ok := llmProviders[name]
+	llmMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown LLM provider %q (available: %s)", name, strings.Join(LLMProviders(), ", "))
+	}
+
+	llm, err := factory(ctx, model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s LLM provider: %w", name, err)
+	}
+	return llm, nil
+}
+
+// ScriptPrompt returns the prompt every backend uses to write a script for theme
+func ScriptPrompt(theme string) string {
+	return fmt.Sprintf(`Generate a compelling and engaging video script about: %s
+
+Requirements:
+- The script should be between 50-100 words (approximately 10 seconds when narrated)
+- Write in a conversational and engaging tone suitable for short-form video content
+- The script should be in paragraph format with no headers or subheaders
+- Make it informative yet entertaining
+- Include a strong hook at the beginning to capture attention
+- Structure the content with a clear beginning, middle, and end
+- End with a thought-provoking conclusion
+- Write ONLY the script text, no additional formatting, labels, or stage directions
+
+
+Generate the script now:`, theme)
+	// - Include voice controls like: [laughs], [laughs harder], [starts laughing], [wheezing], [whispers], [sighs], [exhales],[sarcastic], [curious], [excited], [crying], [snorts], [mischievously]
+}
+
+// ScenesPrompt returns the prompt every backend uses to split script into scenes
+func ScenesPrompt(script string) string {
+	return fmt.Sprintf(`Based on the following video script, generate 2-3 scene descriptions that will be used to create images for the video.
+
+Script:
+%s
+
+Requirements:
+- Generate between 2-3 scenes that flow with the narration.
+- the script should be in paragraph format with no headers or subheaders.
+- Each scene should be a detailed, descriptive image prompt that can be used for image generation. include consistent style and coloring across all scenes
+- Use consistent styling and coloring across all scenes (e.g., "cinematic style", "vibrant colors", "minimalist illustration")
+- Make each prompt very descriptive (3-4 sentences) to generate high-quality images
+- Prompts should include detailed descriptions of the scene, including the characters, objects, and background.
+- Order the scenes to match the progression of the script
+- Return ONLY a valid JSON array with no additional text, markdown formatting, or code blocks
+- Format: [{"image_prompt": "detailed description here", "index": 0}, {"image_prompt": "another detailed description", "index": 1}, ...]
+
+Generate the JSON array now:`, script)
+}
+
+// ParseScenePrompts parses the JSON array a model returned for ScenesPrompt
+func ParseScenePrompts(responseText string) ([]ScenePrompt, error) {
+	if responseText == "" {
+		return nil, fmt.Errorf("generated scenes response is empty")
+	}
+
+	// Strip markdown code blocks if present (```json ... ```)
+	responseText = stripMarkdownCodeBlocks(responseText)
+
+	var scenes []ScenePrompt
+	if err := json.Unmarshal([]byte(responseText), &scenes); err != nil {
+		return nil, fmt.Errorf("failed to parse scenes JSON: %w (response: %s)", err, responseText)
+	}
+
+	if len(scenes) == 0 {
+		return nil, fmt.Errorf("no scenes generated")
+	}
+
+	return scenes, nil
+}
+
+// stripMarkdownCodeBlocks removes markdown code block formatting from a string
+// Handles cases like ```json ... ``` or ``` ... ```
+func stripMarkdownCodeBlocks(text string) string {
+	// Trim whitespace
+	text = strings.TrimSpace(text)
+
+	// Check if it starts with ``` and ends with ```
+	if strings.HasPrefix(text, "```") && strings.HasSuffix(text, "```") {
+		// Remove the opening ```
+		text = strings.TrimPrefix(text, "```")
+
+		// Remove language identifier if present (e.g., "json")
+		if idx := strings.Index(text, "\n"); idx != -1 {
+			text = text[idx+1:]
+		}
+
+		// Remove the closing ```
+		text = strings.TrimSuffix(text, "```")
+
+		// Trim any remaining whitespace
+		text = strings.TrimSpace(text)
+	}
+
+	return text
+}
@@ -0,0 +1,138 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestOpenAIService(t *testing.T, handler http.HandlerFunc) *OpenAIService {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewOpenAIServiceWithClient(srv.URL+"/v1/", "secret", "llama3", srv.Client())
+}
+
+func chatReply(content string) string {
+	body, _ := json.Marshal(map[string]any{
+		"choices": []map[string]any{{"message": map[string]string{"role": "assistant", "content": content}}},
+	})
+	return string(body)
+}
+
+func TestOpenAIServiceGenerateVideoScript(t *testing.T) {
+	var received chatCompletionRequest
+	service := newTestOpenAIService(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("Expected /v1/chat/completions, got %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Write([]byte(chatReply("  Octopuses have three hearts.\n")))
+	})
+
+	script, err := service.GenerateVideoScript(context.Background(), "octopuses")
+	if err != nil {
+		t.Fatalf("GenerateVideoScript returned error: %v", err)
+	}
+	if script != "Octopuses have three hearts." {
+		t.Errorf("Expected trimmed script, got %q", script)
+	}
+	if received.Model != "llama3" || len(received.Messages) != 1 || !strings.Contains(received.Messages[0].Content, "octopuses") {
+		t.Errorf("Server received unexpected request: %+v", received)
+	}
+}
+
+func TestOpenAIServiceGenerateScenes(t *testing.T) {
+	service := newTestOpenAIService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(chatReply("```json\n[{\"image_prompt\": \"a reef\", \"index\": 0}, {\"image_prompt\": \"a heart\", \"index\": 1}]\n```")))
+	})
+
+	scenes, err := service.GenerateScenes(context.Background(), "Octopuses have three hearts.")
+	if err != nil {
+		t.Fatalf("GenerateScenes returned error: %v", err)
+	}
+	if len(scenes) != 2 || scenes[0].ImagePrompt != "a reef" || scenes[1].Index != 1 {
+		t.Errorf("Unexpected scenes: %+v", scenes)
+	}
+}
+
+func TestOpenAIServiceFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "server error", status: http.StatusInternalServerError, body: `model not loaded`, wantErr: "status 500"},
+		{name: "API error", status: http.StatusOK, body: `{"error":{"message":"context length exceeded"}}`, wantErr: "context length exceeded"},
+		{name: "no choices", status: http.StatusOK, body: `{"choices":[]}`, wantErr: "no choices"},
+		{name: "empty script", status: http.StatusOK, body: chatReply(" "), wantErr: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestOpenAIService(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := service.GenerateVideoScript(context.Background(), "octopuses")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseScenePrompts(t *testing.T) {
+	if _, err := ParseScenePrompts("[]"); err == nil {
+		t.Error("Expected error for no scenes")
+	}
+	if _, err := ParseScenePrompts("Here are your scenes!"); err == nil {
+		t.Error("Expected error for non JSON response")
+	}
+	scenes, err := ParseScenePrompts("```\n[{\"image_prompt\": \"a reef\", \"index\": 0}]\n```")
+	if err != nil || len(scenes) != 1 {
+		t.Errorf("Expected one scene, got %+v (err: %v)", scenes, err)
+	}
+}
+
+func TestFakeLLM(t *testing.T) {
+	llm, err := NewLLM(context.Background(), "fake", "")
+	if err != nil {
+		t.Fatalf("NewLLM returned error: %v", err)
+	}
+
+	script, err := llm.GenerateVideoScript(context.Background(), "octopuses")
+	if err != nil {
+		t.Fatalf("GenerateVideoScript returned error: %v", err)
+	}
+	if !strings.Contains(script, "octopuses") {
+		t.Errorf("Expected script about the theme, got %q", script)
+	}
+
+	scenes, err := llm.GenerateScenes(context.Background(), script)
+	if err != nil {
+		t.Fatalf("GenerateScenes returned error: %v", err)
+	}
+	if len(scenes) != 3 {
+		t.Fatalf("Expected 3 scenes, got %d", len(scenes))
+	}
+	for i, scene := range scenes {
+		if scene.Index != i || scene.ImagePrompt == "" {
+			t.Errorf("Unexpected scene %d: %+v", i, scene)
+		}
+	}
+
+	if _, err := NewLLM(context.Background(), "missing", ""); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
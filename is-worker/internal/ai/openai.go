@@ -0,0 +1,158 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIBaseURL is used when OPENAI_BASE_URL is not set
+const DefaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+func init() {
+	RegisterLLM("openai", func(ctx context.Context, model string) (LLM, error) {
+		return NewOpenAIService(model)
+	})
+}
+
+// OpenAIService writes scripts and scenes through an OpenAI-compatible chat
+// completions API. Local servers such as Ollama or llama.cpp expose the same API.
+type OpenAIService struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIService creates a service from OPENAI_BASE_URL and OPENAI_API_KEY.
+// An empty model falls back to OPENAI_MODEL. The API key is optional because
+// local servers usually don't check it.
+func NewOpenAIService(model string) (*OpenAIService, error) {
+	if model == "" {
+		model = os.Getenv("OPENAI_MODEL")
+	}
+	if model == "" {
+		return nil, fmt.Errorf("no model given and OPENAI_MODEL environment variable not set")
+	}
+
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+
+	return NewOpenAIServiceWithClient(baseURL, os.Getenv("OPENAI_API_KEY"), model, &http.Client{
+		// Local models on CPU can take minutes to answer
+		Timeout: 5 * time.Minute,
+	}), nil
+}
+
+// NewOpenAIServiceWithClient creates a service for an explicit base URL and HTTP client
+func NewOpenAIServiceWithClient(baseURL string, apiKey string, model string, httpClient *http.Client) *OpenAIService {
+	return &OpenAIService{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: httpClient,
+	}
+}
+
+// chatMessage is a single message in a chat completions request or response
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// chatCompletionRequest represents the request body of the chat completions API
+type chatCompletionRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+}
+
+// chatCompletionResponse represents the response body of the chat completions API
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GenerateVideoScript generates a video script based on the theme
+func (s *OpenAIService) GenerateVideoScript(ctx context.Context, theme string) (string, error) {
+	script, err := s.complete(ctx, ScriptPrompt(theme))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	script = strings.TrimSpace(script)
+	if script == "" {
+		return "", fmt.Errorf("generated script is empty")
+	}
+
+	return script, nil
+}
+
+// GenerateScenes generates 2-3 scene prompts based on the video script
+func (s *OpenAIService) GenerateScenes(ctx context.Context, script string) ([]ScenePrompt, error) {
+	responseText, err := s.complete(ctx, ScenesPrompt(script))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate scenes: %w", err)
+	}
+
+	return ParseScenePrompts(responseText)
+}
+
+// complete sends prompt as a single user message and returns the reply
+func (s *OpenAIService) complete(ctx context.Context, prompt string) (string, error) {
+	jsonBody, err := json.Marshal(chatCompletionRequest{
+		Model:    s.model,
+		Messages: []chatMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/chat/completions", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", NewAPIError("chat completions", resp.StatusCode, resp.Header, string(body))
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(body, &completion); err != nil {
+		return "", fmt.Errorf("failed to decode chat completions response: %w", err)
+	}
+	if completion.Error != nil {
+		return "", fmt.Errorf("chat completions API returned error: %s", completion.Error.Message)
+	}
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("chat completions API returned no choices")
+	}
+
+	return completion.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,140 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultOpenAIImageModel is used when OPENAI_IMAGE_MODEL is not set
+const DefaultOpenAIImageModel = "dall-e-3"
+
+func init() {
+	RegisterImageGenerator("openai", func(ctx context.Context) (ImageGenerator, error) {
+		return NewOpenAIImageService()
+	})
+}
+
+// OpenAIImageService generates images through an OpenAI-compatible images API
+type OpenAIImageService struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// NewOpenAIImageService creates a service from OPENAI_BASE_URL, OPENAI_API_KEY
+// and OPENAI_IMAGE_MODEL
+func NewOpenAIImageService() (*OpenAIImageService, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("OPENAI_API_KEY environment variable not set")
+	}
+
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = DefaultOpenAIBaseURL
+	}
+
+	model := os.Getenv("OPENAI_IMAGE_MODEL")
+	if model == "" {
+		model = DefaultOpenAIImageModel
+	}
+
+	return NewOpenAIImageServiceWithClient(baseURL, apiKey, model, &http.Client{
+		Timeout: 2 * time.Minute,
+	}), nil
+}
+
+// NewOpenAIImageServiceWithClient creates a service for an explicit base URL and HTTP client
+func NewOpenAIImageServiceWithClient(baseURL string, apiKey string, model string, httpClient *http.Client) *OpenAIImageService {
+	return &OpenAIImageService{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: httpClient,
+	}
+}
+
+// imageGenerationRequest represents the request body of the images API
+type imageGenerationRequest struct {
+	Model          string `json:"model"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n"`
+	Size           string `json:"size"`
+	ResponseFormat string `json:"response_format"`
+}
+
+// imageGenerationResponse represents the response body of the images API
+type imageGenerationResponse struct {
+	Data []struct {
+		B64JSON string `json:"b64_json"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GenerateImage generates a vertical PNG image for prompt
+func (s *OpenAIImageService) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	jsonBody, err := json.Marshal(imageGenerationRequest{
+		Model:          s.model,
+		Prompt:         prompt,
+		N:              1,
+		Size:           "1024x1792", // Vertical format for short-form videos
+		ResponseFormat: "b64_json",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.baseURL+"/images/generations", bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, NewAPIError("images", resp.StatusCode, resp.Header, string(body))
+	}
+
+	var generation imageGenerationResponse
+	if err := json.Unmarshal(body, &generation); err != nil {
+		return nil, fmt.Errorf("failed to decode images response: %w", err)
+	}
+	if generation.Error != nil {
+		return nil, fmt.Errorf("images API returned error: %s", generation.Error.Message)
+	}
+	if len(generation.Data) == 0 {
+		return nil, fmt.Errorf("no images generated")
+	}
+
+	imageBytes, err := base64.StdEncoding.DecodeString(generation.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode generated image: %w", err)
+	}
+	if len(imageBytes) == 0 {
+		return nil, fmt.Errorf("generated image has no data")
+	}
+
+	return imageBytes, nil
+}
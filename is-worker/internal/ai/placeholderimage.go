@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+func init() {
+	RegisterImageGenerator("placeholder", func(ctx context.Context) (ImageGenerator, error) {
+		return &PlaceholderImageGenerator{}, nil
+	})
+}
+
+const (
+	// The prompt is drawn onto a small canvas with a bitmap font and scaled up,
+	// so the text stays legible at the final 1080x1920 size
+	placeholderWidth   = 270
+	placeholderHeight  = 480
+	placeholderScale   = 4
+	placeholderMargin  = 15
+	placeholderLineGap = 16
+)
+
+// PlaceholderImageGenerator draws the scene prompt onto a plain 9:16 canvas.
+// It never fails on a valid prompt, so it works as the last link of a fallback
+// chain and as an offline image provider.
+type PlaceholderImageGenerator struct{}
+
+// GenerateImage returns a 1080x1920 PNG with prompt written on it. The background
+// color is derived from the prompt, so identical prompts give identical images.
+func (p *PlaceholderImageGenerator) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return nil, fmt.Errorf("prompt cannot be empty: %w", ErrInvalidInput)
+	}
+
+	face := basicfont.Face7x13
+	canvas := image.NewRGBA(image.Rect(0, 0, placeholderWidth, placeholderHeight))
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(placeholderBackground(prompt)), image.Point{}, draw.Src)
+
+	maxChars := (placeholderWidth - 2*placeholderMargin) / face.Advance
+	maxLines := (placeholderHeight - 2*placeholderMargin) / placeholderLineGap
+	lines := wrapText(prompt, maxChars)
+	if len(lines) > maxLines {
+		lines = lines[:maxLines]
+		lines[maxLines-1] = truncateLine(lines[maxLines-1], maxChars)
+	}
+
+	// Center the block of text vertically and each line horizontally
+	drawer := &font.Drawer{Dst: canvas, Src: image.White, Face: face}
+	top := (placeholderHeight-len(lines)*placeholderLineGap)/2 + face.Ascent
+	for i, line := range lines {
+		width := drawer.MeasureString(line).Round()
+		drawer.Dot = fixed.P((placeholderWidth-width)/2, top+i*placeholderLineGap)
+		drawer.DrawString(line)
+	}
+
+	scaled := image.NewRGBA(image.Rect(0, 0, placeholderWidth*placeholderScale, placeholderHeight*placeholderScale))
+	draw.NearestNeighbor.Scale(scaled, scaled.Bounds(), canvas, canvas.Bounds(), draw.Src, nil)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, scaled); err != nil {
+		return nil, fmt.Errorf("failed to encode placeholder image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// placeholderBackground picks a dark color from the prompt hash so white text stays readable
+func placeholderBackground(prompt string) color.RGBA {
+	h := fnv.New32a()
+	h.Write([]byte(prompt))
+	sum := h.Sum32()
+	return color.RGBA{R: uint8(sum>>16) / 2, G: uint8(sum>>8) / 2, B: uint8(sum) / 2, A: 0xFF}
+}
+
+// wrapText splits text into lines of at most maxChars, breaking words that don't fit
+func wrapText(text string, maxChars int) []string {
+	var lines []string
+	var line []rune
+	for _, field := range strings.Fields(text) {
+		word := []rune(field)
+		for len(word) > maxChars {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+			}
+			lines = append(lines, string(word[:maxChars]))
+			word = word[maxChars:]
+		}
+		switch {
+		case len(line) == 0:
+			line = word
+		case len(line)+1+len(word) <= maxChars:
+			line = append(append(line, ' '), word...)
+		default:
+			lines = append(lines, string(line))
+			line = word
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// truncateLine marks line as cut off, keeping it within maxChars
+func truncateLine(line string, maxChars int) string {
+	runes := []rune(line)
+	if len(runes)+3 > maxChars {
+		runes = runes[:maxChars-3]
+	}
+	return string(runes) + "..."
+}
@@ -1,6 +1,8 @@
 package ai
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -55,61 +57,94 @@ func (s *SpeechToTextService) Close() error {
 // GenerateCaptionsFromURL generates word-level captions from an audio file URL
 // The audio file should be accessible via HTTP/HTTPS (e.g., from S3)
 func (s *SpeechToTextService) GenerateCaptionsFromURL(ctx context.Context, audioURL string) ([]CaptionWord, error) {
-	// Download the audio file
-	audioData, err := downloadAudioFile(ctx, audioURL)
+	// Open the audio file; it is streamed to the API as it downloads
+	audio, err := openAudioURL(ctx, audioURL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to download audio file: %w", err)
 	}
+	defer audio.Close()
 
-	return s.GenerateCaptions(ctx, audioData)
+	return s.GenerateCaptions(ctx, audio)
 }
 
-// GenerateCaptions generates word-level captions from audio data
-func (s *SpeechToTextService) GenerateCaptions(ctx context.Context, audioData []byte) ([]CaptionWord, error) {
-	// Create recognition request
-	req := &speechpb.RecognizeRequest{
-		Config: &speechpb.RecognitionConfig{
-			Encoding:                   speechpb.RecognitionConfig_MP3,
-			SampleRateHertz:            0, // Auto-detect
-			LanguageCode:               "en-US",
-			EnableWordTimeOffsets:      true, // This is key for word-level timestamps
-			EnableAutomaticPunctuation: true,
-			Model:                      "latest_long", // Best for longer audio
-		},
-		Audio: &speechpb.RecognitionAudio{
-			AudioSource: &speechpb.RecognitionAudio_Content{
-				Content: audioData,
+// GenerateCaptions generates word-level captions from an audio stream. The
+// audio is sent in chunks while results come back, so it is never held in memory.
+func (s *SpeechToTextService) GenerateCaptions(ctx context.Context, audio io.Reader) ([]CaptionWord, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := s.client.StreamingRecognize(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recognition stream: %w", err)
+	}
+	return recognizeStream(stream, audio, cancel)
+}
+
+// streamChunkSize keeps each streamed request under the API's 25 KB audio limit
+const streamChunkSize = 16 * 1024
+
+// recognitionStream is the part of the StreamingRecognize client the captions use
+type recognitionStream interface {
+	Send(*speechpb.StreamingRecognizeRequest) error
+	Recv() (*speechpb.StreamingRecognizeResponse, error)
+	CloseSend() error
+}
+
+// recognizeStream sends the recognition config and then audio to stream, and
+// collects the words of every final result. cancel must cancel the stream's
+// context; it is used to stop sending when receiving fails.
+func recognizeStream(stream recognitionStream, audio io.Reader, cancel context.CancelFunc) ([]CaptionWord, error) {
+	// Pick the encoding from the header without consuming it
+	reader := bufio.NewReaderSize(audio, streamChunkSize)
+	header, _ := reader.Peek(12)
+
+	if err := stream.Send(&speechpb.StreamingRecognizeRequest{
+		StreamingRequest: &speechpb.StreamingRecognizeRequest_StreamingConfig{
+			StreamingConfig: &speechpb.StreamingRecognitionConfig{
+				Config: &speechpb.RecognitionConfig{
+					Encoding:                   detectAudioEncoding(header),
+					SampleRateHertz:            0, // Auto-detect
+					LanguageCode:               "en-US",
+					EnableWordTimeOffsets:      true, // This is key for word-level timestamps
+					EnableAutomaticPunctuation: true,
+					Model:                      "latest_long", // Best for longer audio
+				},
 			},
 		},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to send recognition config: %w", err)
 	}
 
-	// Perform recognition
-	resp, err := s.client.Recognize(ctx, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to recognize speech: %w", err)
-	}
+	// Send audio while results are received, so the stream never backs up
+	sent := make(chan error, 1)
+	go func() {
+		sent <- sendAudio(stream, reader)
+	}()
 
-	// Extract word-level timestamps
 	var captions []CaptionWord
-	for _, result := range resp.Results {
-		// Use the first (most confident) alternative
-		if len(result.Alternatives) == 0 {
-			continue
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cancel()
+			<-sent
+			return nil, fmt.Errorf("failed to recognize speech: %w", err)
 		}
 
-		alternative := result.Alternatives[0]
-		for _, wordInfo := range alternative.Words {
-			// Convert from protobuf Duration to float64 seconds
-			startTime := float64(wordInfo.StartTime.Seconds) + float64(wordInfo.StartTime.Nanos)/1e9
-			endTime := float64(wordInfo.EndTime.Seconds) + float64(wordInfo.EndTime.Nanos)/1e9
-
-			captions = append(captions, CaptionWord{
-				Word:      wordInfo.Word,
-				StartTime: startTime,
-				EndTime:   endTime,
-			})
+		for _, result := range resp.Results {
+			// Interim results are revised later; only final ones have stable timings
+			if !result.IsFinal || len(result.Alternatives) == 0 {
+				continue
+			}
+			// Use the first (most confident) alternative
+			captions = append(captions, captionWords(result.Alternatives[0].Words)...)
 		}
 	}
+	if err := <-sent; err != nil {
+		return nil, err
+	}
 
 	if len(captions) == 0 {
 		return nil, fmt.Errorf("no captions generated from audio")
@@ -118,6 +153,61 @@ func (s *SpeechToTextService) GenerateCaptions(ctx context.Context, audioData []
 	return captions, nil
 }
 
+// sendAudio streams audio to stream in chunks and half-closes it at the end
+func sendAudio(stream recognitionStream, audio io.Reader) error {
+	for {
+		// gRPC may hold on to a sent message, so every chunk gets its own buffer
+		buf := make([]byte, streamChunkSize)
+		n, err := io.ReadFull(audio, buf)
+		if n > 0 {
+			if sendErr := stream.Send(&speechpb.StreamingRecognizeRequest{
+				StreamingRequest: &speechpb.StreamingRecognizeRequest_AudioContent{
+					AudioContent: buf[:n],
+				},
+			}); sendErr != nil {
+				return fmt.Errorf("failed to send audio: %w", sendErr)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audio: %w", err)
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return fmt.Errorf("failed to close audio stream: %w", err)
+	}
+	return nil
+}
+
+// captionWords converts recognized words to captions with times in seconds
+func captionWords(words []*speechpb.WordInfo) []CaptionWord {
+	captions := make([]CaptionWord, 0, len(words))
+	for _, wordInfo := range words {
+		// Convert from protobuf Duration to float64 seconds
+		startTime := float64(wordInfo.StartTime.Seconds) + float64(wordInfo.StartTime.Nanos)/1e9
+		endTime := float64(wordInfo.EndTime.Seconds) + float64(wordInfo.EndTime.Nanos)/1e9
+
+		captions = append(captions, CaptionWord{
+			Word:      wordInfo.Word,
+			StartTime: startTime,
+			EndTime:   endTime,
+		})
+	}
+	return captions
+}
+
+// detectAudioEncoding picks the recognition encoding from the audio header.
+// WAV files carry their own encoding and sample rate, so they are left unspecified.
+func detectAudioEncoding(audioData []byte) speechpb.RecognitionConfig_AudioEncoding {
+	if len(audioData) >= 12 && bytes.Equal(audioData[0:4], []byte("RIFF")) && bytes.Equal(audioData[8:12], []byte("WAVE")) {
+		return speechpb.RecognitionConfig_ENCODING_UNSPECIFIED
+	}
+	return speechpb.RecognitionConfig_MP3
+}
+
 // CaptionsToJSON converts captions to JSON string
 func CaptionsToJSON(captions []CaptionWord) (string, error) {
 	jsonData, err := json.Marshal(captions)
@@ -127,8 +217,9 @@ func CaptionsToJSON(captions []CaptionWord) (string, error) {
 	return string(jsonData), nil
 }
 
-// downloadAudioFile downloads an audio file from a URL
-func downloadAudioFile(ctx context.Context, url string) ([]byte, error) {
+// openAudioURL starts downloading an audio file from a URL. The caller must
+// close the returned body.
+func openAudioURL(ctx context.Context, url string) (io.ReadCloser, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -138,16 +229,11 @@ func downloadAudioFile(ctx context.Context, url string) ([]byte, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to download file: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	return data, nil
+	return resp.Body, nil
 }
@@ -0,0 +1,121 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/speech/apiv1/speechpb"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeRecognitionStream records what is sent and answers with responses once
+// the audio has been half-closed, like the API does for a finished file
+type fakeRecognitionStream struct {
+	ctx       context.Context
+	responses []*speechpb.StreamingRecognizeResponse
+	recvErr   error
+
+	mu       sync.Mutex
+	requests []*speechpb.StreamingRecognizeRequest
+	closed   chan struct{}
+}
+
+func (f *fakeRecognitionStream) Send(req *speechpb.StreamingRecognizeRequest) error {
+	if err := f.ctx.Err(); err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, req)
+	return nil
+}
+
+func (f *fakeRecognitionStream) CloseSend() error {
+	close(f.closed)
+	return nil
+}
+
+func (f *fakeRecognitionStream) Recv() (*speechpb.StreamingRecognizeResponse, error) {
+	if f.recvErr != nil {
+		return nil, f.recvErr
+	}
+	<-f.closed
+	if len(f.responses) == 0 {
+		return nil, io.EOF
+	}
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	return resp, nil
+}
+
+func word(text string, start float64, end float64) *speechpb.WordInfo {
+	seconds := func(s float64) *durationpb.Duration {
+		return &durationpb.Duration{Seconds: int64(s), Nanos: int32((s - float64(int64(s))) * 1e9)}
+	}
+	return &speechpb.WordInfo{Word: text, StartTime: seconds(start), EndTime: seconds(end)}
+}
+
+func TestRecognizeStream(t *testing.T) {
+	fake := &FakeTextToSpeech{Format: "wav", ToneHz: 440, WordsPerMinute: 150}
+	audio, err := fake.GenerateAudio(context.Background(), "a few words of narration", "voice", VoiceSettings{})
+	if err != nil {
+		t.Fatalf("GenerateAudio returned error: %v", err)
+	}
+	want := readAudio(t, audio)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeRecognitionStream{
+		ctx:    ctx,
+		closed: make(chan struct{}),
+		responses: []*speechpb.StreamingRecognizeResponse{
+			{Results: []*speechpb.StreamingRecognitionResult{{
+				Alternatives: []*speechpb.SpeechRecognitionAlternative{{Words: []*speechpb.WordInfo{word("interim", 0, 0.4)}}},
+			}}},
+			{Results: []*speechpb.StreamingRecognitionResult{{
+				IsFinal:      true,
+				Alternatives: []*speechpb.SpeechRecognitionAlternative{{Words: []*speechpb.WordInfo{word("A", 0, 0.2), word("few", 0.2, 0.5)}}},
+			}}},
+		},
+	}
+
+	captions, err := recognizeStream(stream, bytes.NewReader(want), cancel)
+	if err != nil {
+		t.Fatalf("recognizeStream returned error: %v", err)
+	}
+	if len(captions) != 2 || captions[0].Word != "A" || captions[1].Word != "few" || captions[1].StartTime != 0.2 || captions[1].EndTime != 0.5 {
+		t.Errorf("Expected the final words only, got %+v", captions)
+	}
+
+	config := stream.requests[0].GetStreamingConfig()
+	if config == nil || config.Config.Encoding != speechpb.RecognitionConfig_ENCODING_UNSPECIFIED {
+		t.Fatalf("Expected a WAV recognition config first, got %v", stream.requests[0])
+	}
+	var sent []byte
+	for _, req := range stream.requests[1:] {
+		chunk := req.GetAudioContent()
+		if len(chunk) == 0 || len(chunk) > streamChunkSize {
+			t.Fatalf("Expected audio chunks of at most %d bytes, got %d", streamChunkSize, len(chunk))
+		}
+		sent = append(sent, chunk...)
+	}
+	if !bytes.Equal(sent, want) {
+		t.Errorf("Expected the whole audio to be streamed, got %d of %d bytes", len(sent), len(want))
+	}
+}
+
+func TestRecognizeStreamReceiveError(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream := &fakeRecognitionStream{ctx: ctx, closed: make(chan struct{}), recvErr: errors.New("quota exceeded")}
+
+	// An endless reader stops being sent once receiving fails
+	_, err := recognizeStream(stream, &repeatReader{data: silentMP3Frame, size: 1 << 40}, cancel)
+	if err == nil || !errors.Is(err, stream.recvErr) {
+		t.Errorf("Expected the receive error, got %v", err)
+	}
+}
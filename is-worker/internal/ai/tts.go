@@ -0,0 +1,77 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultTTSProvider is used when TTS_PROVIDER is not set
+const DefaultTTSProvider = "elevenlabs"
+
+// Audio content types produced by TextToSpeech providers
+const (
+	AudioContentTypeMP3 = "audio/mpeg"
+	AudioContentTypeWAV = "audio/wav"
+)
+
+// Audio is narration produced by a TextToSpeech provider. Body streams the
+// encoded audio as the provider produces it and must be closed by the caller.
+type Audio struct {
+	Body        io.ReadCloser
+	ContentType string
+}
+
+// TextToSpeech turns a video script into narration audio
+type TextToSpeech interface {
+	GenerateAudio(ctx context.Context, text string, voiceID string, settings VoiceSettings) (*Audio, error)
+}
+
+// TextToSpeechFactory creates a TextToSpeech provider from its environment configuration
+type TextToSpeechFactory func(ctx context.Context) (TextToSpeech, error)
+
+var (
+	ttsMu        sync.RWMutex
+	ttsProviders = map[string]TextToSpeechFactory{}
+)
+
+// RegisterTextToSpeech makes a TextToSpeech provider available under name
+func RegisterTextToSpeech(name string, factory TextToSpeechFactory) {
+	ttsMu.Lock()
+	defer ttsMu.Unlock()
+	if _, exists := ttsProviders[name]; exists {
+		panic(fmt.Sprintf("ai: text-to-speech provider %q registered twice", name))
+	}
+	ttsProviders[name] = factory
+}
+
+// TextToSpeechProviders returns the names of every registered provider
+func TextToSpeechProviders() []string {
+	ttsMu.RLock()
+	defer ttsMu.RUnlock()
+	names := make([]string, 0, len(ttsProviders))
+	for name := range ttsProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewTextToSpeech creates the TextToSpeech provider registered under name
+func NewTextToSpeech(ctx context.Context, name string) (TextToSpeech, error) {
+	ttsMu.RLock()
+	factory, ok := ttsProviders[name]
+	ttsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown text-to-speech provider %q (available: %s)", name, strings.Join(TextToSpeechProviders(), ", "))
+	}
+
+	provider, err := factory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s text-to-speech provider: %w", name, err)
+	}
+	return provider, nil
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/repository"
+)
+
+// errVideoCancelled stops a task whose video was cancelled or deleted. It is
+// recorded on the pipeline step but not returned to asynq, so the task isn't retried.
+var errVideoCancelled = errors.New("video was cancelled")
+
+// ensureVideoActive returns errVideoCancelled when the video was cancelled or
+// deleted. Handlers call it right before every paid external call.
+func ensureVideoActive(ctx context.Context, videos repository.VideoStore, videoID int) error {
+	video, err := videos.GetVideo(ctx, videoID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return fmt.Errorf("video %d no longer exists: %w", videoID, errVideoCancelled)
+	case err != nil:
+		return fmt.Errorf("failed to check video status: %w", err)
+	case video.DeletedAt.Valid:
+		return fmt.Errorf("video %d was deleted: %w", videoID, errVideoCancelled)
+	case video.Status == models.VideoStatusCancelled:
+		return fmt.Errorf("video %d: %w", videoID, errVideoCancelled)
+	}
+	return nil
+}
+
+// skipCancelled turns errVideoCancelled into success for asynq, logging why the task stopped
+func skipCancelled(err error) error {
+	if errors.Is(err, errVideoCancelled) {
+		log.Printf("Stopping task: %v", err)
+		return nil
+	}
+	return err
+}
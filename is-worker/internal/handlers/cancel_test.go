@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+)
+
+func TestEnsureVideoActive(t *testing.T) {
+	deletedAt := time.Now()
+	tests := []struct {
+		name      string
+		video     *models.Video
+		cancelled bool
+	}{
+		{name: "video in flight", video: &models.Video{ID: 1, Status: "generating_audio"}, cancelled: false},
+		{name: "failed video waiting to retry", video: &models.Video{ID: 1, Status: "failed"}, cancelled: false},
+		{name: "cancelled video", video: &models.Video{ID: 1, Status: "cancelled"}, cancelled: true},
+		{name: "soft-deleted video", video: &models.Video{ID: 1, Status: "generating_audio", DeletedAt: gorm.DeletedAt{Time: deletedAt, Valid: true}}, cancelled: true},
+		{name: "missing video", cancelled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videos := repository.NewMemoryVideoStore()
+			if tt.video != nil {
+				videos.AddVideo(*tt.video)
+			}
+
+			err := ensureVideoActive(context.Background(), videos, 1)
+			if got := errors.Is(err, errVideoCancelled); got != tt.cancelled {
+				t.Errorf("Expected cancelled=%v, got error %v", tt.cancelled, err)
+			}
+			if !tt.cancelled && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+type countingScriptWriter struct {
+	calls int
+}
+
+func (w *countingScriptWriter) GenerateVideoScript(ctx context.Context, theme string) (string, error) {
+	w.calls++
+	return "script", nil
+}
+
+func TestGenerateVideoScriptSkipsCancelledVideo(t *testing.T) {
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Theme: "reefs", Status: "cancelled"})
+
+	writer := &countingScriptWriter{}
+	payload, _ := json.Marshal(queue.GenerateVideoScriptPayload{VideoID: 1})
+	handler := NewHandleGenerateVideoScript(videos, writer)
+
+	// A cancelled video isn't a failure, so asynq must not retry the task
+	if err := handler(context.Background(), asynq.NewTask(queue.TypeGenerateVideoScript, payload)); err != nil {
+		t.Fatalf("Expected the task to stop without error, got %v", err)
+	}
+	if writer.calls != 0 {
+		t.Errorf("Expected no script to be generated, got %d calls", writer.calls)
+	}
+
+	if video := getVideo(t, videos, 1); video.Status != "cancelled" {
+		t.Errorf("Expected status to stay cancelled, got %s", video.Status)
+	}
+}
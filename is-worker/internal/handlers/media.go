@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
+)
+
+// storedMedia locates a generated artifact. Rows written before object keys
+// were stored only have the signed URL the worker saved at the time.
+type storedMedia struct {
+	Bucket    *string
+	Key       *string
+	LegacyURL *string
+}
+
+// exists reports whether the artifact was generated
+func (m storedMedia) exists() bool {
+	return !isBlank(m.Key) || !isBlank(m.LegacyURL)
+}
+
+// url returns a read URL valid for ttl, signing a fresh one when the key is known
+func (m storedMedia) url(ctx context.Context, store storage.BlobStore, ttl time.Duration) (string, error) {
+	if !isBlank(m.Key) {
+		return storage.SignObject(ctx, store, deref(m.Bucket), *m.Key, ttl)
+	}
+	if !isBlank(m.LegacyURL) {
+		return *m.LegacyURL, nil
+	}
+	return "", fmt.Errorf("media has not been generated")
+}
+
+// renderPayload builds the render task of a video with its media URLs signed
+// for urlTTL, since the renderer service can't sign them itself
+func renderPayload(ctx context.Context, videos repository.VideoStore, store storage.BlobStore, urlTTL time.Duration, videoID int) (queue.RenderVideoPayload, error) {
+	payload := queue.RenderVideoPayload{VideoID: videoID}
+
+	video, err := videos.GetVideo(ctx, videoID)
+	if err != nil {
+		return payload, fmt.Errorf("failed to fetch video: %w", err)
+	}
+	audio := storedMedia{Bucket: video.AudioBucket, Key: video.AudioKey, LegacyURL: video.AudioURL}
+	if payload.AudioURL, err = audio.url(ctx, store, urlTTL); err != nil {
+		return payload, fmt.Errorf("failed to sign audio URL: %w", err)
+	}
+
+	scenes, err := videos.ListScenes(ctx, videoID)
+	if err != nil {
+		return payload, fmt.Errorf("failed to fetch video scenes: %w", err)
+	}
+	for _, scene := range scenes {
+		image := storedMedia{Bucket: scene.ImageBucket, Key: scene.ImageKey, LegacyURL: scene.ImageURL}
+		imageURL, err := image.url(ctx, store, urlTTL)
+		if err != nil {
+			return payload, fmt.Errorf("failed to sign image URL for scene %d: %w", scene.Index, err)
+		}
+		payload.SceneImages = append(payload.SceneImages, queue.RenderSceneImage{SceneID: scene.ID, ImageURL: imageURL})
+	}
+	return payload, nil
+}
+
+func isBlank(s *string) bool {
+	return s == nil || *s == ""
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
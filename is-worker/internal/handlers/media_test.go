@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"instashorts-be/is-worker/internal/ai"
+	"instashorts-be/pkg/storage"
+)
+
+// workerConcurrency matches the asynq Concurrency the worker runs with
+const workerConcurrency = 10
+
+// BenchmarkAudioStreaming streams narration from the fake TTS into storage and
+// back out, like the audio and captions handlers do, for workerConcurrency
+// videos at a time. peak-heap-MB should stay flat as the narration gets
+// longer, since no stage holds a whole file in memory.
+func BenchmarkAudioStreaming(b *testing.B) {
+	for _, minutes := range []int{1, 5, 20} {
+		b.Run(fmt.Sprintf("%dmin", minutes), func(b *testing.B) {
+			store, err := storage.NewLocalStoreWithConfig(b.TempDir(), "http://localhost/files", []byte("secret"))
+			if err != nil {
+				b.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+			}
+			media := storage.NewMedia(store)
+			tts := &ai.FakeTextToSpeech{Format: "wav", WordsPerMinute: 150}
+			script := strings.Repeat("word ", 150*minutes)
+
+			var videoID atomic.Int64
+			peak := trackPeakHeap()
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				errs := make(chan error, workerConcurrency)
+				for j := 0; j < workerConcurrency; j++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs <- streamAudio(context.Background(), tts, media, store, script, int(videoID.Add(1)))
+					}()
+				}
+				wg.Wait()
+				close(errs)
+				for err := range errs {
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(peak())/(1<<20), "peak-heap-MB")
+		})
+	}
+}
+
+// streamAudio generates, uploads, reads back and deletes one video's narration
+func streamAudio(ctx context.Context, tts ai.TextToSpeech, media *storage.Media, store storage.BlobStore, script string, videoID int) error {
+	audio, err := tts.GenerateAudio(ctx, script, "voice", ai.VoiceSettings{})
+	if err != nil {
+		return fmt.Errorf("failed to generate audio: %w", err)
+	}
+	defer audio.Body.Close()
+
+	key, err := media.UploadAudio(ctx, audio.Body, videoID, audio.ContentType)
+	if err != nil {
+		return err
+	}
+
+	rc, err := store.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download audio: %w", err)
+	}
+	_, err = io.Copy(io.Discard, rc)
+	rc.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read audio: %w", err)
+	}
+
+	return store.Delete(ctx, key)
+}
+
+// trackPeakHeap samples the live heap until the returned function is called,
+// which reports the highest value seen
+func trackPeakHeap() func() uint64 {
+	runtime.GC()
+	var peak atomic.Uint64
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		var stats runtime.MemStats
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapAlloc > peak.Load() {
+				peak.Store(stats.HeapAlloc)
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() uint64 {
+		close(done)
+		<-stopped
+		return peak.Load()
+	}
+}
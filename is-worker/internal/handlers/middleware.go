@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+
+	"github.com/hibiken/asynq"
+)
+
+// Middleware returns the middleware every task runs through, outermost first:
+// logging with timing, failure marking on the final attempt, and panic recovery.
+// Recovery is innermost so a panic is logged and marked like any other error.
+func Middleware(videos repository.VideoStore) []asynq.MiddlewareFunc {
+	return []asynq.MiddlewareFunc{
+		LogTasks,
+		MarkFailedOnFinalAttempt(videos),
+		RecoverPanics,
+	}
+}
+
+// taskSubject holds the payload fields that tie a task to a video
+type taskSubject struct {
+	VideoID *int `json:"video_id"`
+	SceneID *int `json:"scene_id"`
+}
+
+// decodeTaskSubject reads the video or scene a task works on and its metadata.
+// Tasks that don't decode, like ones from a newer producer, have neither.
+func decodeTaskSubject(t *asynq.Task) (taskSubject, queue.TaskMeta) {
+	var subject taskSubject
+	meta, err := queue.DecodePayload(t.Payload(), &subject)
+	if err != nil {
+		return taskSubject{}, meta
+	}
+	return subject, meta
+}
+
+// isFinalAttempt reports whether asynq archives the task after it fails with
+// err, instead of retrying it
+func isFinalAttempt(ctx context.Context, err error) bool {
+	if errors.Is(err, asynq.SkipRetry) {
+		return true
+	}
+	retried, ok := asynq.GetRetryCount(ctx)
+	if !ok {
+		return false
+	}
+	maxRetry, ok := asynq.GetMaxRetry(ctx)
+	return ok && retried >= maxRetry
+}
+
+// LogTasks logs the start and outcome of every task with its duration
+func LogTasks(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		attrs := taskAttrs(ctx, t)
+		slog.Info("task started", attrs...)
+
+		start := time.Now()
+		err := next.ProcessTask(ctx, t)
+		attrs = append(attrs, slog.Int64("duration_ms", time.Since(start).Milliseconds()))
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()), slog.Bool("final", isFinalAttempt(ctx, err)))
+			slog.Error("task failed", attrs...)
+			return err
+		}
+
+		slog.Info("task succeeded", attrs...)
+		return nil
+	})
+}
+
+// taskAttrs returns the log attributes that identify a task run
+func taskAttrs(ctx context.Context, t *asynq.Task) []any {
+	attrs := []any{slog.String("type", t.Type())}
+	if taskID, ok := asynq.GetTaskID(ctx); ok {
+		attrs = append(attrs, slog.String("task_id", taskID))
+	}
+	if qname, ok := asynq.GetQueueName(ctx); ok {
+		attrs = append(attrs, slog.String("queue", qname))
+	}
+	if retried, ok := asynq.GetRetryCount(ctx); ok {
+		attrs = append(attrs, slog.Int("attempt", retried+1))
+	}
+
+	subject, meta := decodeTaskSubject(t)
+	if meta.TraceID != "" {
+		attrs = append(attrs, slog.String("trace_id", meta.TraceID))
+	}
+	if subject.VideoID != nil {
+		attrs = append(attrs, slog.Int("video_id", *subject.VideoID))
+	}
+	if subject.SceneID != nil {
+		attrs = append(attrs, slog.Int("scene_id", *subject.SceneID))
+	}
+	return attrs
+}
+
+// MarkFailedOnFinalAttempt marks the video of a task failed, with the error as
+// its failure_reason, once the task has no retries left. Earlier failures leave
+// the video alone, since a retry may still succeed. Scene image tasks also mark
+// their scene failed.
+func MarkFailedOnFinalAttempt(videos repository.VideoStore) asynq.MiddlewareFunc {
+	return func(next asynq.Handler) asynq.Handler {
+		return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+			err := next.ProcessTask(ctx, t)
+			if err == nil || !isFinalAttempt(ctx, err) {
+				return err
+			}
+
+			subject, _ := decodeTaskSubject(t)
+			if markErr := markTaskFailed(ctx, videos, subject, fmt.Sprintf("%s: %v", t.Type(), err)); markErr != nil {
+				log.Printf("ERROR: Failed to mark %s task as failed: %v", t.Type(), markErr)
+			}
+			return err
+		})
+	}
+}
+
+// markTaskFailed marks the video, and the scene if any, of a failed task
+func markTaskFailed(ctx context.Context, videos repository.VideoStore, subject taskSubject, reason string) error {
+	// The task context may already be cancelled or past its deadline
+	ctx = context.WithoutCancel(ctx)
+
+	videoID := subject.VideoID
+	if subject.SceneID != nil {
+		scene, err := videos.GetScene(ctx, *subject.SceneID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch scene %d: %w", *subject.SceneID, err)
+		}
+		if err := videos.SetSceneStatus(ctx, scene.ID, models.SceneStatusFailed); err != nil {
+			return fmt.Errorf("failed to update scene status: %w", err)
+		}
+		videoID = &scene.VideoID
+	}
+
+	if videoID == nil {
+		return nil
+	}
+	return markVideoFailed(ctx, videos, *videoID, reason)
+}
+
+// markVideoFailed moves a video to failed with reason, unless it was cancelled
+func markVideoFailed(ctx context.Context, videos repository.VideoStore, videoID int, reason string) error {
+	if _, err := videos.MarkVideoFailed(ctx, videoID, reason); err != nil {
+		return fmt.Errorf("failed to mark video %d as failed: %w", videoID, err)
+	}
+	log.Printf("Marked video_id=%d as failed: %s", videoID, reason)
+	return nil
+}
+
+// RecoverPanics turns a panicking handler into a failed task, logging the stack
+func RecoverPanics(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("ERROR: Panic in %s handler: %v\n%s", t.Type(), r, debug.Stack())
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		return next.ProcessTask(ctx, t)
+	})
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+
+	"github.com/hibiken/asynq"
+)
+
+func newTestTask(t *testing.T, payload queue.Payload) *asynq.Task {
+	t.Helper()
+	task, err := queue.NewTask(context.Background(), payload)
+	if err != nil {
+		t.Fatalf("NewTask returned error: %v", err)
+	}
+	return task
+}
+
+func failingHandler(err error) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		return err
+	})
+}
+
+func TestMarkFailedOnFinalAttempt(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus string
+	}{
+		{name: "retries left", err: errors.New("provider timeout"), expectedStatus: "generating_audio"},
+		{name: "retries skipped", err: fmt.Errorf("video has no script: %w", asynq.SkipRetry), expectedStatus: "failed"},
+		{name: "succeeded", expectedStatus: "generating_audio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videos := repository.NewMemoryVideoStore()
+			videos.AddVideo(models.Video{ID: 1, Status: "generating_audio"})
+
+			handler := MarkFailedOnFinalAttempt(videos)(failingHandler(tt.err))
+			if err := handler.ProcessTask(context.Background(), newTestTask(t, queue.GenerateAudioPayload{VideoID: 1})); !errors.Is(err, tt.err) {
+				t.Errorf("Expected the handler's error to pass through, got %v", err)
+			}
+
+			video := getVideo(t, videos, 1)
+			if string(video.Status) != tt.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tt.expectedStatus, video.Status)
+			}
+			if tt.expectedStatus == "failed" && (video.FailureReason == nil || !strings.Contains(*video.FailureReason, "video has no script")) {
+				t.Errorf("Expected the error as failure reason, got %v", video.FailureReason)
+			}
+		})
+	}
+}
+
+func TestMarkTaskFailedThroughScene(t *testing.T) {
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Status: "generating_images"})
+	videos.AddVideo(models.Video{ID: 2, Status: models.VideoStatusCancelled})
+	videos.AddScene(models.VideoScene{ID: 7, VideoID: 1, Status: "generating"})
+	videos.AddScene(models.VideoScene{ID: 8, VideoID: 2, Status: "generating"})
+
+	for _, sceneID := range []int{7, 8} {
+		if err := markTaskFailed(context.Background(), videos, taskSubject{SceneID: &sceneID}, "image failed"); err != nil {
+			t.Fatalf("markTaskFailed returned error: %v", err)
+		}
+	}
+
+	scene, _ := videos.GetScene(context.Background(), 7)
+	video := getVideo(t, videos, 1)
+	cancelled := getVideo(t, videos, 2)
+	if scene.Status != "failed" || video.Status != "failed" {
+		t.Errorf("Expected the scene and its video to fail, got scene=%s video=%s", scene.Status, video.Status)
+	}
+	if cancelled.Status != models.VideoStatusCancelled {
+		t.Errorf("Expected a cancelled video to stay cancelled, got %s", cancelled.Status)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Status: "rendering"})
+
+	panicking := asynq.HandlerFunc(func(ctx context.Context, t *asynq.Task) error {
+		var scenes []int
+		_ = scenes[3]
+		return nil
+	})
+	mux := asynq.NewServeMux()
+	mux.Use(Middleware(videos)...)
+	mux.Handle(queue.TypeRenderVideo, panicking)
+
+	err := mux.ProcessTask(context.Background(), newTestTask(t, queue.RenderVideoPayload{VideoID: 1}))
+	if err == nil || !strings.Contains(err.Error(), "panic: runtime error: index out of range") {
+		t.Fatalf("Expected the panic as an error, got %v", err)
+	}
+
+	// Outside asynq the retry count is unknown, so the video isn't failed yet
+	if video := getVideo(t, videos, 1); video.Status != "rendering" {
+		t.Errorf("Expected the video to wait for its retries, got %s", video.Status)
+	}
+}
@@ -0,0 +1,355 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
+
+	"github.com/hibiken/asynq"
+)
+
+// maxVideoRecoveries is how many times the reaper re-queues the missing steps
+// of a video before it gives up and fails it
+const maxVideoRecoveries = 3
+
+// DefaultStuckVideoTimeouts is how long a video may go without pipeline
+// activity in each non-terminal status before the reaper looks at it
+var DefaultStuckVideoTimeouts = map[string]time.Duration{
+	"pending":           15 * time.Minute,
+	"generating_script": 15 * time.Minute,
+	"generating_audio":  15 * time.Minute,
+	"generating_scenes": 15 * time.Minute,
+	"generating_images": 30 * time.Minute,
+	"ready_to_render":   15 * time.Minute,
+	"rendering":         time.Hour,
+}
+
+// ParseStuckVideoTimeouts overrides DefaultStuckVideoTimeouts with a
+// comma-separated list of status=duration pairs, e.g. "rendering=2h,pending=5m"
+func ParseStuckVideoTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration, len(DefaultStuckVideoTimeouts))
+	for status, timeout := range DefaultStuckVideoTimeouts {
+		timeouts[status] = timeout
+	}
+	if strings.TrimSpace(value) == "" {
+		return timeouts, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		status, duration, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid stuck video timeout %q, expected status=duration", pair)
+		}
+		if _, known := DefaultStuckVideoTimeouts[status]; !known {
+			return nil, fmt.Errorf("unknown video status %q in stuck video timeouts", status)
+		}
+		timeout, err := time.ParseDuration(duration)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q for status %s", duration, status)
+		}
+		timeouts[status] = timeout
+	}
+	return timeouts, nil
+}
+
+// VideoTaskInspector reports the tasks a video has in asynq
+type VideoTaskInspector interface {
+	VideoTasks(videoID int, sceneIDs []int) (*queue.VideoTasks, error)
+}
+
+// recoveryStep is a pipeline task the reaper can queue again
+type recoveryStep struct {
+	TaskType string
+	SceneID  *int
+	Payload  interface{}
+}
+
+// NewHandleReapStuckVideos creates a handler for the periodic reaper task. It
+// finds videos that have sat in a non-terminal status for longer than their
+// timeout with no queued, running or unrelayed outbox task, then re-queues the steps whose
+// artifacts are missing, or fails the video when a step exhausted its retries
+// or the video has already been recovered maxVideoRecoveries times. Re-queued
+// renders carry media URLs from store valid for urlTTL.
+func NewHandleReapStuckVideos(videos repository.VideoStore, tasks VideoTaskInspector, timeouts map[string]time.Duration, store storage.BlobStore, urlTTL time.Duration) func(context.Context, *asynq.Task) error {
+	statuses := make([]models.VideoStatus, 0, len(timeouts))
+	for status := range timeouts {
+		statuses = append(statuses, models.VideoStatus(status))
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i] < statuses[j] })
+
+	return func(ctx context.Context, t *asynq.Task) error {
+		inFlight, err := videos.ListVideosInStatus(ctx, statuses...)
+		if err != nil {
+			return fmt.Errorf("failed to fetch in-flight videos: %w", err)
+		}
+
+		now := time.Now().UTC()
+		var errs []error
+		for _, video := range inFlight {
+			if err := reapVideo(ctx, videos, tasks, store, urlTTL, video, timeouts[string(video.Status)], now); err != nil {
+				errs = append(errs, fmt.Errorf("video %d: %w", video.ID, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// reapVideo recovers or fails a single video if it is stuck
+func reapVideo(ctx context.Context, videos repository.VideoStore, tasks VideoTaskInspector, store storage.BlobStore, urlTTL time.Duration, video models.Video, timeout time.Duration, now time.Time) error {
+	lastActive, err := lastVideoActivity(ctx, videos, video)
+	if err != nil {
+		return err
+	}
+	stuckFor := now.Sub(lastActive)
+	if stuckFor < timeout {
+		return nil
+	}
+
+	scenes, err := videos.ListScenes(ctx, video.ID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch scenes: %w", err)
+	}
+	sceneIDs := make([]int, len(scenes))
+	for i, scene := range scenes {
+		sceneIDs[i] = scene.ID
+	}
+
+	queued, err := tasks.VideoTasks(video.ID, sceneIDs)
+	if err != nil {
+		return fmt.Errorf("failed to inspect tasks: %w", err)
+	}
+	if queued.Live > 0 {
+		log.Printf("Video %d has been in %s for %s but still has %d queued or running tasks, leaving it", video.ID, video.Status, stuckFor.Round(time.Second), queued.Live)
+		return nil
+	}
+	// A task still in the outbox reaches asynq once the relay gets to it, or
+	// once Redis is back
+	pending, err := videos.CountPendingOutboxTasks(ctx, video.ID, sceneIDs)
+	if err != nil {
+		return fmt.Errorf("failed to inspect outbox: %w", err)
+	}
+	if pending > 0 {
+		log.Printf("Video %d has been in %s for %s but still has %d tasks waiting in the outbox, leaving it", video.ID, video.Status, stuckFor.Round(time.Second), pending)
+		return nil
+	}
+
+	stuck := fmt.Sprintf("stuck in %s for %s with no queued tasks", video.Status, stuckFor.Round(time.Second))
+	steps, rendered := planRecovery(video, scenes)
+	if rendered {
+		// Only a render in flight can complete; a video rendered before it was
+		// moved back can't be recovered, since its artifacts are all there
+		if video.Status != models.VideoStatusRendering {
+			reason := fmt.Sprintf("%s; video was already rendered", stuck)
+			return setReapedStatus(ctx, videos, video, models.VideoStatusFailed, reason, models.PipelineStepFailed, reason)
+		}
+		// The render finished but its completion was never recorded
+		return setReapedStatus(ctx, videos, video, models.VideoStatusCompleted, "", models.PipelineStepSucceeded, stuck+"; video was already rendered")
+	}
+	if archived := archivedStep(queued.Archived, steps); archived != nil {
+		reason := fmt.Sprintf("%s; %s task exhausted its retries: %s", stuck, archived.Type, archived.LastErr)
+		return setReapedStatus(ctx, videos, video, models.VideoStatusFailed, reason, models.PipelineStepFailed, reason)
+	}
+
+	// Recoveries count from when the video reached its status, so a retry or
+	// a step that moved it on gives the reaper its tries back
+	since, err := statusReachedAt(ctx, videos, video)
+	if err != nil {
+		return err
+	}
+	recoveries, err := videos.CountPipelineSteps(ctx, video.ID, queue.TypeReapStuckVideos, models.PipelineStepSucceeded, since)
+	if err != nil {
+		return fmt.Errorf("failed to count recoveries: %w", err)
+	}
+	if recoveries >= maxVideoRecoveries {
+		reason := fmt.Sprintf("%s; gave up after %d recoveries", stuck, recoveries)
+		return setReapedStatus(ctx, videos, video, models.VideoStatusFailed, reason, models.PipelineStepFailed, reason)
+	}
+
+	return recoverVideo(ctx, videos, store, urlTTL, video, steps, stuck)
+}
+
+// lastVideoActivity returns when the video last changed or had a pipeline step start or finish
+func lastVideoActivity(ctx context.Context, videos repository.VideoStore, video models.Video) (time.Time, error) {
+	last := video.UpdatedAt
+
+	step, err := videos.LatestPipelineStep(ctx, video.ID)
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return last, nil
+	case err != nil:
+		return time.Time{}, fmt.Errorf("failed to fetch pipeline steps: %w", err)
+	}
+
+	if step.StartedAt.After(last) {
+		last = step.StartedAt
+	}
+	if step.FinishedAt != nil && step.FinishedAt.After(last) {
+		last = *step.FinishedAt
+	}
+	return last, nil
+}
+
+// statusReachedAt returns when the video last moved into its current status,
+// or the zero time when it has been there since it was created
+func statusReachedAt(ctx context.Context, videos repository.VideoStore, video models.Video) (time.Time, error) {
+	transitions, err := videos.ListStatusTransitions(ctx, video.ID)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to fetch status transitions: %w", err)
+	}
+	for i := len(transitions) - 1; i >= 0; i-- {
+		if transitions[i].ToStatus == video.Status {
+			return transitions[i].CreatedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// planRecovery returns the first missing step of each pipeline branch, like a
+// retry from the API would, and whether the final video already exists
+func planRecovery(video models.Video, scenes []models.VideoScene) ([]recoveryStep, bool) {
+	if !isBlank(video.VideoURL) {
+		return nil, true
+	}
+	if isBlank(video.Script) {
+		return []recoveryStep{{TaskType: queue.TypeGenerateVideoScript, Payload: queue.GenerateVideoScriptPayload{VideoID: video.ID}}}, false
+	}
+
+	var steps []recoveryStep
+	switch {
+	case isBlank(video.AudioKey) && isBlank(video.AudioURL):
+		steps = append(steps, recoveryStep{TaskType: queue.TypeGenerateAudio, Payload: queue.GenerateAudioPayload{VideoID: video.ID}})
+	case isBlank(video.Captions):
+		steps = append(steps, recoveryStep{TaskType: queue.TypeGenerateCaptions, Payload: queue.GenerateCaptionsPayload{VideoID: video.ID}})
+	}
+
+	if len(scenes) == 0 {
+		steps = append(steps, recoveryStep{TaskType: queue.TypeGenerateScenes, Payload: queue.GenerateScenesPayload{VideoID: video.ID}})
+	}
+	for _, scene := range scenes {
+		if isBlank(scene.ImageKey) && isBlank(scene.ImageURL) {
+			sceneID := scene.ID
+			steps = append(steps, recoveryStep{TaskType: queue.TypeGenerateSceneImage, SceneID: &sceneID, Payload: queue.GenerateSceneImagePayload{SceneID: sceneID}})
+		}
+	}
+
+	if len(steps) == 0 {
+		steps = append(steps, recoveryStep{TaskType: queue.TypeRenderVideo, Payload: queue.RenderVideoPayload{VideoID: video.ID}})
+	}
+	return steps, false
+}
+
+// archivedStep returns the archived task of one of the missing steps, if any.
+// Archived tasks of steps that have since succeeded are ignored.
+func archivedStep(archived []queue.ArchivedTask, steps []recoveryStep) *queue.ArchivedTask {
+	for i := range archived {
+		for _, step := range steps {
+			if archived[i].Type != step.TaskType {
+				continue
+			}
+			if step.SceneID == nil || (archived[i].SceneID != nil && *archived[i].SceneID == *step.SceneID) {
+				return &archived[i]
+			}
+		}
+	}
+	return nil
+}
+
+// recoverVideo queues the missing steps through the outbox and records the recovery
+func recoverVideo(ctx context.Context, videos repository.VideoStore, store storage.BlobStore, urlTTL time.Duration, video models.Video, steps []recoveryStep, stuck string) error {
+	taskTypes := make([]string, 0, len(steps))
+	err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+		for _, step := range steps {
+			if step.TaskType == queue.TypeRenderVideo && video.Status == models.VideoStatusGeneratingImages {
+				// The fan-in missed; claim the render the way the last handler would have
+				claimed, err := tx.ClaimRender(ctx, video.ID)
+				if err != nil {
+					return err
+				}
+				if !claimed {
+					return fmt.Errorf("render prerequisites are no longer met")
+				}
+			}
+			payload := step.Payload
+			if step.TaskType == queue.TypeRenderVideo {
+				var err error
+				if payload, err = renderPayload(ctx, tx, store, urlTTL, video.ID); err != nil {
+					return err
+				}
+			}
+			if err := tx.WriteOutbox(ctx, step.TaskType, payload); err != nil {
+				return err
+			}
+			if len(taskTypes) == 0 || taskTypes[len(taskTypes)-1] != step.TaskType {
+				taskTypes = append(taskTypes, step.TaskType)
+			}
+		}
+		return recordReaperStep(ctx, tx, video.ID, models.PipelineStepSucceeded, fmt.Sprintf("%s; re-queued %s", stuck, strings.Join(taskTypes, ", ")))
+	})
+	if err != nil {
+		return fmt.Errorf("failed to recover video: %w", err)
+	}
+
+	log.Printf("Recovered video %d: %s; re-queued %s", video.ID, stuck, strings.Join(taskTypes, ", "))
+	return nil
+}
+
+// setReapedStatus moves a stuck video to status and records why; failed videos
+// get failureReason. Any other status must be a legal move from the video's. The update only applies if the video is still in the status
+// it was found in, so a handler that moved it on in the meantime wins.
+func setReapedStatus(ctx context.Context, videos repository.VideoStore, video models.Video, status models.VideoStatus, failureReason string, outcome models.PipelineStepOutcome, note string) error {
+	moved := false
+	err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+		var err error
+		if status == models.VideoStatusFailed {
+			moved, err = tx.MarkVideoFailed(ctx, video.ID, failureReason, video.Status)
+		} else {
+			err = tx.Transition(ctx, video.ID, video.Status, status, note)
+			moved = err == nil
+			if errors.Is(err, repository.ErrStatusChanged) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update video status: %w", err)
+		}
+		if !moved {
+			return nil
+		}
+		return recordReaperStep(ctx, tx, video.ID, outcome, note)
+	})
+	if err != nil {
+		return err
+	}
+
+	if moved {
+		log.Printf("Reaped video %d as %s: %s", video.ID, status, note)
+	}
+	return nil
+}
+
+// recordReaperStep adds what the reaper did to the video's pipeline history
+func recordReaperStep(ctx context.Context, videos repository.VideoStore, videoID int, outcome models.PipelineStepOutcome, note string) error {
+	now := time.Now().UTC()
+	step := models.PipelineStep{
+		VideoID:    videoID,
+		Step:       queue.TypeReapStuckVideos,
+		Attempt:    1,
+		Outcome:    outcome,
+		Error:      &note,
+		StartedAt:  now,
+		FinishedAt: &now,
+	}
+	if err := videos.CreatePipelineStep(ctx, &step); err != nil {
+		return fmt.Errorf("failed to record reaper step: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
+
+	"github.com/hibiken/asynq"
+)
+
+// fakeVideoTasks answers VideoTasks from a fixed map of video IDs
+type fakeVideoTasks map[int]*queue.VideoTasks
+
+func (f fakeVideoTasks) VideoTasks(videoID int, sceneIDs []int) (*queue.VideoTasks, error) {
+	if tasks, ok := f[videoID]; ok {
+		return tasks, nil
+	}
+	return &queue.VideoTasks{}, nil
+}
+
+func TestParseStuckVideoTimeouts(t *testing.T) {
+	timeouts, err := ParseStuckVideoTimeouts(" rendering=2h, pending=5m")
+	if err != nil {
+		t.Fatalf("ParseStuckVideoTimeouts returned error: %v", err)
+	}
+	if timeouts["rendering"] != 2*time.Hour || timeouts["pending"] != 5*time.Minute {
+		t.Errorf("Expected overrides to apply, got %v", timeouts)
+	}
+	if timeouts["generating_images"] != DefaultStuckVideoTimeouts["generating_images"] {
+		t.Errorf("Expected other statuses to keep their default, got %v", timeouts)
+	}
+
+	for _, value := range []string{"rendering", "completed=1h", "rendering=soon", "rendering=-1m"} {
+		if _, err := ParseStuckVideoTimeouts(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestPlanRecovery(t *testing.T) {
+	set := strPtr("x")
+	tests := []struct {
+		name     string
+		video    models.Video
+		scenes   []models.VideoScene
+		expected []string
+		rendered bool
+	}{
+		{name: "no script", video: models.Video{}, expected: []string{queue.TypeGenerateVideoScript}},
+		{name: "no audio or scenes", video: models.Video{Script: set}, expected: []string{queue.TypeGenerateAudio, queue.TypeGenerateScenes}},
+		{
+			name:     "missing captions and one image",
+			video:    models.Video{Script: set, AudioKey: set},
+			scenes:   []models.VideoScene{{ID: 1, ImageKey: set}, {ID: 2}},
+			expected: []string{queue.TypeGenerateCaptions, queue.TypeGenerateSceneImage},
+		},
+		{
+			name:     "only the render is missing",
+			video:    models.Video{Script: set, AudioURL: set, Captions: set},
+			scenes:   []models.VideoScene{{ID: 1, ImageURL: set}},
+			expected: []string{queue.TypeRenderVideo},
+		},
+		{name: "already rendered", video: models.Video{VideoURL: set}, rendered: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			steps, rendered := planRecovery(tt.video, tt.scenes)
+			var got []string
+			for _, step := range steps {
+				got = append(got, step.TaskType)
+			}
+			if rendered != tt.rendered || strings.Join(got, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected %v (rendered=%v), got %v (rendered=%v)", tt.expected, tt.rendered, got, rendered)
+			}
+		})
+	}
+}
+
+func TestReapStuckVideos(t *testing.T) {
+	store := repository.NewMemoryVideoStore()
+	stale := time.Now().UTC().Add(-2 * time.Hour)
+	set := strPtr("x")
+	videos := []models.Video{
+		// The last scene handler missed the fan-in
+		{ID: 1, Status: "generating_images", Script: set, AudioKey: set, Captions: strPtr("[]"), UpdatedAt: stale},
+		// The render is still running
+		{ID: 2, Status: "rendering", Script: set, AudioKey: set, Captions: strPtr("[]"), UpdatedAt: stale},
+		// A scene image task was archived
+		{ID: 3, Status: "generating_images", Script: set, AudioKey: set, Captions: strPtr("[]"), UpdatedAt: stale},
+		// Recently active
+		{ID: 4, Status: "generating_images", UpdatedAt: time.Now().UTC()},
+		// Already recovered too often
+		{ID: 5, Status: "generating_audio", Script: set, UpdatedAt: stale},
+		// Not in a status the reaper watches
+		{ID: 6, Status: "completed", UpdatedAt: stale},
+		// A scene image task is still waiting in the outbox for Redis
+		{ID: 7, Status: "generating_images", Script: set, AudioKey: set, Captions: strPtr("[]"), UpdatedAt: stale},
+		// Rendered, but somehow moved back before the render
+		{ID: 8, Status: "generating_images", Script: set, AudioKey: set, Captions: strPtr("[]"), VideoURL: set, UpdatedAt: stale},
+		// Recovered as often as video 5, but all before the user retried it
+		{ID: 9, Status: "failed", Script: set, UpdatedAt: stale},
+	}
+	for _, video := range videos {
+		store.AddVideo(video)
+	}
+	scenes := []models.VideoScene{
+		{ID: 10, VideoID: 1, Status: "completed", ImageKey: set},
+		{ID: 20, VideoID: 2, Status: "completed", ImageKey: set},
+		{ID: 30, VideoID: 3, Status: "completed", ImageKey: set},
+		{ID: 31, VideoID: 3, Index: 1, Status: "failed"},
+		{ID: 50, VideoID: 5, Status: "completed", ImageKey: set},
+		{ID: 70, VideoID: 7, Status: "pending"},
+	}
+	for _, scene := range scenes {
+		store.AddScene(scene)
+	}
+	if err := store.WriteOutbox(context.Background(), queue.TypeGenerateSceneImage, queue.GenerateSceneImagePayload{SceneID: 70}); err != nil {
+		t.Fatalf("WriteOutbox returned error: %v", err)
+	}
+	recoveredAt := stale.Add(-time.Hour)
+	for _, videoID := range []int{5, 9} {
+		for i := 0; i < maxVideoRecoveries; i++ {
+			step := models.PipelineStep{VideoID: videoID, Step: queue.TypeReapStuckVideos, Attempt: 1, Outcome: models.PipelineStepSucceeded, StartedAt: recoveredAt, FinishedAt: &recoveredAt}
+			if err := store.CreatePipelineStep(context.Background(), &step); err != nil {
+				t.Fatalf("CreatePipelineStep returned error: %v", err)
+			}
+		}
+	}
+	if err := store.Transition(context.Background(), 9, "failed", "generating_audio", "retried by the user"); err != nil {
+		t.Fatalf("Transition returned error: %v", err)
+	}
+	// The retry went stale too
+	store.AddVideo(models.Video{ID: 9, Status: "generating_audio", Script: set, UpdatedAt: stale})
+	store.AddScene(models.VideoScene{ID: 90, VideoID: 9, Status: "completed", ImageKey: set})
+
+	sceneID := 31
+	tasks := fakeVideoTasks{
+		2: {Live: 1},
+		3: {Archived: []queue.ArchivedTask{
+			{Type: queue.TypeGenerateSceneImage, SceneID: &sceneID, LastErr: "quota exceeded"},
+		}},
+	}
+	media, err := storage.NewLocalStoreWithConfig(t.TempDir(), "http://localhost/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+	}
+	handler := NewHandleReapStuckVideos(store, tasks, DefaultStuckVideoTimeouts, media, time.Hour)
+	if err := handler(context.Background(), asynq.NewTask(queue.TypeReapStuckVideos, nil)); err != nil {
+		t.Fatalf("Handler returned error: %v", err)
+	}
+
+	expected := map[int]models.VideoStatus{
+		1: "ready_to_render",
+		2: "rendering",
+		3: "failed",
+		4: "generating_images",
+		5: "failed",
+		6: "completed",
+		7: "generating_images",
+		8: "failed",
+		9: "generating_audio",
+	}
+	for id, status := range expected {
+		if video := getVideo(t, store, id); video.Status != status {
+			t.Errorf("Video %d: expected status %s, got %s", id, status, video.Status)
+		}
+	}
+
+	reasons := map[int]string{3: "quota exceeded", 5: "gave up after 3 recoveries", 8: "video was already rendered"}
+	for id, reason := range reasons {
+		video := getVideo(t, store, id)
+		if video.FailureReason == nil || !strings.Contains(*video.FailureReason, reason) {
+			t.Errorf("Video %d: expected a failure reason containing %q, got %v", id, reason, deref(video.FailureReason))
+		}
+	}
+
+	outbox := store.OutboxTasks()[1:]
+	if len(outbox) != 2 || outbox[0].TaskType != queue.TypeRenderVideo || outbox[1].TaskType != queue.TypeGenerateAudio {
+		t.Fatalf("Expected a render and the audio of the retried video to be queued, got %+v", outbox)
+	}
+	var payload queue.RenderVideoPayload
+	if _, err := queue.DecodePayload(outbox[0].Payload, &payload); err != nil || payload.VideoID != 1 {
+		t.Errorf("Expected the render of video 1, got %+v (%v)", payload, err)
+	}
+	if payload.AudioURL == "" || len(payload.SceneImages) != 1 || payload.SceneImages[0].SceneID != 10 || payload.SceneImages[0].ImageURL == "" {
+		t.Errorf("Expected the render to carry signed media URLs, got %+v", payload)
+	}
+
+	recovered, _ := store.CountPipelineSteps(context.Background(), 1, queue.TypeReapStuckVideos, models.PipelineStepSucceeded, time.Time{})
+	if recovered != 1 {
+		t.Errorf("Expected the recovery to be recorded, got %d steps", recovered)
+	}
+}
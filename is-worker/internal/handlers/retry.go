@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"instashorts-be/is-worker/internal/ai"
+
+	"github.com/hibiken/asynq"
+)
+
+const (
+	// rateLimitDelay is the shortest wait after a rate limit that didn't say how long to wait
+	rateLimitDelay = 30 * time.Second
+	// maxRetryAfter caps a provider's Retry-After, so a bogus value can't park a task for days
+	maxRetryAfter = 15 * time.Minute
+)
+
+// providerError marks an AI provider error that retrying can't fix, like an
+// unknown voice or a blocked prompt, so asynq archives the task right away
+// instead of paying for the same failure on every retry
+func providerError(err error) error {
+	if ai.IsPermanent(err) {
+		return fmt.Errorf("%w: %w", err, asynq.SkipRetry)
+	}
+	return err
+}
+
+// RetryDelay is the worker's asynq.RetryDelayFunc. A rate-limited task waits as
+// long as the provider asked, plus jitter so the scene images of a video don't
+// all come back at once; other tasks back off exponentially.
+func RetryDelay(n int, err error, t *asynq.Task) time.Duration {
+	backoff := asynq.DefaultRetryDelayFunc(n, err, t)
+	after, rateLimited := ai.RetryAfter(err)
+	if !rateLimited {
+		return backoff
+	}
+	if after <= 0 {
+		after = max(backoff, rateLimitDelay)
+	}
+	after = min(after, maxRetryAfter)
+	return after + rand.N(after/10+time.Second)
+}
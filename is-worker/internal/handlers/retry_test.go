@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"instashorts-be/is-worker/internal/ai"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestProviderError(t *testing.T) {
+	unknownVoice := fmt.Errorf("failed to generate audio: %w", ai.NewAPIError("ElevenLabs", http.StatusNotFound, nil, "voice_not_found"))
+	if err := providerError(unknownVoice); !errors.Is(err, asynq.SkipRetry) || !errors.Is(err, ai.ErrInvalidInput) {
+		t.Errorf("Expected a permanent error to skip retries, got %v", err)
+	}
+
+	timeout := errors.New("failed to generate audio: timeout")
+	if err := providerError(timeout); errors.Is(err, asynq.SkipRetry) {
+		t.Errorf("Expected a transient error to be retried, got %v", err)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	task := asynq.NewTask("test", nil)
+	tests := []struct {
+		name string
+		err  error
+		min  time.Duration
+		max  time.Duration
+	}{
+		{
+			name: "provider asked to wait",
+			err:  ai.NewAPIError("ElevenLabs", http.StatusTooManyRequests, http.Header{"Retry-After": []string{"40"}}, ""),
+			min:  40 * time.Second,
+			max:  45 * time.Second,
+		},
+		{
+			name: "provider asked to wait too long",
+			err:  ai.NewAPIError("ElevenLabs", http.StatusTooManyRequests, http.Header{"Retry-After": []string{"86400"}}, ""),
+			min:  maxRetryAfter,
+			max:  maxRetryAfter + maxRetryAfter/10 + time.Second,
+		},
+		{
+			// asynq's own first backoff is up to 45s, and the longer wait wins
+			name: "provider didn't say",
+			err:  ai.NewAPIError("Vertex AI", http.StatusTooManyRequests, nil, "RESOURCE_EXHAUSTED"),
+			min:  rateLimitDelay,
+			max:  45*time.Second + 45*time.Second/10 + time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RetryDelay(0, tt.err, task); got < tt.min || got > tt.max {
+				t.Errorf("Expected a delay between %s and %s, got %s", tt.min, tt.max, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/repository"
+
+	"github.com/hibiken/asynq"
+)
+
+// stepRun tracks a started pipeline step so it can be finished later
+type stepRun struct {
+	videos repository.VideoStore
+	id     int
+}
+
+// startStep records the start of a pipeline step for the task in ctx.
+// Recording is best-effort: failures are logged and never fail the task.
+func startStep(ctx context.Context, videos repository.VideoStore, step string, videoID int, sceneID *int) *stepRun {
+	row := models.PipelineStep{
+		VideoID:   videoID,
+		SceneID:   sceneID,
+		Step:      step,
+		Attempt:   1,
+		Outcome:   models.PipelineStepRunning,
+		StartedAt: time.Now().UTC(),
+	}
+	if retried, ok := asynq.GetRetryCount(ctx); ok {
+		row.Attempt = retried + 1
+	}
+	if taskID, ok := asynq.GetTaskID(ctx); ok {
+		row.TaskID = &taskID
+	}
+
+	if err := videos.CreatePipelineStep(ctx, &row); err != nil {
+		log.Printf("ERROR: Failed to record start of step %s for video_id=%d: %v", step, videoID, err)
+		return &stepRun{videos: videos}
+	}
+
+	return &stepRun{videos: videos, id: row.ID}
+}
+
+// finish records the outcome of the step; a nil error means success
+func (s *stepRun) finish(ctx context.Context, stepErr error) {
+	if s.id == 0 {
+		return
+	}
+
+	outcome := models.PipelineStepSucceeded
+	var message *string
+	switch {
+	case errors.Is(stepErr, errVideoCancelled):
+		outcome = models.PipelineStepCancelled
+	case stepErr != nil:
+		outcome = models.PipelineStepFailed
+	}
+	if stepErr != nil {
+		text := stepErr.Error()
+		message = &text
+	}
+
+	// The task context may already be cancelled or past its deadline
+	if err := s.videos.FinishPipelineStep(context.WithoutCancel(ctx), s.id, outcome, message); err != nil {
+		log.Printf("ERROR: Failed to record outcome of pipeline step %d: %v", s.id, err)
+	}
+}
@@ -1,162 +1,109 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"instashorts-be/is-worker/internal/ai"
-	"instashorts-be/is-worker/internal/ai/gemini"
-	"instashorts-be/is-worker/internal/storage"
+	"instashorts-be/is-worker/internal/render"
+	"instashorts-be/pkg/models"
 	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
 
 	"github.com/hibiken/asynq"
-	"gorm.io/gorm"
 )
 
-// NewHandleGenerateVideoScript creates a handler for video script generation
-func NewHandleGenerateVideoScript(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// NewHandleGenerateVideoScript creates a handler for video script generation using the given ScriptWriter
+func NewHandleGenerateVideoScript(videos repository.VideoStore, writer ai.ScriptWriter) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.GenerateVideoScriptPayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Generating script for video: video_id=%d", payload.VideoID)
 
+		run := startStep(ctx, videos, queue.TypeGenerateVideoScript, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
 		// Fetch video from database to get theme
-		var video struct {
-			ID     int
-			Theme  string
-			Status string
-			Script *string
-		}
-		if err := db.WithContext(ctx).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			First(&video).Error; err != nil {
+		video, err := videos.GetVideo(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video: %w", err)
 		}
 
 		log.Printf("Video theme: %s", video.Theme)
 
-		// Update status to "generating_script"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "generating_script").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
+		if err := advanceStatus(ctx, videos, payload.VideoID, models.VideoStatusPending, models.VideoStatusGeneratingScript, "script generation started"); err != nil {
+			return err
 		}
 
-		log.Printf("Status updated to 'generating_script' for video_id=%d", payload.VideoID)
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, payload.VideoID); err != nil {
+			return err
+		}
 
-		// Create AI service
-		aiService, err := gemini.NewService(ctx)
+		// Generate script with the configured LLM
+		script, err := writer.GenerateVideoScript(ctx, video.Theme)
 		if err != nil {
-			// Update status to "failed" if we can't create the AI service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create AI service: %w", err)
-		}
-
-		// Generate script using Google AI (250-300 words for ~70 seconds)
-		script, err := aiService.GenerateVideoScript(ctx, video.Theme)
-		if err != nil {
-			// Update status to "failed" if script generation fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to generate script: %w", err)
+			return providerError(fmt.Errorf("failed to generate script: %w", err))
 		}
 
 		log.Printf("Script generated for video_id=%d (length: %d characters)", payload.VideoID, len(script))
 
-		// Update script
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Script string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("script", script).Error; err != nil {
-			return fmt.Errorf("failed to update video script: %w", err)
-		}
-
-		// Update status to "completed"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "completed").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
-		}
-
-		log.Printf("Video script generation completed: video_id=%d", payload.VideoID)
-
-		// Enqueue the generate audio task
-		queueClient := queue.GetClient()
-		if queueClient != nil {
-			log.Printf("Enqueueing generate audio task for video_id=%d", payload.VideoID)
-			err := queueClient.EnqueueGenerateAudio(queue.GenerateAudioPayload(payload))
-			if err != nil {
-				log.Printf("Failed to enqueue generate audio task: %v", err)
+		// Save the script and queue the next steps together, so a committed
+		// script always gets its audio and scenes
+		if err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+			if err := tx.SaveScript(ctx, payload.VideoID, script); err != nil {
+				return fmt.Errorf("failed to update video script: %w", err)
 			}
 
-			log.Printf("Enqueueing generate scenes task for video_id=%d", payload.VideoID)
-			err = queueClient.EnqueueGenerateScenes(queue.GenerateScenesPayload(payload))
-			if err != nil {
-				log.Printf("Failed to enqueue generate scenes task: %v", err)
+			// The audio and scene branches start together; the status follows the scenes
+			if err := advanceStatus(ctx, tx, payload.VideoID, models.VideoStatusGeneratingScript, models.VideoStatusGeneratingAudio, "script saved"); err != nil {
+				return err
 			}
 
-		} else {
-			log.Printf("Warning: Queue client not initialized, skipping audio and scene generation")
+			if err := tx.WriteOutbox(ctx, queue.TypeGenerateAudio, queue.GenerateAudioPayload(payload)); err != nil {
+				return err
+			}
+			return tx.WriteOutbox(ctx, queue.TypeGenerateScenes, queue.GenerateScenesPayload(payload))
+		}); err != nil {
+			return err
 		}
 
+		log.Printf("Video script generation completed: video_id=%d, queued audio and scene generation", payload.VideoID)
+
 		return nil
 	}
 }
 
-func NewHandleGenerateAudio(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// NewHandleGenerateAudio creates a handler for audio generation using the given TextToSpeech provider
+func NewHandleGenerateAudio(videos repository.VideoStore, tts ai.TextToSpeech, store storage.BlobStore) func(context.Context, *asynq.Task) error {
+	media := storage.NewMedia(store)
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.GenerateAudioPayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Generating audio for video: video_id=%d", payload.VideoID)
 
+		run := startStep(ctx, videos, queue.TypeGenerateAudio, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
 		// Fetch video from database to get script and voice_id
-		var video struct {
-			ID       int
-			Script   *string
-			VoiceID  string
-			AudioURL *string
-			Status   string
-		}
-		if err := db.WithContext(ctx).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			First(&video).Error; err != nil {
+		video, err := videos.GetVideo(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video: %w", err)
 		}
 
@@ -165,168 +112,96 @@ func NewHandleGenerateAudio(db *gorm.DB) func(context.Context, *asynq.Task) erro
 			return fmt.Errorf("video has no script to generate audio from")
 		}
 
-		log.Printf("Video script length: %d characters", len(*video.Script))
+		// Check if a voice was selected
+		if video.VoiceID == "" {
+			return fmt.Errorf("video has no voice_id to generate audio with")
+		}
 
-		// Update status to "generating_audio"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "generating_audio").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
+		// Per-video voice settings; unset fields use the defaults
+		var voiceSettings ai.VoiceSettings
+		if video.VoiceSettings != nil {
+			voiceSettings = ai.VoiceSettings(*video.VoiceSettings)
 		}
 
-		log.Printf("Status updated to 'generating_audio' for video_id=%d", payload.VideoID)
+		log.Printf("Video script length: %d characters", len(*video.Script))
 
-		// Create ElevenLabs service
-		elevenLabsService, err := ai.NewElevenLabsService()
-		if err != nil {
-			log.Printf("ERROR: Failed to create ElevenLabs service for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if we can't create the service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create ElevenLabs service: %w", err)
-		}
-
-		// Generate audio using ElevenLabs
-		// Hardcoded voice ID for now
-		voiceID := "NNl6r8mD7vthiJatiJt1"
-		log.Printf("Calling ElevenLabs API for video_id=%d with voice_id=%s", payload.VideoID, voiceID)
-		audioData, err := elevenLabsService.GenerateAudio(ctx, *video.Script, voiceID)
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, payload.VideoID); err != nil {
+			return err
+		}
+
+		// Generate audio with the voice selected for this video
+		log.Printf("Generating audio for video_id=%d with voice_id=%s", payload.VideoID, video.VoiceID)
+		audio, err := tts.GenerateAudio(ctx, *video.Script, video.VoiceID, voiceSettings)
 		if err != nil {
 			log.Printf("ERROR: Failed to generate audio for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if audio generation fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to generate audio: %w", err)
-		}
-
-		log.Printf("Audio generated for video_id=%d (size: %d bytes)", payload.VideoID, len(audioData))
-
-		// Create S3 service (using GCS from your new file)
-		// Note: The old file used storage.NewS3Service, the new one uses storage.NewGCSClient
-		// I'll use the one from your new structure: storage.NewGCSClient
-		s3Service, err := storage.NewGCSClient(ctx) // Using NewGCSClient as per your new `task_handlers.go`
-		if err != nil {
-			log.Printf("ERROR: Failed to create GCS service for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if we can't create the GCS service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create GCS service: %w", err)
-		}
-
-		// Upload audio to S3/GCS
-		audioURL, err := s3Service.UploadAudio(ctx, audioData, payload.VideoID)
+			return providerError(fmt.Errorf("failed to generate audio: %w", err))
+		}
+
+		defer audio.Body.Close()
+
+		log.Printf("Audio stream opened for video_id=%d (type: %s)", payload.VideoID, audio.ContentType)
+
+		// Stream the audio to storage as the provider produces it
+		audioKey, err := media.UploadAudio(ctx, audio.Body, payload.VideoID, audio.ContentType)
 		if err != nil {
-			log.Printf("ERROR: Failed to upload audio to GCS for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if upload fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to upload audio to GCS: %w", err)
-		}
-
-		log.Printf("Audio uploaded to GCS: %s", audioURL)
-
-		// Update audio_url in the database
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID       int
-				AudioURL string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("audio_url", audioURL).Error; err != nil {
-			return fmt.Errorf("failed to update video audio_url: %w", err)
+			log.Printf("ERROR: Failed to upload audio for video_id=%d: %v", payload.VideoID, err)
+			return fmt.Errorf("failed to upload audio: %w", err)
 		}
 
-		// Update status to "completed"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "completed").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
-		}
-
-		log.Printf("Audio generation completed: video_id=%d, audio_url=%s", payload.VideoID, audioURL)
-
-		// Enqueue the generate captions and scenes tasks in parallel
-		queueClient := queue.GetClient()
-		if queueClient != nil {
-			// Enqueue caption generation
-			log.Printf("Enqueueing generate captions task for video_id=%d", payload.VideoID)
-			err := queueClient.EnqueueGenerateCaptions(queue.GenerateCaptionsPayload(payload))
-			if err != nil {
-				log.Printf("Failed to enqueue generate captions task: %v", err)
+		log.Printf("Audio uploaded: %s", audioKey)
+
+		// Record where the audio is stored and queue its captions together;
+		// URLs are signed whenever the audio is read. The video's status belongs
+		// to the scene branch running in parallel, so it is left alone.
+		if err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+			if err := tx.SaveAudio(ctx, payload.VideoID, store.Bucket(), audioKey); err != nil {
+				return fmt.Errorf("failed to update video audio_key: %w", err)
 			}
 
-		} else {
-			log.Printf("Warning: Queue client not initialized, skipping caption and scene generation")
+			return tx.WriteOutbox(ctx, queue.TypeGenerateCaptions, queue.GenerateCaptionsPayload(payload))
+		}); err != nil {
+			return err
 		}
 
+		log.Printf("Audio generation completed: video_id=%d, audio_key=%s, queued caption generation", payload.VideoID, audioKey)
+
 		return nil
 	}
 }
 
-// NewHandleGenerateCaptions creates a handler for caption generation
-func NewHandleGenerateCaptions(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// NewHandleGenerateCaptions creates a handler for caption generation that reads
+// the audio from store. The render task it may queue carries URLs valid for urlTTL.
+func NewHandleGenerateCaptions(videos repository.VideoStore, store storage.BlobStore, urlTTL time.Duration) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.GenerateCaptionsPayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Generating captions for video: video_id=%d", payload.VideoID)
 
-		// Fetch video from database to get audio_url
-		var video struct {
-			ID       int
-			AudioURL *string
-			Status   string
-		}
-		if err := db.WithContext(ctx).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			First(&video).Error; err != nil {
+		run := startStep(ctx, videos, queue.TypeGenerateCaptions, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
+		// Fetch video from database to get the audio location
+		video, err := videos.GetVideo(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video: %w", err)
 		}
 
-		// Check if audio URL exists
-		if video.AudioURL == nil || *video.AudioURL == "" {
-			return fmt.Errorf("video has no audio URL to generate captions from")
+		// Check if audio exists
+		audio := storedMedia{Bucket: video.AudioBucket, Key: video.AudioKey, LegacyURL: video.AudioURL}
+		if !audio.exists() {
+			return fmt.Errorf("video has no audio to generate captions from")
 		}
 
-		log.Printf("Generating captions from audio URL: %s", *video.AudioURL)
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, payload.VideoID); err != nil {
+			return err
+		}
 
 		// Create Speech-to-Text service
 		sttService, err := ai.NewSpeechToTextService(ctx)
@@ -336,8 +211,23 @@ func NewHandleGenerateCaptions(db *gorm.DB) func(context.Context, *asynq.Task) e
 		}
 		defer sttService.Close()
 
-		// Generate captions from audio URL
-		captions, err := sttService.GenerateCaptionsFromURL(ctx, *video.AudioURL)
+		// Generate captions from the stored audio, or from the URL saved before keys were stored
+		var captions []ai.CaptionWord
+		if !isBlank(audio.Key) {
+			log.Printf("Generating captions from audio key: %s", *audio.Key)
+			if deref(audio.Bucket) != store.Bucket() {
+				return fmt.Errorf("audio is in bucket %q, but storage is configured for bucket %q", deref(audio.Bucket), store.Bucket())
+			}
+			audioReader, getErr := store.Get(ctx, *audio.Key)
+			if getErr != nil {
+				return fmt.Errorf("failed to download audio: %w", getErr)
+			}
+			defer audioReader.Close()
+			captions, err = sttService.GenerateCaptions(ctx, audioReader)
+		} else {
+			log.Printf("Generating captions from audio URL: %s", *audio.LegacyURL)
+			captions, err = sttService.GenerateCaptionsFromURL(ctx, *audio.LegacyURL)
+		}
 		if err != nil {
 			log.Printf("ERROR: Failed to generate captions for video_id=%d: %v", payload.VideoID, err)
 			return fmt.Errorf("failed to generate captions: %w", err)
@@ -354,46 +244,37 @@ func NewHandleGenerateCaptions(db *gorm.DB) func(context.Context, *asynq.Task) e
 		log.Printf("Captions JSON: %s", captionsJSON)
 
 		// Update captions in the database
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID       int
-				Captions string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("captions", captionsJSON).Error; err != nil {
+		if err := videos.SaveCaptions(ctx, payload.VideoID, captionsJSON); err != nil {
 			return fmt.Errorf("failed to update video captions: %w", err)
 		}
 
 		log.Printf("Caption generation completed: video_id=%d, caption_count=%d", payload.VideoID, len(captions))
 
 		// Check if all scenes are completed and trigger render if ready
-		checkAndEnqueueRender(ctx, db, payload.VideoID)
+		checkAndEnqueueRender(ctx, videos, store, urlTTL, payload.VideoID)
 
 		return nil
 	}
 }
 
-// NewHandleGenerateScenes creates a handler for scene generation
-func NewHandleGenerateScenes(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// NewHandleGenerateScenes creates a handler for scene generation using the given SceneDirector
+func NewHandleGenerateScenes(videos repository.VideoStore, director ai.SceneDirector) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.GenerateScenesPayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Generating scenes for video: video_id=%d", payload.VideoID)
 
+		run := startStep(ctx, videos, queue.TypeGenerateScenes, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
 		// Fetch video from database to get script
-		var video struct {
-			ID     int
-			Script *string
-			Status string
-		}
-		if err := db.WithContext(ctx).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			First(&video).Error; err != nil {
+		video, err := videos.GetVideo(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video: %w", err)
 		}
 
@@ -404,115 +285,74 @@ func NewHandleGenerateScenes(db *gorm.DB) func(context.Context, *asynq.Task) err
 
 		log.Printf("Generating scenes based on script (length: %d characters)", len(*video.Script))
 
-		// Update status to "generating_scenes"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "generating_scenes").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
+		if err := advanceStatus(ctx, videos, payload.VideoID, models.VideoStatusGeneratingAudio, models.VideoStatusGeneratingScenes, "scene generation started"); err != nil {
+			return err
 		}
 
-		// Create AI service
-		aiService, err := gemini.NewService(ctx)
+		// A run that committed its scenes but wasn't acked is redelivered; queue
+		// the images it left instead of paying for the scenes again
+		existing, err := videos.ListScenes(ctx, payload.VideoID)
 		if err != nil {
-			log.Printf("ERROR: Failed to create AI service for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if we can't create the AI service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create AI service: %w", err)
-		}
-
-		// Generate scenes using Gemini
-		scenes, err := aiService.GenerateScenes(ctx, *video.Script)
+			return fmt.Errorf("failed to fetch existing scenes: %w", err)
+		}
+		if len(existing) > 0 {
+			log.Printf("Video %d already has %d scenes, queueing their images", payload.VideoID, len(existing))
+			return videos.Transaction(ctx, func(tx repository.VideoStore) error {
+				for _, scene := range existing {
+					if scene.Status == models.SceneStatusCompleted {
+						continue
+					}
+					if err := tx.WriteOutbox(ctx, queue.TypeGenerateSceneImage, queue.GenerateSceneImagePayload{
+						SceneID: scene.ID,
+					}); err != nil {
+						return err
+					}
+				}
+				return advanceStatus(ctx, tx, payload.VideoID, models.VideoStatusGeneratingScenes, models.VideoStatusGeneratingImages, "scene images queued")
+			})
+		}
+
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, payload.VideoID); err != nil {
+			return err
+		}
+
+		// Generate scenes with the configured LLM
+		scenes, err := director.GenerateScenes(ctx, *video.Script)
 		if err != nil {
 			log.Printf("ERROR: Failed to generate scenes for video_id=%d: %v", payload.VideoID, err)
-			// Update status to "failed" if scene generation fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("videos").
-				Where("id = ?", payload.VideoID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to generate scenes: %w", err)
+			return providerError(fmt.Errorf("failed to generate scenes: %w", err))
 		}
 
 		log.Printf("Generated %d scenes for video_id=%d", len(scenes), payload.VideoID)
 
-		// Create VideoScene records in the database
-		queueClient := queue.GetClient()
-		for _, scene := range scenes {
-			videoScene := struct {
-				VideoID int
-				Prompt  string
-				Index   int
-				Status  string
-			}{
-				VideoID: payload.VideoID,
-				Prompt:  scene.ImagePrompt,
-				Index:   scene.Index,
-				Status:  "pending",
-			}
-
-			// Insert the scene into the database
-			var sceneID int
-			err := db.WithContext(ctx).
-				Table("video_scenes").
-				Create(&videoScene).
-				Error
-			if err != nil {
-				log.Printf("ERROR: Failed to create video scene for video_id=%d: %v", payload.VideoID, err)
-				continue
-			}
+		// Create the scenes, queue their images and move the video on together,
+		// so every committed scene gets an image task
+		if err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+			for _, scene := range scenes {
+				// A concurrent run may have created the scene first; its row is kept
+				videoScene := models.VideoScene{
+					VideoID: payload.VideoID,
+					Prompt:  scene.ImagePrompt,
+					Index:   scene.Index,
+					Status:  models.SceneStatusPending,
+				}
+				if err := tx.UpsertScene(ctx, &videoScene); err != nil {
+					return fmt.Errorf("failed to create video scene %d: %w", scene.Index, err)
+				}
 
-			// Get the ID of the created scene
-			err = db.WithContext(ctx).
-				Table("video_scenes").
-				Where("video_id = ? AND index = ?", payload.VideoID, scene.Index).
-				Select("id").
-				Scan(&sceneID).
-				Error
-			if err != nil {
-				log.Printf("ERROR: Failed to get scene ID for video_id=%d, index=%d: %v", payload.VideoID, scene.Index, err)
-				continue
-			}
+				log.Printf("Created scene %d for video_id=%d (scene_id=%d)", scene.Index, payload.VideoID, videoScene.ID)
 
-			log.Printf("Created scene %d for video_id=%d (scene_id=%d)", scene.Index, payload.VideoID, sceneID)
-
-			// Enqueue image generation task for this scene
-			if queueClient != nil {
-				err := queueClient.EnqueueGenerateSceneImage(queue.GenerateSceneImagePayload{
-					SceneID: sceneID,
-				})
-				if err != nil {
-					log.Printf("Failed to enqueue generate scene image task for scene_id=%d: %v", sceneID, err)
-				} else {
-					log.Printf("Enqueued image generation task for scene_id=%d", sceneID)
+				if err := tx.WriteOutbox(ctx, queue.TypeGenerateSceneImage, queue.GenerateSceneImagePayload{
+					SceneID: videoScene.ID,
+				}); err != nil {
+					return err
 				}
 			}
-		}
 
-		// Update video status to "generating_images"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "generating_images").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
+			return advanceStatus(ctx, tx, payload.VideoID, models.VideoStatusGeneratingScenes, models.VideoStatusGeneratingImages, "scene images queued")
+		}); err != nil {
+			return err
 		}
 
 		log.Printf("Scene generation completed: video_id=%d, scenes_count=%d", payload.VideoID, len(scenes))
@@ -520,344 +360,195 @@ func NewHandleGenerateScenes(db *gorm.DB) func(context.Context, *asynq.Task) err
 	}
 }
 
-// NewHandleGenerateSceneImage creates a handler for scene image generation
-func NewHandleGenerateSceneImage(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// advanceStatus moves a video to the next status of the pipeline. A video that
+// is no longer in from was moved by someone else. A redelivered task finds it
+// already at or past to and carries on; a cancelled one stops the task, and any
+// other status, such as failed, fails it without a retry so nothing is paid for.
+func advanceStatus(ctx context.Context, videos repository.VideoStore, videoID int, from, to models.VideoStatus, reason string) error {
+	err := videos.Transition(ctx, videoID, from, to, reason)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		if err := ensureVideoActive(ctx, videos, videoID); err != nil {
+			return err
+		}
+		video, getErr := videos.GetVideo(ctx, videoID)
+		if getErr != nil {
+			return fmt.Errorf("failed to check video status: %w", getErr)
+		}
+		if video.Status.Reached(to) {
+			log.Printf("Not moving video_id=%d to '%s': %v", videoID, to, err)
+			return nil
+		}
+		return fmt.Errorf("failed to update video status: %w: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update video status: %w", err)
+	}
+	return nil
+}
+
+// NewHandleGenerateSceneImage creates a handler for scene image generation using
+// the given ImageGenerator. The render task it may queue carries URLs valid for urlTTL.
+func NewHandleGenerateSceneImage(videos repository.VideoStore, images ai.ImageGenerator, store storage.BlobStore, urlTTL time.Duration) func(context.Context, *asynq.Task) error {
+	media := storage.NewMedia(store)
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.GenerateSceneImagePayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Generating image for scene: scene_id=%d", payload.SceneID)
 
 		// Fetch scene from database
-		var scene struct {
-			ID       int
-			VideoID  int
-			Prompt   string
-			ImageURL *string
-			Index    int
-			Status   string
-		}
-		if err := db.WithContext(ctx).
-			Table("video_scenes").
-			Where("id = ?", payload.SceneID).
-			First(&scene).Error; err != nil {
+		scene, err := videos.GetScene(ctx, payload.SceneID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch scene: %w", err)
 		}
 
+		run := startStep(ctx, videos, queue.TypeGenerateSceneImage, scene.VideoID, &scene.ID)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
 		log.Printf("Scene prompt: %s", scene.Prompt)
 
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, scene.VideoID); err != nil {
+			return err
+		}
+
 		// Update status to "generating"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("video_scenes").
-			Where("id = ?", payload.SceneID).
-			Update("status", "generating").Error; err != nil {
+		if err := videos.SetSceneStatus(ctx, payload.SceneID, models.SceneStatusGenerating); err != nil {
 			return fmt.Errorf("failed to update scene status: %w", err)
 		}
 
-		// Create AI service
-		aiService, err := gemini.NewService(ctx)
-		if err != nil {
-			log.Printf("ERROR: Failed to create AI service for scene_id=%d: %v", payload.SceneID, err)
-			// Update status to "failed" if we can't create the AI service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("video_scenes").
-				Where("id = ?", payload.SceneID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create AI service: %w", err)
-		}
-
-		// Generate image using Imagen
-		log.Printf("Generating image with Imagen for scene_id=%d with prompt: %s", payload.SceneID, scene.Prompt)
-		imageData, err := aiService.GenerateImage(ctx, scene.Prompt)
+		// Generate image with the configured image providers
+		log.Printf("Generating image for scene_id=%d with prompt: %s", payload.SceneID, scene.Prompt)
+		imageData, err := images.GenerateImage(ctx, scene.Prompt)
 		if err != nil {
 			log.Printf("ERROR: Failed to generate image for scene_id=%d: %v", payload.SceneID, err)
-			// Update status to "failed" if image generation fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("video_scenes").
-				Where("id = ?", payload.SceneID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to generate image: %w", err)
+			return providerError(fmt.Errorf("failed to generate image: %w", err))
 		}
 
 		log.Printf("Image generated for scene_id=%d (size: %d bytes)", payload.SceneID, len(imageData))
 
-		// Create S3/GCS service
-		// Using NewGCSClient as per your new `task_handlers.go`
-		s3Service, err := storage.NewGCSClient(ctx)
+		// Upload image to storage
+		imageKey, err := media.UploadImage(ctx, bytes.NewReader(imageData), scene.VideoID, scene.Index)
 		if err != nil {
-			log.Printf("ERROR: Failed to create GCS service for scene_id=%d: %v", payload.SceneID, err)
-			// Update status to "failed" if we can't create the S3 service
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("video_scenes").
-				Where("id = ?", payload.SceneID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to create GCS service: %w", err)
-		}
-
-		// Upload image to S3/GCS
-		imageURL, err := s3Service.UploadImage(ctx, imageData, scene.VideoID, scene.Index)
-		if err != nil {
-			log.Printf("ERROR: Failed to upload image to GCS for scene_id=%d: %v", payload.SceneID, err)
-			// Update status to "failed" if upload fails
-			db.WithContext(ctx).
-				Model(&struct {
-					ID     int
-					Status string
-				}{}).
-				Table("video_scenes").
-				Where("id = ?", payload.SceneID).
-				Update("status", "failed")
-			return fmt.Errorf("failed to upload image to GCS: %w", err)
-		}
-
-		log.Printf("Image uploaded to GCS: %s", imageURL)
-
-		// Update scene with image URL
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID       int
-				ImageURL string
-			}{}).
-			Table("video_scenes").
-			Where("id = ?", payload.SceneID).
-			Update("image_url", imageURL).Error; err != nil {
-			return fmt.Errorf("failed to update scene image_url: %w", err)
+			log.Printf("ERROR: Failed to upload image for scene_id=%d: %v", payload.SceneID, err)
+			return fmt.Errorf("failed to upload image: %w", err)
+		}
+
+		log.Printf("Image uploaded: %s", imageKey)
+
+		// Record where the image is stored; URLs are signed whenever it is read
+		if err := videos.SaveSceneImage(ctx, payload.SceneID, store.Bucket(), imageKey); err != nil {
+			return fmt.Errorf("failed to update scene image_key: %w", err)
 		}
 
 		// Update status to "completed"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("video_scenes").
-			Where("id = ?", payload.SceneID).
-			Update("status", "completed").Error; err != nil {
+		if err := videos.SetSceneStatus(ctx, payload.SceneID, models.SceneStatusCompleted); err != nil {
 			return fmt.Errorf("failed to update scene status: %w", err)
 		}
 
-		log.Printf("Scene image generation completed: scene_id=%d, image_url=%s", payload.SceneID, imageURL)
+		log.Printf("Scene image generation completed: scene_id=%d, image_key=%s", payload.SceneID, imageKey)
 
 		// Check if all scenes are completed and captions are ready, then trigger render
-		checkAndEnqueueRender(ctx, db, scene.VideoID)
+		checkAndEnqueueRender(ctx, videos, store, urlTTL, scene.VideoID)
 
 		return nil
 	}
 }
 
-// checkAndEnqueueRender checks if all prerequisites are met to render the video
-func checkAndEnqueueRender(ctx context.Context, db *gorm.DB, videoID int) {
-	// --- MODIFICATION: Fetch video with captions AND status ---
-	var video struct {
-		ID       int
-		Captions *string
-		Status   string // <-- Added this field
-	}
-	if err := db.WithContext(ctx).
-		Table("videos").
-		Where("id = ?", videoID).
-		First(&video).Error; err != nil {
-		log.Printf("ERROR: Failed to fetch video for render check: %v", err)
-		return
-	}
-
-	// Check if captions exist
-	if video.Captions == nil || *video.Captions == "" {
-		log.Printf("Captions not ready yet for video_id=%d, skipping render", videoID)
-		return
-	}
-
-	// --- MODIFICATION: Added check to prevent race condition ---
-	// If captions are done, but the scenes task hasn't finished (i.e., status isn't 'generating_images' or 'ready_to_render'),
-	// then it's too early for us to check for pending scenes.
-	// The scene_image handlers will call this check again later.
-	if video.Status != "generating_images" && video.Status != "ready_to_render" {
-		log.Printf("Captions are done, but scene generation is not yet complete (status: %s). Skipping render check.", video.Status)
-		return
-	}
-	// --- END MODIFICATION ---
-
-	// Check if all scenes have completed
-	var pendingScenes int64
-	if err := db.WithContext(ctx).
-		Table("video_scenes").
-		Where("video_id = ? AND (status != ? OR image_url IS NULL)", videoID, "completed").
-		Count(&pendingScenes).Error; err != nil {
-		log.Printf("ERROR: Failed to count pending scenes for video_id=%d: %v", videoID, err)
-		return
-	}
-
-	if pendingScenes > 0 {
-		log.Printf("Still have %d pending scenes for video_id=%d, skipping render", pendingScenes, videoID)
-		return
-	}
-
-	// Check if there are any scenes at all
-	var totalScenes int64
-	if err := db.WithContext(ctx).
-		Table("video_scenes").
-		Where("video_id = ?", videoID).
-		Count(&totalScenes).Error; err != nil {
-		log.Printf("ERROR: Failed to count total scenes for video_id=%d: %v", videoID, err)
-		return
-	}
-
-	if totalScenes == 0 {
-		// This check is now safe, as we know the scene gen step has run
-		log.Printf("No scenes found for video_id=%d, skipping render", videoID)
-		return
-	}
-
-	// --- MODIFICATION: Update status to 'ready_to_render' BEFORE enqueueing ---
-	log.Printf("All prerequisites met for video_id=%d, updating status and enqueueing render task", videoID)
-
-	// Update status to "ready_to_render"
-	if err := db.WithContext(ctx).
-		Model(&struct {
-			ID     int
-			Status string
-		}{}).
-		Table("videos").
-		Where("id = ?", videoID).
-		Update("status", "ready_to_render").Error; err != nil {
-		log.Printf("ERROR: Failed to update video status to ready_to_render: %v", err)
-		// Don't return, still try to enqueue
-	}
-	// --- END MODIFICATION ---
-
-	// Enqueue render video task
-	queueClient := queue.GetClient()
-	if queueClient != nil {
-		err := queueClient.EnqueueRenderVideo(queue.RenderVideoPayload{VideoID: videoID})
+// checkAndEnqueueRender queues the render task, with media URLs valid for
+// urlTTL, once all prerequisites are met.
+// It is called concurrently by the captions handler and every scene image handler;
+// ClaimRender makes sure only one of them queues the render, and the claim and
+// the outbox write commit together.
+func checkAndEnqueueRender(ctx context.Context, videos repository.VideoStore, store storage.BlobStore, urlTTL time.Duration, videoID int) {
+	claimed := false
+	err := videos.Transaction(ctx, func(tx repository.VideoStore) error {
+		var err error
+		claimed, err = tx.ClaimRender(ctx, videoID)
+		if err != nil || !claimed {
+			return err
+		}
+		payload, err := renderPayload(ctx, tx, store, urlTTL, videoID)
 		if err != nil {
-			log.Printf("ERROR: Failed to enqueue render video task for video_id=%d: %v", videoID, err)
-		} else {
-			log.Printf("Successfully enqueued render video task for video_id=%d", videoID)
-		}
-	} else {
-		log.Printf("ERROR: Queue client not initialized, cannot enqueue render task")
+			return err
+		}
+		return tx.WriteOutbox(ctx, queue.TypeRenderVideo, payload)
+	})
+	switch {
+	case err != nil && claimed:
+		log.Printf("ERROR: Failed to queue render task for video_id=%d: %v", videoID, err)
+		// The claim rolled back with the outbox write and nothing else will pick
+		// the video up; fail it so it can be retried
+		if err := markVideoFailed(ctx, videos, videoID, fmt.Sprintf("failed to queue render: %v", err)); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
+	case err != nil:
+		log.Printf("ERROR: Failed to claim render for video_id=%d: %v", videoID, err)
+	case !claimed:
+		log.Printf("Render prerequisites not met or already claimed for video_id=%d, skipping render", videoID)
+	default:
+		log.Printf("All prerequisites met for video_id=%d, queued render task", videoID)
 	}
 }
 
-// RemotionLambdaRequest represents the payload sent to Remotion Lambda
-type RemotionLambdaRequest struct {
-	Scenes []struct {
-		ImageURL string `json:"image_url"`
-		Index    int    `json:"index"`
-	} `json:"scenes"`
-	Captions []struct {
-		Word      string  `json:"word"`
-		StartTime float64 `json:"start_time"`
-		EndTime   float64 `json:"end_time"`
-	} `json:"captions"`
-	AudioURL      string  `json:"audioUrl"`
-	VideoDuration float64 `json:"videoDuration"`
-	VideoID       int     `json:"videoId"`
-}
-
-// RemotionLambdaResponse represents the response from Remotion Lambda
-type RemotionLambdaResponse struct {
-	Success  bool    `json:"success"`
-	VideoURL *string `json:"videoUrl,omitempty"`
-	RenderId *string `json:"renderId,omitempty"`
-	Error    *string `json:"error,omitempty"`
-}
-
-// NewHandleRenderVideo creates a handler for video rendering with Remotion Lambda
-func NewHandleRenderVideo(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+// NewHandleRenderVideo creates a handler for video rendering. The renderer gets
+// media URLs from store that stay valid for urlTTL.
+func NewHandleRenderVideo(videos repository.VideoStore, renderer render.Renderer, store storage.BlobStore, urlTTL time.Duration) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.RenderVideoPayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Starting video render for video_id=%d", payload.VideoID)
 
-		// Update status to "rendering"
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID     int
-				Status string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Update("status", "rendering").Error; err != nil {
-			return fmt.Errorf("failed to update video status: %w", err)
+		run := startStep(ctx, videos, queue.TypeRenderVideo, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
+		if err := advanceStatus(ctx, videos, payload.VideoID, models.VideoStatusReadyToRender, models.VideoStatusRendering, "render started"); err != nil {
+			return err
 		}
 
 		// Fetch video with all required data
-		var video struct {
-			ID       int
-			AudioURL *string
-			Captions *string
-		}
-		if err := db.WithContext(ctx).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			First(&video).Error; err != nil {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
+		video, err := videos.GetVideo(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video: %w", err)
 		}
 
-		// Validate audio URL
-		if video.AudioURL == nil || *video.AudioURL == "" {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
-			return fmt.Errorf("video has no audio URL")
+		// Validate audio
+		audio := storedMedia{Bucket: video.AudioBucket, Key: video.AudioKey, LegacyURL: video.AudioURL}
+		if !audio.exists() {
+			return fmt.Errorf("video has no audio")
+		}
+		audioURL, err := audio.url(ctx, store, urlTTL)
+		if err != nil {
+			return fmt.Errorf("failed to sign audio URL: %w", err)
 		}
 
 		// Validate captions
 		if video.Captions == nil || *video.Captions == "" {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
 			return fmt.Errorf("video has no captions")
 		}
 
 		// Parse captions JSON
-		var captionsData []struct {
-			Word      string  `json:"word"`
-			StartTime float64 `json:"start_time"`
-			EndTime   float64 `json:"end_time"`
-		}
+		var captionsData []render.RemotionCaption
 		if err := json.Unmarshal([]byte(*video.Captions), &captionsData); err != nil {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
 			return fmt.Errorf("failed to parse captions: %w", err)
 		}
 
 		// Fetch all scenes ordered by index
-		var scenes []struct {
-			ID       int
-			VideoID  int
-			ImageURL *string
-			Index    int
-		}
-		if err := db.WithContext(ctx).
-			Table("video_scenes").
-			Where("video_id = ?", payload.VideoID).
-			Order("index ASC").
-			Find(&scenes).Error; err != nil {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
+		scenes, err := videos.ListScenes(ctx, payload.VideoID)
+		if err != nil {
 			return fmt.Errorf("failed to fetch video scenes: %w", err)
 		}
 
 		if len(scenes) == 0 {
-			updateVideoStatusToFailed(ctx, db, payload.VideoID)
 			return fmt.Errorf("no scenes found for video")
 		}
 
@@ -873,84 +564,72 @@ func NewHandleRenderVideo(db *gorm.DB) func(context.Context, *asynq.Task) error
 			videoDuration = float64(len(scenes)) * 5.0
 		}
 
-		// Prepare Lambda request
-		lambdaReq := RemotionLambdaRequest{
-			AudioURL:      *video.AudioURL,
+		// Prepare render request
+		renderReq := render.RemotionLambdaRequest{
+			Captions:      captionsData,
+			AudioURL:      audioURL,
 			VideoDuration: videoDuration,
 			VideoID:       payload.VideoID,
 		}
 
 		// Add scenes
 		for _, scene := range scenes {
-			if scene.ImageURL == nil || *scene.ImageURL == "" {
-				updateVideoStatusToFailed(ctx, db, payload.VideoID)
-				return fmt.Errorf("scene %d has no image URL", scene.Index)
+			image := storedMedia{Bucket: scene.ImageBucket, Key: scene.ImageKey, LegacyURL: scene.ImageURL}
+			if !image.exists() {
+				return fmt.Errorf("scene %d has no image", scene.Index)
+			}
+			imageURL, err := image.url(ctx, store, urlTTL)
+			if err != nil {
+				return fmt.Errorf("failed to sign image URL for scene %d: %w", scene.Index, err)
 			}
-			lambdaReq.Scenes = append(lambdaReq.Scenes, struct {
-				ImageURL string `json:"image_url"`
-				Index    int    `json:"index"`
-			}{
-				ImageURL: *scene.ImageURL,
+			renderReq.Scenes = append(renderReq.Scenes, render.RemotionScene{
+				ImageURL: imageURL,
 				Index:    scene.Index,
 			})
 		}
 
-		// Add captions
-		for _, caption := range captionsData {
-			lambdaReq.Captions = append(lambdaReq.Captions, struct {
-				Word      string  `json:"word"`
-				StartTime float64 `json:"start_time"`
-				EndTime   float64 `json:"end_time"`
-			}{
-				Word:      caption.Word,
-				StartTime: caption.StartTime,
-				EndTime:   caption.EndTime,
-			})
+		// Stop before paying for work on a cancelled or deleted video
+		if err := ensureVideoActive(ctx, videos, payload.VideoID); err != nil {
+			return err
+		}
+
+		// Render the video
+		videoURL, err := renderer.Render(ctx, renderReq)
+		if err != nil {
+			log.Printf("ERROR: Failed to render video_id=%d: %v", payload.VideoID, err)
+			return fmt.Errorf("failed to render video: %w", err)
 		}
 
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID       int
-				VideoURL string
-				Status   string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Updates(map[string]interface{}{
-				"status": "completed",
-			}).Error; err != nil {
-			return fmt.Errorf("failed to update video with final URL: %w", err)
+		log.Printf("Render completed: video_url=%s", videoURL)
+
+		// Update video with final URL and status
+		if err := completeVideo(ctx, videos, payload.VideoID, videoURL); err != nil {
+			return err
 		}
 
-		log.Printf("Video render completed successfully: video_id=%d", payload.VideoID)
+		log.Printf("Video render completed successfully: video_id=%d, video_url=%s", payload.VideoID, videoURL)
 		return nil
 	}
 }
 
 // NewHandleVideoComplete creates a handler for video completion tasks
-func NewHandleVideoComplete(db *gorm.DB) func(context.Context, *asynq.Task) error {
-	return func(ctx context.Context, t *asynq.Task) error {
+func NewHandleVideoComplete(videos repository.VideoStore) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) (err error) {
 		var payload queue.VideoCompletePayload
-		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
-			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		meta, err := queue.DecodePayload(t.Payload(), &payload)
+		if err != nil {
+			return fmt.Errorf("failed to decode payload: %w", err)
 		}
+		ctx = queue.ContextWithMeta(ctx, meta)
 
 		log.Printf("Processing video_complete task for video_id=%d", payload.VideoID)
 
+		run := startStep(ctx, videos, queue.TypeVideoComplete, payload.VideoID, nil)
+		defer func() { run.finish(ctx, err); err = skipCancelled(err) }()
+
 		// Update video with final URL and status
-		if err := db.WithContext(ctx).
-			Model(&struct {
-				ID       int
-				VideoURL string
-				Status   string
-			}{}).
-			Table("videos").
-			Where("id = ?", payload.VideoID).
-			Updates(map[string]interface{}{
-				"video_url": payload.VideoURL,
-				"status":    "completed",
-			}).Error; err != nil {
-			return fmt.Errorf("failed to update video with final URL: %w", err)
+		if err := completeVideo(ctx, videos, payload.VideoID, payload.VideoURL); err != nil {
+			return err
 		}
 
 		log.Printf("Video completion processed successfully: video_id=%d, video_url=%s", payload.VideoID, payload.VideoURL)
@@ -958,15 +637,18 @@ func NewHandleVideoComplete(db *gorm.DB) func(context.Context, *asynq.Task) erro
 	}
 }
 
-// Helper functions for video rendering
-
-func updateVideoStatusToFailed(ctx context.Context, db *gorm.DB, videoID int) {
-	db.WithContext(ctx).
-		Model(&struct {
-			ID     int
-			Status string
-		}{}).
-		Table("videos").
-		Where("id = ?", videoID).
-		Update("status", "failed")
+// completeVideo records the rendered video. A video that was cancelled or
+// failed while it rendered keeps its status, and retrying can't change that.
+func completeVideo(ctx context.Context, videos repository.VideoStore, videoID int, videoURL string) error {
+	err := videos.CompleteVideo(ctx, videoID, videoURL)
+	if errors.Is(err, repository.ErrStatusChanged) {
+		if err := ensureVideoActive(ctx, videos, videoID); err != nil {
+			return err
+		}
+		return fmt.Errorf("failed to update video with final URL: %w: %w", err, asynq.SkipRetry)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update video with final URL: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,302 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"instashorts-be/is-worker/internal/ai"
+	"instashorts-be/is-worker/internal/render"
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+	"instashorts-be/pkg/repository"
+	"instashorts-be/pkg/storage"
+
+	"github.com/hibiken/asynq"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func getVideo(t *testing.T, videos repository.VideoStore, id int) *models.Video {
+	t.Helper()
+	video, err := videos.GetVideo(context.Background(), id)
+	if err != nil {
+		t.Fatalf("Failed to fetch video %d: %v", id, err)
+	}
+	return video
+}
+
+// outboxTypes returns the types of the tasks written to the outbox in order
+func outboxTypes(videos *repository.MemoryVideoStore) []string {
+	var types []string
+	for _, task := range videos.OutboxTasks() {
+		types = append(types, task.TaskType)
+	}
+	return types
+}
+
+func TestHandleGenerateVideoScript(t *testing.T) {
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Theme: "reefs", Status: models.VideoStatusPending})
+
+	writer := &countingScriptWriter{}
+	handler := NewHandleGenerateVideoScript(videos, writer)
+	if err := handler(context.Background(), newTestTask(t, queue.GenerateVideoScriptPayload{VideoID: 1})); err != nil {
+		t.Fatalf("Handler returned error: %v", err)
+	}
+
+	// The video waits for its audio and scenes, it isn't completed yet
+	video := getVideo(t, videos, 1)
+	if video.Status != models.VideoStatusGeneratingAudio || video.Script == nil || *video.Script != "script" {
+		t.Errorf("Expected the script to be saved, got status=%s script=%v", video.Status, video.Script)
+	}
+	transitions, _ := videos.ListStatusTransitions(context.Background(), 1)
+	if len(transitions) != 2 || transitions[0].ToStatus != models.VideoStatusGeneratingScript || transitions[1].ToStatus != models.VideoStatusGeneratingAudio {
+		t.Errorf("Expected the video to move through generating_script, got %+v", transitions)
+	}
+	if got := outboxTypes(videos); len(got) != 2 || got[0] != queue.TypeGenerateAudio || got[1] != queue.TypeGenerateScenes {
+		t.Errorf("Expected audio and scenes to be queued, got %v", got)
+	}
+	steps := videos.PipelineSteps(1)
+	if len(steps) != 1 || steps[0].Step != queue.TypeGenerateVideoScript || steps[0].Outcome != models.PipelineStepSucceeded {
+		t.Errorf("Expected one succeeded step, got %+v", steps)
+	}
+}
+
+func TestCheckAndEnqueueRenderWritesOutbox(t *testing.T) {
+	store, err := storage.NewLocalStoreWithConfig(t.TempDir(), "http://localhost/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+	}
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Status: models.VideoStatusGeneratingImages, AudioKey: strPtr("audio/1.mp3"), Captions: strPtr("[]")})
+	sceneID := videos.AddScene(models.VideoScene{VideoID: 1, Status: models.SceneStatusCompleted, ImageKey: strPtr("images/1/a.png")})
+
+	// The second call finds the render already claimed and queues nothing
+	ctx := queue.WithTraceID(context.Background(), "trace-1")
+	checkAndEnqueueRender(ctx, videos, store, time.Hour, 1)
+	checkAndEnqueueRender(ctx, videos, store, time.Hour, 1)
+
+	tasks := videos.OutboxTasks()
+	if len(tasks) != 1 || tasks[0].TaskType != queue.TypeRenderVideo {
+		t.Fatalf("Expected one render task in the outbox, got %+v", tasks)
+	}
+	var payload queue.RenderVideoPayload
+	meta, err := queue.DecodePayload(tasks[0].Payload, &payload)
+	if err != nil || payload.VideoID != 1 {
+		t.Errorf("Expected the render of video 1, got %+v (%v)", payload, err)
+	}
+	if meta.TraceID != "trace-1" {
+		t.Errorf("Expected the render to continue the trace, got %q", meta.TraceID)
+	}
+	// The renderer service reads its media from URLs signed by the worker
+	if !strings.Contains(payload.AudioURL, "audio/1.mp3") || len(payload.SceneImages) != 1 || payload.SceneImages[0].SceneID != sceneID || !strings.Contains(payload.SceneImages[0].ImageURL, "images/1/a.png") {
+		t.Errorf("Expected signed media URLs in the render task, got %+v", payload)
+	}
+}
+
+// countingDirector returns fixed scenes and counts how often it was paid for
+type countingDirector struct {
+	calls int
+}
+
+func (d *countingDirector) GenerateScenes(ctx context.Context, script string) ([]ai.ScenePrompt, error) {
+	d.calls++
+	return []ai.ScenePrompt{{Index: 0, ImagePrompt: "a reef"}, {Index: 1, ImagePrompt: "an octopus"}}, nil
+}
+
+func TestHandleGenerateScenesIsIdempotent(t *testing.T) {
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Status: models.VideoStatusGeneratingAudio, Script: strPtr("Octopuses have three hearts.")})
+
+	director := &countingDirector{}
+	handler := NewHandleGenerateScenes(videos, director)
+	task := newTestTask(t, queue.GenerateScenesPayload{VideoID: 1})
+	if err := handler(context.Background(), task); err != nil {
+		t.Fatalf("First run returned error: %v", err)
+	}
+
+	// The first scene's image lands before the task is delivered again
+	scenes, _ := videos.ListScenes(context.Background(), 1)
+	if len(scenes) != 2 {
+		t.Fatalf("Expected 2 scenes, got %d", len(scenes))
+	}
+	videos.SetSceneStatus(context.Background(), scenes[0].ID, models.SceneStatusCompleted)
+	if err := handler(context.Background(), task); err != nil {
+		t.Fatalf("Second run returned error: %v", err)
+	}
+
+	if director.calls != 1 {
+		t.Errorf("Expected the scenes to be generated once, got %d calls", director.calls)
+	}
+	if scenes, _ := videos.ListScenes(context.Background(), 1); len(scenes) != 2 {
+		t.Errorf("Expected 2 scenes, got %d", len(scenes))
+	}
+	if got := outboxTypes(videos); len(got) != 3 {
+		t.Errorf("Expected 2 image tasks and one more for the unfinished scene, got %v", got)
+	}
+	if video := getVideo(t, videos, 1); video.Status != models.VideoStatusGeneratingImages {
+		t.Errorf("Expected status generating_images, got %s", video.Status)
+	}
+}
+
+// staticImages returns the same image for every prompt
+type staticImages []byte
+
+func (s staticImages) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	return s, nil
+}
+
+func TestHandleGenerateSceneImageQueuesRenderAfterLastScene(t *testing.T) {
+	store, err := storage.NewLocalStoreWithConfig(t.TempDir(), "http://localhost/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+	}
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{ID: 1, Status: models.VideoStatusGeneratingImages, AudioKey: strPtr("audio/1.mp3"), Captions: strPtr("[]")})
+	first := videos.AddScene(models.VideoScene{VideoID: 1, Index: 0, Prompt: "a reef", Status: models.SceneStatusPending})
+	second := videos.AddScene(models.VideoScene{VideoID: 1, Index: 1, Prompt: "an octopus", Status: models.SceneStatusPending})
+
+	handler := NewHandleGenerateSceneImage(videos, staticImages("png"), store, time.Hour)
+	for i, sceneID := range []int{first, second} {
+		if err := handler(context.Background(), newTestTask(t, queue.GenerateSceneImagePayload{SceneID: sceneID})); err != nil {
+			t.Fatalf("Handler returned error: %v", err)
+		}
+		scene, _ := videos.GetScene(context.Background(), sceneID)
+		if scene.Status != models.SceneStatusCompleted || isBlank(scene.ImageKey) {
+			t.Errorf("Expected scene %d to have its image, got %+v", sceneID, scene)
+		}
+		if rendering := len(videos.OutboxTasks()) == 1; rendering != (i == 1) {
+			t.Errorf("After scene %d: expected the render to be queued only after the last scene, got %v", sceneID, outboxTypes(videos))
+		}
+	}
+
+	if video := getVideo(t, videos, 1); video.Status != models.VideoStatusReadyToRender {
+		t.Errorf("Expected status ready_to_render, got %s", video.Status)
+	}
+}
+
+// renderFunc adapts a function to render.Renderer
+type renderFunc func(ctx context.Context, req render.RemotionLambdaRequest) (string, error)
+
+func (f renderFunc) Render(ctx context.Context, req render.RemotionLambdaRequest) (string, error) {
+	return f(ctx, req)
+}
+
+func newRenderableVideo(t *testing.T) (*repository.MemoryVideoStore, storage.BlobStore) {
+	t.Helper()
+	store, err := storage.NewLocalStoreWithConfig(t.TempDir(), "http://localhost/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+	}
+	videos := repository.NewMemoryVideoStore()
+	videos.AddVideo(models.Video{
+		ID:          1,
+		Status:      models.VideoStatusReadyToRender,
+		AudioBucket: strPtr(store.Bucket()),
+		AudioKey:    strPtr("audio/1.mp3"),
+		Captions:    strPtr(`[{"word":"hi","start_time":0,"end_time":1}]`),
+	})
+	videos.AddScene(models.VideoScene{VideoID: 1, Status: models.SceneStatusCompleted, ImageBucket: strPtr(store.Bucket()), ImageKey: strPtr("images/1/a.png")})
+	return videos, store
+}
+
+func TestHandleRenderVideo(t *testing.T) {
+	videos, store := newRenderableVideo(t)
+	renderer := renderFunc(func(ctx context.Context, req render.RemotionLambdaRequest) (string, error) {
+		if video := getVideo(t, videos, 1); video.Status != models.VideoStatusRendering {
+			t.Errorf("Expected status rendering during the render, got %s", video.Status)
+		}
+		return "https://example.com/1.mp4", nil
+	})
+
+	handler := NewHandleRenderVideo(videos, renderer, store, time.Hour)
+	if err := handler(context.Background(), newTestTask(t, queue.RenderVideoPayload{VideoID: 1})); err != nil {
+		t.Fatalf("Handler returned error: %v", err)
+	}
+
+	video := getVideo(t, videos, 1)
+	if video.Status != models.VideoStatusCompleted || deref(video.VideoURL) != "https://example.com/1.mp4" {
+		t.Errorf("Expected the video to be completed, got status=%s video_url=%s", video.Status, deref(video.VideoURL))
+	}
+	transitions, _ := videos.ListStatusTransitions(context.Background(), 1)
+	if len(transitions) != 2 || transitions[0].ToStatus != models.VideoStatusRendering || transitions[1].ToStatus != models.VideoStatusCompleted {
+		t.Errorf("Expected the video to move through rendering, got %+v", transitions)
+	}
+}
+
+func TestHandleRenderVideoSkipsVideoThatIsNotReady(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  models.VideoStatus
+		skipped bool
+	}{
+		{name: "failed", status: models.VideoStatusFailed},
+		{name: "cancelled", status: models.VideoStatusCancelled, skipped: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videos, store := newRenderableVideo(t)
+			video := getVideo(t, videos, 1)
+			video.Status = tt.status
+			videos.AddVideo(*video)
+			renderer := renderFunc(func(ctx context.Context, req render.RemotionLambdaRequest) (string, error) {
+				t.Error("Expected no render of a video that isn't ready to render")
+				return "", nil
+			})
+
+			handler := NewHandleRenderVideo(videos, renderer, store, time.Hour)
+			err := handler(context.Background(), newTestTask(t, queue.RenderVideoPayload{VideoID: 1}))
+			if tt.skipped && err != nil {
+				t.Errorf("Expected the task to stop without error, got %v", err)
+			}
+			if !tt.skipped && (!errors.Is(err, asynq.SkipRetry) || !errors.Is(err, repository.ErrStatusChanged)) {
+				t.Errorf("Expected a status change error that isn't retried, got %v", err)
+			}
+			if video := getVideo(t, videos, 1); video.Status != tt.status {
+				t.Errorf("Expected the video to stay %s, got %s", tt.status, video.Status)
+			}
+		})
+	}
+}
+
+func TestHandleRenderVideoKeepsStatusChangedDuringRender(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  models.VideoStatus
+		skipped bool
+	}{
+		{name: "cancelled", status: models.VideoStatusCancelled, skipped: true},
+		{name: "failed", status: models.VideoStatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			videos, store := newRenderableVideo(t)
+			renderer := renderFunc(func(ctx context.Context, req render.RemotionLambdaRequest) (string, error) {
+				if err := videos.Transition(ctx, 1, models.VideoStatusRendering, tt.status, "changed during the render"); err != nil {
+					t.Fatalf("Transition returned error: %v", err)
+				}
+				return "https://example.com/1.mp4", nil
+			})
+
+			handler := NewHandleRenderVideo(videos, renderer, store, time.Hour)
+			err := handler(context.Background(), newTestTask(t, queue.RenderVideoPayload{VideoID: 1}))
+			if tt.skipped && err != nil {
+				t.Errorf("Expected the task to stop without error, got %v", err)
+			}
+			if !tt.skipped && !errors.Is(err, asynq.SkipRetry) {
+				t.Errorf("Expected an error that isn't retried, got %v", err)
+			}
+
+			if video := getVideo(t, videos, 1); video.Status != tt.status || video.VideoURL != nil {
+				t.Errorf("Expected the video to stay %s, got status=%s video_url=%s", tt.status, video.Status, deref(video.VideoURL))
+			}
+		})
+	}
+}
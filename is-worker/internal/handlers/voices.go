@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"instashorts-be/is-worker/internal/ai"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// voiceRow represents a row in the voices catalog table
+type voiceRow struct {
+	VoiceID     string
+	Name        string
+	Category    string
+	Language    string
+	Gender      string
+	Accent      string
+	Age         string
+	Description string
+	PreviewURL  string
+	SyncedAt    time.Time
+	UpdatedAt   time.Time
+	DeletedAt   *time.Time
+}
+
+// NewHandleSyncVoices creates a handler that syncs the ElevenLabs voice catalog into the voices table
+func NewHandleSyncVoices(db *gorm.DB) func(context.Context, *asynq.Task) error {
+	return func(ctx context.Context, t *asynq.Task) error {
+		log.Printf("Syncing voice catalog from ElevenLabs")
+
+		elevenLabsService, err := ai.NewElevenLabsService()
+		if err != nil {
+			return fmt.Errorf("failed to create ElevenLabs service: %w", err)
+		}
+
+		voices, err := elevenLabsService.ListVoices(ctx)
+		if err != nil {
+			return providerError(fmt.Errorf("failed to list voices: %w", err))
+		}
+
+		if len(voices) == 0 {
+			// Never wipe the catalog because of an empty response
+			return fmt.Errorf("ElevenLabs returned no voices")
+		}
+
+		now := time.Now().UTC()
+		rows := make([]voiceRow, 0, len(voices))
+		voiceIDs := make([]string, 0, len(voices))
+		for _, v := range voices {
+			rows = append(rows, voiceRow{
+				VoiceID:     v.VoiceID,
+				Name:        v.Name,
+				Category:    v.Category,
+				Language:    v.Language,
+				Gender:      v.Gender,
+				Accent:      v.Accent,
+				Age:         v.Age,
+				Description: v.Description,
+				PreviewURL:  v.PreviewURL,
+				SyncedAt:    now,
+				UpdatedAt:   now,
+			})
+			voiceIDs = append(voiceIDs, v.VoiceID)
+		}
+
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// Upsert every voice we got back, restoring previously removed ones
+			if err := tx.Table("voices").
+				Clauses(clause.OnConflict{
+					Columns: []clause.Column{{Name: "voice_id"}},
+					DoUpdates: clause.AssignmentColumns([]string{
+						"name", "category", "language", "gender", "accent", "age",
+						"description", "preview_url", "synced_at", "updated_at", "deleted_at",
+					}),
+				}).
+				Create(&rows).Error; err != nil {
+				return fmt.Errorf("failed to upsert voices: %w", err)
+			}
+
+			// Soft delete voices that are no longer available
+			if err := tx.Table("voices").
+				Where("voice_id NOT IN ? AND deleted_at IS NULL", voiceIDs).
+				Update("deleted_at", now).Error; err != nil {
+				return fmt.Errorf("failed to remove stale voices: %w", err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		log.Printf("Voice catalog sync completed: %d voices", len(voices))
+		return nil
+	}
+}
@@ -0,0 +1,90 @@
+package render
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// HTTPRenderer renders videos by POSTing a RemotionLambdaRequest to a render
+// endpoint (a Remotion Lambda function URL or the is-render HTTP server)
+type HTTPRenderer struct {
+	endpoint   string
+	authToken  string
+	httpClient *http.Client
+}
+
+// NewHTTPRenderer creates a renderer from the RENDERER_URL environment variable.
+// RENDERER_AUTH_TOKEN is sent as a bearer token when set.
+func NewHTTPRenderer() (*HTTPRenderer, error) {
+	endpoint := os.Getenv("RENDERER_URL")
+	if endpoint == "" {
+		return nil, fmt.Errorf("RENDERER_URL environment variable not set")
+	}
+
+	return NewHTTPRendererWithClient(endpoint, os.Getenv("RENDERER_AUTH_TOKEN"), &http.Client{
+		// Rendering a short takes minutes, not seconds
+		Timeout: 15 * time.Minute,
+	}), nil
+}
+
+// NewHTTPRendererWithClient creates a renderer for an explicit endpoint and HTTP client
+func NewHTTPRendererWithClient(endpoint string, authToken string, httpClient *http.Client) *HTTPRenderer {
+	return &HTTPRenderer{
+		endpoint:   endpoint,
+		authToken:  authToken,
+		httpClient: httpClient,
+	}
+}
+
+// Render sends the render request and returns the URL of the rendered video
+func (r *HTTPRenderer) Render(ctx context.Context, req RemotionLambdaRequest) (string, error) {
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal render request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, r.endpoint, bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if r.authToken != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+r.authToken)
+	}
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to call renderer: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read renderer response: %w", err)
+	}
+
+	var renderResp RemotionLambdaResponse
+	if err := json.Unmarshal(body, &renderResp); err != nil {
+		return "", fmt.Errorf("renderer returned status %d with invalid body: %s", resp.StatusCode, string(body))
+	}
+
+	if resp.StatusCode != http.StatusOK || !renderResp.Success {
+		errorMsg := "unknown error"
+		if renderResp.Error != nil {
+			errorMsg = *renderResp.Error
+		}
+		return "", fmt.Errorf("render failed with status %d: %s", resp.StatusCode, errorMsg)
+	}
+
+	if renderResp.VideoURL == nil || *renderResp.VideoURL == "" {
+		return "", fmt.Errorf("renderer returned empty video URL")
+	}
+
+	return *renderResp.VideoURL, nil
+}
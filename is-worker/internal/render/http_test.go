@@ -0,0 +1,103 @@
+package render
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestRenderer(t *testing.T, handler http.HandlerFunc) *HTTPRenderer {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return NewHTTPRendererWithClient(srv.URL, "secret", srv.Client())
+}
+
+func TestHTTPRendererRender(t *testing.T) {
+	var received RemotionLambdaRequest
+	renderer := newTestRenderer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Expected bearer token, got %q", got)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"success":true,"videoUrl":"https://cdn.example.com/videos/42.mp4","renderId":"r-1"}`))
+	})
+
+	req := RemotionLambdaRequest{
+		Scenes:        []RemotionScene{{ImageURL: "https://example.com/0.png", Index: 0}},
+		Captions:      []RemotionCaption{{Word: "hello", StartTime: 0, EndTime: 0.4}},
+		AudioURL:      "https://example.com/audio.mp3",
+		VideoDuration: 0.4,
+		VideoID:       42,
+	}
+
+	videoURL, err := renderer.Render(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if videoURL != "https://cdn.example.com/videos/42.mp4" {
+		t.Errorf("Expected rendered video URL, got %s", videoURL)
+	}
+	if received.VideoID != 42 || received.AudioURL != req.AudioURL || len(received.Scenes) != 1 || len(received.Captions) != 1 {
+		t.Errorf("Renderer received unexpected request: %+v", received)
+	}
+}
+
+func TestHTTPRendererRenderFailures(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{
+			name:    "render reported failure",
+			status:  http.StatusOK,
+			body:    `{"success":false,"error":"composition crashed"}`,
+			wantErr: "composition crashed",
+		},
+		{
+			name:    "success without video URL",
+			status:  http.StatusOK,
+			body:    `{"success":true}`,
+			wantErr: "empty video URL",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusBadGateway,
+			body:    `{"success":false,"error":"upstream timeout"}`,
+			wantErr: "status 502",
+		},
+		{
+			name:    "non JSON body",
+			status:  http.StatusInternalServerError,
+			body:    `internal error`,
+			wantErr: "invalid body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			renderer := newTestRenderer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			videoURL, err := renderer.Render(context.Background(), RemotionLambdaRequest{VideoID: 1})
+			if err == nil {
+				t.Fatalf("Expected error, got video URL %q", videoURL)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package render
+
+import (
+	"context"
+)
+
+// Renderer turns a fully prepared video (scenes, captions and audio) into a
+// final video file and returns its URL
+type Renderer interface {
+	Render(ctx context.Context, req RemotionLambdaRequest) (string, error)
+}
+
+// RemotionScene represents a single scene image in the render request
+type RemotionScene struct {
+	ImageURL string `json:"image_url"`
+	Index    int    `json:"index"`
+}
+
+// RemotionCaption represents a word with timing information in the render request
+type RemotionCaption struct {
+	Word      string  `json:"word"`
+	StartTime float64 `json:"start_time"`
+	EndTime   float64 `json:"end_time"`
+}
+
+// RemotionLambdaRequest represents the payload sent to Remotion Lambda
+type RemotionLambdaRequest struct {
+	Scenes        []RemotionScene   `json:"scenes"`
+	Captions      []RemotionCaption `json:"captions"`
+	AudioURL      string            `json:"audioUrl"`
+	VideoDuration float64           `json:"videoDuration"`
+	VideoID       int               `json:"videoId"`
+}
+
+// RemotionLambdaResponse represents the response from Remotion Lambda
+type RemotionLambdaResponse struct {
+	Success  bool    `json:"success"`
+	VideoURL *string `json:"videoUrl,omitempty"`
+	RenderId *string `json:"renderId,omitempty"`
+	Error    *string `json:"error,omitempty"`
+}
@@ -0,0 +1,140 @@
+// Package events carries live video events from the services that change
+// videos to the API, over a Redis pub/sub channel per user
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"instashorts-be/pkg/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Type is the kind of change an event reports
+type Type string
+
+const (
+	// TypeStatus reports a video status change
+	TypeStatus Type = "status"
+	// TypeStep reports the start or outcome of a pipeline step attempt
+	TypeStep Type = "step"
+)
+
+// channelPrefix is followed by the user ID in the name of each user's channel
+const channelPrefix = "instashorts:events:user:"
+
+// Event is a change to a video, published on the channel of its owner
+type Event struct {
+	Type    Type `json:"type"`
+	UserID  int  `json:"user_id"`
+	VideoID int  `json:"video_id"`
+	// Status is the status of the video after the change
+	Status models.VideoStatus `json:"status"`
+	// Reason is why the status changed, for status events
+	Reason string `json:"reason,omitempty"`
+	// Step, SceneID, Attempt, Outcome and Error describe the attempt, for step events
+	Step    string                     `json:"step,omitempty"`
+	SceneID *int                       `json:"scene_id,omitempty"`
+	Attempt int                        `json:"attempt,omitempty"`
+	Outcome models.PipelineStepOutcome `json:"outcome,omitempty"`
+	Error   *string                    `json:"error,omitempty"`
+	At      time.Time                  `json:"at"`
+}
+
+// Channel returns the name of the channel the events of a user's videos go to
+func Channel(userID int) string {
+	return channelPrefix + strconv.Itoa(userID)
+}
+
+// Publisher sends events to whoever is listening. Events are best-effort:
+// nobody may be listening, and nothing is kept for listeners that connect later.
+type Publisher interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// Discard is the Publisher that drops every event
+var Discard Publisher = discard{}
+
+type discard struct{}
+
+func (discard) Publish(ctx context.Context, event Event) error {
+	return nil
+}
+
+// RedisPublisher publishes events on the Redis channel of each event's user
+type RedisPublisher struct {
+	client *redis.Client
+}
+
+// NewRedisPublisher creates a publisher for the Redis server at addr
+func NewRedisPublisher(addr string) *RedisPublisher {
+	return &RedisPublisher{client: redis.NewClient(&redis.Options{Addr: addr})}
+}
+
+func (p *RedisPublisher) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	if err := p.client.Publish(ctx, Channel(event.UserID), data).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection to Redis
+func (p *RedisPublisher) Close() error {
+	return p.client.Close()
+}
+
+// Listen subscribes to the channels of every user on the Redis server at addr
+// and calls handle with each event until ctx is done. The connection is
+// re-established after errors; events published meanwhile are lost.
+func Listen(ctx context.Context, addr string, handle func(Event)) error {
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+
+	sub := client.PSubscribe(ctx, channelPrefix+"*")
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		return fmt.Errorf("failed to subscribe to events: %w", err)
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			if event, ok := decodeMessage(msg); ok {
+				handle(event)
+			}
+		}
+	}
+}
+
+// decodeMessage reads the event in msg. The channel is authoritative for the
+// user, whatever the payload says.
+func decodeMessage(msg *redis.Message) (Event, bool) {
+	var event Event
+	if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+		log.Printf("ERROR: Failed to decode event on %s: %v", msg.Channel, err)
+		return Event{}, false
+	}
+	userID, err := strconv.Atoi(strings.TrimPrefix(msg.Channel, channelPrefix))
+	if err != nil {
+		log.Printf("ERROR: Event on unexpected channel %s", msg.Channel)
+		return Event{}, false
+	}
+	event.UserID = userID
+	return event, true
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// VideoStatus represents the status of a video
+type VideoStatus string
+
+const (
+	VideoStatusPending          VideoStatus = "pending"
+	VideoStatusGeneratingScript VideoStatus = "generating_script"
+	VideoStatusGeneratingAudio  VideoStatus = "generating_audio"
+	VideoStatusGeneratingScenes VideoStatus = "generating_scenes"
+	VideoStatusGeneratingImages VideoStatus = "generating_images"
+	VideoStatusReadyToRender    VideoStatus = "ready_to_render"
+	VideoStatusRendering        VideoStatus = "rendering"
+	VideoStatusCompleted        VideoStatus = "completed"
+	VideoStatusFailed           VideoStatus = "failed"
+	VideoStatusCancelled        VideoStatus = "cancelled"
+)
+
+// Scene statuses
+const (
+	SceneStatusPending    = "pending"
+	SceneStatusGenerating = "generating"
+	SceneStatusCompleted  = "completed"
+	SceneStatusFailed     = "failed"
+)
+
+// Series represents a collection of videos
+type Series struct {
+	ID        int            `json:"id" gorm:"primaryKey"`
+	UserID    int            `json:"user_id" gorm:"not null;index"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// Caption represents a word with timing information
+type Caption struct {
+	Word      string  `json:"word"`
+	StartTime float64 `json:"start_time"` // in seconds
+	EndTime   float64 `json:"end_time"`   // in seconds
+}
+
+// Video represents a video in the system
+type Video struct {
+	ID            int            `json:"id" gorm:"primaryKey"`
+	UserID        int            `json:"user_id" gorm:"not null;index"`
+	SeriesID      *int           `json:"series_id,omitempty" gorm:"index"`
+	Title         *string        `json:"title,omitempty"`
+	Theme         string         `json:"theme" gorm:"not null"`
+	VoiceID       string         `json:"voice_id" gorm:"not null"`
+	VoiceSettings *VoiceSettings `json:"voice_settings,omitempty" gorm:"type:jsonb;serializer:json"`
+	Script        *string        `json:"script,omitempty" gorm:"type:text"`
+	AudioBucket   *string        `json:"-"`
+	AudioKey      *string        `json:"-" gorm:"type:text"`
+	AudioURL      *string        `json:"audio_url,omitempty" gorm:"type:text"` // Signed from AudioKey when the video is read
+	VideoURL      *string        `json:"video_url,omitempty" gorm:"type:text"` // Final rendered video URL
+	Captions      *string        `json:"captions,omitempty" gorm:"type:jsonb"` // JSON array of Caption objects
+	Status        VideoStatus    `json:"status" gorm:"type:varchar(50);not null;default:'pending';index"`
+	FailureReason *string        `json:"failure_reason,omitempty" gorm:"type:text"` // Why the video failed: the error of its last attempt, or the reaper's verdict
+	Scenes        []VideoScene   `json:"scenes,omitempty" gorm:"foreignKey:VideoID"`
+	CreatedAt     time.Time      `json:"created_at"`
+	CompletedAt   time.Time      `json:"completed_at,omitempty"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// VideoScene represents a scene in a video with its image
+type VideoScene struct {
+	ID          int            `json:"id" gorm:"primaryKey"`
+	VideoID     int            `json:"video_id" gorm:"not null;index;uniqueIndex:video_scenes_video_id_index_key,priority:1"`
+	Prompt      string         `json:"prompt" gorm:"type:text;not null"`
+	ImageBucket *string        `json:"-"`
+	ImageKey    *string        `json:"-" gorm:"type:text"`
+	ImageURL    *string        `json:"image_url,omitempty" gorm:"type:text"` // Signed from ImageKey when the video is read
+	Index       int            `json:"index" gorm:"not null;uniqueIndex:video_scenes_video_id_index_key,priority:2"`
+	Status      string         `json:"status" gorm:"type:varchar(50);not null;default:'pending';index"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// PipelineStepOutcome represents the outcome of a pipeline step attempt
+type PipelineStepOutcome string
+
+const (
+	PipelineStepRunning   PipelineStepOutcome = "running"
+	PipelineStepSucceeded PipelineStepOutcome = "succeeded"
+	PipelineStepFailed    PipelineStepOutcome = "failed"
+	PipelineStepCancelled PipelineStepOutcome = "cancelled"
+)
+
+// PipelineStep represents a single attempt of a pipeline step for a video
+type PipelineStep struct {
+	ID         int                 `json:"id" gorm:"primaryKey"`
+	VideoID    int                 `json:"video_id" gorm:"not null;index"`
+	SceneID    *int                `json:"scene_id,omitempty" gorm:"index"`
+	Step       string              `json:"step" gorm:"type:varchar(100);not null"`
+	Attempt    int                 `json:"attempt" gorm:"not null;default:1"`
+	TaskID     *string             `json:"task_id,omitempty"`
+	Outcome    PipelineStepOutcome `json:"outcome" gorm:"type:varchar(50);not null;default:'running'"`
+	Error      *string             `json:"error,omitempty" gorm:"type:text"`
+	StartedAt  time.Time           `json:"started_at"`
+	FinishedAt *time.Time          `json:"finished_at,omitempty"`
+	DurationMS *int64              `json:"duration_ms,omitempty" gorm:"-"`
+	CreatedAt  time.Time           `json:"created_at"`
+}
+
+// TableName overrides the default table name for GORM
+func (PipelineStep) TableName() string {
+	return "video_pipeline_steps"
+}
+
+// VoiceSettings overrides how the selected voice is rendered.
+// Nil fields use the worker's defaults.
+type VoiceSettings struct {
+	Stability       *float64 `json:"stability,omitempty" binding:"omitempty,gte=0,lte=1"`
+	SimilarityBoost *float64 `json:"similarity_boost,omitempty" binding:"omitempty,gte=0,lte=1"`
+	Style           *float64 `json:"style,omitempty" binding:"omitempty,gte=0,lte=1"`
+	UseSpeakerBoost *bool    `json:"use_speaker_boost,omitempty"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// videoTransitions lists the statuses a video may move to from each status.
+// The script moves a video into the audio and scene branches, which run in
+// parallel; the status follows the scene branch, since its images gate the
+// render. Failed videos are retried from their first missing step, and
+// completed and cancelled videos never move again.
+var videoTransitions = map[VideoStatus][]VideoStatus{
+	VideoStatusPending:          {VideoStatusGeneratingScript, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusGeneratingScript: {VideoStatusGeneratingAudio, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusGeneratingAudio:  {VideoStatusGeneratingScenes, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusGeneratingScenes: {VideoStatusGeneratingImages, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusGeneratingImages: {VideoStatusReadyToRender, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusReadyToRender:    {VideoStatusRendering, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusRendering:        {VideoStatusCompleted, VideoStatusFailed, VideoStatusCancelled},
+	VideoStatusFailed: {
+		VideoStatusPending,
+		VideoStatusGeneratingAudio,
+		VideoStatusGeneratingScenes,
+		VideoStatusGeneratingImages,
+		VideoStatusReadyToRender,
+		VideoStatusCancelled,
+	},
+	VideoStatusCompleted: nil,
+	VideoStatusCancelled: nil,
+}
+
+// videoPipeline is the order a video goes through on its way to completed
+var videoPipeline = []VideoStatus{
+	VideoStatusPending,
+	VideoStatusGeneratingScript,
+	VideoStatusGeneratingAudio,
+	VideoStatusGeneratingScenes,
+	VideoStatusGeneratingImages,
+	VideoStatusReadyToRender,
+	VideoStatusRendering,
+	VideoStatusCompleted,
+}
+
+// Reached reports whether a video in status s is at or past target on its way
+// to completed. Failed and cancelled videos are off the way, so they reach
+// nothing but themselves.
+func (s VideoStatus) Reached(target VideoStatus) bool {
+	if s == target {
+		return true
+	}
+	at, want := slices.Index(videoPipeline, s), slices.Index(videoPipeline, target)
+	return at >= 0 && want >= 0 && at >= want
+}
+
+// CanTransition reports whether a video may move from one status to another
+func CanTransition(from, to VideoStatus) bool {
+	for _, next := range videoTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// TransitionsTo returns the statuses a video may move to status from, in order
+func TransitionsTo(status VideoStatus) []VideoStatus {
+	var from []VideoStatus
+	for s := range videoTransitions {
+		if CanTransition(s, status) {
+			from = append(from, s)
+		}
+	}
+	sort.Slice(from, func(i, j int) bool { return from[i] < from[j] })
+	return from
+}
+
+// VideoStatusTransition records a status change of a video and why it happened
+type VideoStatusTransition struct {
+	ID         int         `json:"id" gorm:"primaryKey"`
+	VideoID    int         `json:"video_id" gorm:"not null;index"`
+	FromStatus VideoStatus `json:"from_status" gorm:"type:varchar(50);not null"`
+	ToStatus   VideoStatus `json:"to_status" gorm:"type:varchar(50);not null"`
+	Reason     string      `json:"reason" gorm:"type:text;not null"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to VideoStatus
+		expected bool
+	}{
+		{VideoStatusPending, VideoStatusGeneratingScript, true},
+		{VideoStatusGeneratingScript, VideoStatusGeneratingAudio, true},
+		{VideoStatusGeneratingScript, VideoStatusCompleted, false},
+		{VideoStatusGeneratingImages, VideoStatusReadyToRender, true},
+		{VideoStatusRendering, VideoStatusCompleted, true},
+		{VideoStatusRendering, VideoStatusGeneratingImages, false},
+		{VideoStatusFailed, VideoStatusGeneratingAudio, true},
+		{VideoStatusFailed, VideoStatusRendering, false},
+		{VideoStatusCompleted, VideoStatusFailed, false},
+		{VideoStatusCancelled, VideoStatusPending, false},
+		{"processing", VideoStatusCompleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTransitionsTo(t *testing.T) {
+	expected := []VideoStatus{VideoStatusFailed, VideoStatusGeneratingImages}
+	if got := TransitionsTo(VideoStatusReadyToRender); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+	for _, status := range TransitionsTo(VideoStatusFailed) {
+		if status == VideoStatusCompleted || status == VideoStatusCancelled || status == VideoStatusFailed {
+			t.Errorf("Expected finished videos not to fail, got %v", status)
+		}
+	}
+}
+
+func TestReached(t *testing.T) {
+	tests := []struct {
+		status, target VideoStatus
+		expected       bool
+	}{
+		{VideoStatusRendering, VideoStatusRendering, true},
+		{VideoStatusCompleted, VideoStatusRendering, true},
+		{VideoStatusGeneratingScenes, VideoStatusGeneratingAudio, true},
+		{VideoStatusReadyToRender, VideoStatusRendering, false},
+		{VideoStatusFailed, VideoStatusRendering, false},
+		{VideoStatusCancelled, VideoStatusGeneratingScript, false},
+		{VideoStatusFailed, VideoStatusFailed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.status)+">="+string(tt.target), func(t *testing.T) {
+			if got := tt.status.Reached(tt.target); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
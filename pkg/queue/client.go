@@ -20,14 +20,7 @@ var (
 
 // NewClient creates a new queue client
 func NewClient() *Client {
-	redisAddr := fmt.Sprintf("%s:%s",
-		getEnvOrDefault("REDIS_HOST", "localhost"),
-		getEnvOrDefault("REDIS_PORT", "6379"),
-	)
-
-	client := asynq.NewClient(asynq.RedisClientOpt{
-		Addr: redisAddr,
-	})
+	client := asynq.NewClient(redisClientOpt())
 
 	queueClient := &Client{
 		client: client,
@@ -59,6 +52,16 @@ func (c *Client) Close() error {
 	return c.client.Close()
 }
 
+// redisClientOpt returns the Redis connection from REDIS_HOST and REDIS_PORT
+func redisClientOpt() asynq.RedisClientOpt {
+	return asynq.RedisClientOpt{
+		Addr: fmt.Sprintf("%s:%s",
+			getEnvOrDefault("REDIS_HOST", "localhost"),
+			getEnvOrDefault("REDIS_PORT", "6379"),
+		),
+	}
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
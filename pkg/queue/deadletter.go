@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// FailedState is the state of the failed tasks the dead-letter API works on
+type FailedState string
+
+const (
+	// FailedStateArchived tasks exhausted their retries and won't run again
+	FailedStateArchived FailedState = "archived"
+	// FailedStateRetry tasks failed and are waiting for their next attempt
+	FailedStateRetry FailedState = "retry"
+)
+
+// ParseFailedState validates a failed task state from a request
+func ParseFailedState(value string) (FailedState, error) {
+	switch state := FailedState(value); state {
+	case FailedStateArchived, FailedStateRetry:
+		return state, nil
+	}
+	return "", fmt.Errorf("unknown failed task state %q (expected %s or %s)", value, FailedStateArchived, FailedStateRetry)
+}
+
+// FailedTask is a failed task with its payload decoded
+type FailedTask struct {
+	ID    string      `json:"id"`
+	Queue string      `json:"queue"`
+	Type  string      `json:"type"`
+	State FailedState `json:"state"`
+	// Payload holds the task's fields without its metadata. A payload that
+	// can't be decoded is kept as a JSON string and DecodeError says why.
+	Payload     json.RawMessage `json:"payload"`
+	Meta        *TaskMeta       `json:"meta,omitempty"`
+	DecodeError string          `json:"decode_error,omitempty"`
+	// VideoID and SceneID tie the task to the video it works on
+	VideoID       *int       `json:"video_id,omitempty"`
+	SceneID       *int       `json:"scene_id,omitempty"`
+	LastErr       string     `json:"last_error"`
+	LastFailedAt  *time.Time `json:"last_failed_at,omitempty"`
+	Retried       int        `json:"retried"`
+	MaxRetry      int        `json:"max_retry"`
+	NextProcessAt *time.Time `json:"next_process_at,omitempty"`
+}
+
+// ErrTaskNotFailed is returned for a task that is queued or running rather
+// than archived or waiting to retry
+var ErrTaskNotFailed = errors.New("task has not failed")
+
+// QueueFailures counts the failed tasks of a queue
+type QueueFailures struct {
+	Queue    string `json:"queue"`
+	Archived int    `json:"archived"`
+	Retry    int    `json:"retry"`
+}
+
+// failedTask decodes the payload of a failed task
+func failedTask(task *asynq.TaskInfo, state FailedState) FailedTask {
+	failed := FailedTask{
+		ID:       task.ID,
+		Queue:    task.Queue,
+		Type:     task.Type,
+		State:    state,
+		LastErr:  task.LastErr,
+		Retried:  task.Retried,
+		MaxRetry: task.MaxRetry,
+	}
+	if !task.LastFailedAt.IsZero() {
+		failed.LastFailedAt = &task.LastFailedAt
+	}
+	if !task.NextProcessAt.IsZero() {
+		failed.NextProcessAt = &task.NextProcessAt
+	}
+
+	fields := map[string]json.RawMessage{}
+	meta, err := DecodePayload(task.Payload, &fields)
+	if err != nil {
+		failed.DecodeError = err.Error()
+		failed.Payload, _ = json.Marshal(string(task.Payload))
+		return failed
+	}
+	delete(fields, metaKey)
+	failed.Meta = &meta
+	failed.Payload, _ = json.Marshal(fields)
+
+	var ids videoTaskPayload
+	_ = json.Unmarshal(task.Payload, &ids)
+	failed.VideoID, failed.SceneID = ids.VideoID, ids.SceneID
+	return failed
+}
+
+// Queues returns the names of the queues asynq knows about
+func (i *Inspector) Queues() ([]string, error) {
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+	return queues, nil
+}
+
+// QueueFailures counts the archived and retrying tasks of every queue
+func (i *Inspector) QueueFailures() ([]QueueFailures, error) {
+	queues, err := i.Queues()
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]QueueFailures, 0, len(queues))
+	for _, qname := range queues {
+		info, err := i.inspector.GetQueueInfo(qname)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get queue %s: %w", qname, err)
+		}
+		failures = append(failures, QueueFailures{Queue: qname, Archived: info.Archived, Retry: info.Retry})
+	}
+	return failures, nil
+}
+
+// ListFailedTasks returns one page of the tasks of qname in state. Pages start at 1.
+func (i *Inspector) ListFailedTasks(qname string, state FailedState, page, pageSize int) ([]FailedTask, error) {
+	list := i.inspector.ListArchivedTasks
+	if state == FailedStateRetry {
+		list = i.inspector.ListRetryTasks
+	}
+	tasks, err := list(qname, asynq.Page(page), asynq.PageSize(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s tasks in queue %s: %w", state, qname, err)
+	}
+	failed := make([]FailedTask, 0, len(tasks))
+	for _, task := range tasks {
+		failed = append(failed, failedTask(task, state))
+	}
+	return failed, nil
+}
+
+// GetFailedTask returns the archived or retrying task id of qname. It returns
+// asynq.ErrQueueNotFound or asynq.ErrTaskNotFound for unknown tasks, and
+// ErrTaskNotFailed for tasks in any other state.
+func (i *Inspector) GetFailedTask(qname, id string) (FailedTask, error) {
+	task, err := i.inspector.GetTaskInfo(qname, id)
+	if err != nil {
+		return FailedTask{}, fmt.Errorf("failed to get task %s: %w", id, err)
+	}
+	switch task.State {
+	case asynq.TaskStateArchived:
+		return failedTask(task, FailedStateArchived), nil
+	case asynq.TaskStateRetry:
+		return failedTask(task, FailedStateRetry), nil
+	}
+	return FailedTask{}, fmt.Errorf("task %s is %s: %w", id, task.State, ErrTaskNotFailed)
+}
+
+// RequeueTask moves an archived or retrying task back to pending so it runs now.
+// It doesn't look at the task's video, which the caller should resume through
+// its retry if it failed. It returns asynq.ErrQueueNotFound or
+// asynq.ErrTaskNotFound for unknown tasks.
+func (i *Inspector) RequeueTask(qname, id string) error {
+	if err := i.inspector.RunTask(qname, id); err != nil {
+		return fmt.Errorf("failed to requeue task %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteTask deletes a task for good. Like RequeueTask, it returns
+// asynq.ErrQueueNotFound or asynq.ErrTaskNotFound for unknown tasks.
+func (i *Inspector) DeleteTask(qname, id string) error {
+	if err := i.inspector.DeleteTask(qname, id); err != nil {
+		return fmt.Errorf("failed to delete task %s: %w", id, err)
+	}
+	return nil
+}
+
+// DeleteTasks deletes the tasks with ids in qname and returns how many were
+// deleted. Tasks that are already gone are skipped.
+func (i *Inspector) DeleteTasks(qname string, ids []string) (int, error) {
+	return i.eachTask(qname, ids, i.DeleteTask)
+}
+
+func (i *Inspector) eachTask(qname string, ids []string, apply func(qname, id string) error) (int, error) {
+	done := 0
+	var errs []error
+	for _, id := range ids {
+		if err := apply(qname, id); err != nil {
+			if errors.Is(err, asynq.ErrTaskNotFound) {
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		done++
+	}
+	return done, errors.Join(errs...)
+}
+
+// DeleteAll deletes every task of qname in state and returns how many were deleted
+func (i *Inspector) DeleteAll(qname string, state FailedState) (int, error) {
+	del := i.inspector.DeleteAllArchivedTasks
+	if state == FailedStateRetry {
+		del = i.inspector.DeleteAllRetryTasks
+	}
+	n, err := del(qname)
+	if err != nil {
+		return n, fmt.Errorf("failed to delete %s tasks in queue %s: %w", state, qname, err)
+	}
+	return n, nil
+}
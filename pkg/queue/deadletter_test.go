@@ -0,0 +1,54 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestFailedTask(t *testing.T) {
+	failedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	image := failedTask(&asynq.TaskInfo{
+		ID:           "abc",
+		Queue:        QueueDefault,
+		Type:         TypeGenerateSceneImage,
+		Payload:      readContract(t, "render_video.json"),
+		LastErr:      "quota exceeded",
+		LastFailedAt: failedAt,
+		Retried:      5,
+		MaxRetry:     5,
+	}, FailedStateArchived)
+
+	if image.ID != "abc" || image.State != FailedStateArchived || image.LastErr != "quota exceeded" || image.Retried != 5 {
+		t.Errorf("Unexpected failed task: %+v", image)
+	}
+	if image.LastFailedAt == nil || !image.LastFailedAt.Equal(failedAt) || image.NextProcessAt != nil {
+		t.Errorf("Expected only the failure time to be set, got %v and %v", image.LastFailedAt, image.NextProcessAt)
+	}
+	if string(image.Payload) != `{"video_id":42}` {
+		t.Errorf("Expected the payload without its metadata, got %s", image.Payload)
+	}
+	if image.Meta == nil || image.Meta.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the decoded metadata, got %+v", image.Meta)
+	}
+	if image.VideoID == nil || *image.VideoID != 42 || image.SceneID != nil {
+		t.Errorf("Expected the task to link to video 42, got %v and %v", image.VideoID, image.SceneID)
+	}
+
+	broken := failedTask(&asynq.TaskInfo{Type: TypeGenerateAudio, Payload: []byte(`not json`)}, FailedStateRetry)
+	if broken.DecodeError == "" || string(broken.Payload) != `"not json"` || broken.Meta != nil {
+		t.Errorf("Expected an undecodable payload to be kept as a string, got %+v", broken)
+	}
+}
+
+func TestParseFailedState(t *testing.T) {
+	for _, value := range []string{"archived", "retry"} {
+		if state, err := ParseFailedState(value); err != nil || string(state) != value {
+			t.Errorf("ParseFailedState(%q): got %q (%v)", value, state, err)
+		}
+	}
+	if _, err := ParseFailedState("pending"); err == nil {
+		t.Error("Expected pending tasks to be rejected")
+	}
+}
@@ -0,0 +1,140 @@
+package queue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PayloadVersion is the schema version of the payloads this package writes.
+// Version 1 is the bare payload written before task metadata existed. Bump it
+// when a payload field changes meaning, and teach DecodePayload to read the
+// previous version, since tasks written by the old code may still be queued.
+const PayloadVersion = 2
+
+// metaKey is the payload field that holds TaskMeta. It sits next to the payload
+// fields instead of wrapping them, so consumers that predate it, like the
+// renderer, keep reading the fields they know.
+const metaKey = "_meta"
+
+// TaskMeta describes where a task came from
+type TaskMeta struct {
+	Version    int       `json:"v"`
+	TraceID    string    `json:"trace_id,omitempty"`
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	UserID     *int      `json:"user_id,omitempty"`
+}
+
+type contextKey int
+
+const (
+	traceIDKey contextKey = iota
+	userIDKey
+)
+
+// WithTraceID returns ctx carrying traceID for the tasks enqueued with it
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey, traceID)
+}
+
+// TraceIDFromContext returns the trace ID carried by ctx, if any
+func TraceIDFromContext(ctx context.Context) string {
+	traceID, _ := ctx.Value(traceIDKey).(string)
+	return traceID
+}
+
+// WithUserID returns ctx carrying the ID of the user who requested the work
+func WithUserID(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, userIDKey, userID)
+}
+
+// ContextWithMeta returns ctx carrying the trace ID and user of meta, so the
+// tasks a handler enqueues stay in the trace of the task it is handling
+func ContextWithMeta(ctx context.Context, meta TaskMeta) context.Context {
+	if meta.TraceID != "" {
+		ctx = WithTraceID(ctx, meta.TraceID)
+	}
+	if meta.UserID != nil {
+		ctx = WithUserID(ctx, *meta.UserID)
+	}
+	return ctx
+}
+
+// newTaskMeta builds the metadata for a task enqueued with ctx, starting a new
+// trace when ctx doesn't carry one
+func newTaskMeta(ctx context.Context) TaskMeta {
+	meta := TaskMeta{
+		Version:    PayloadVersion,
+		TraceID:    TraceIDFromContext(ctx),
+		EnqueuedAt: time.Now().UTC(),
+	}
+	if meta.TraceID == "" {
+		meta.TraceID = newTraceID()
+	}
+	if userID, ok := ctx.Value(userIDKey).(int); ok {
+		meta.UserID = &userID
+	}
+	return meta
+}
+
+// newTraceID returns a random 128-bit trace ID in hex
+func newTraceID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// EncodePayload marshals payload with the metadata for a task enqueued with ctx
+func EncodePayload(ctx context.Context, payload interface{}) ([]byte, error) {
+	return encodePayload(payload, newTaskMeta(ctx))
+}
+
+func encodePayload(payload interface{}, meta TaskMeta) ([]byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, fmt.Errorf("payload must be a JSON object: %w", err)
+	}
+
+	fields[metaKey], err = json.Marshal(meta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal task metadata: %w", err)
+	}
+	return json.Marshal(fields)
+}
+
+// DecodePayload unmarshals a task payload of any supported version into v and
+// returns its metadata. Payloads without metadata, written before versioning
+// or by producers that don't set it, decode as version 1.
+func DecodePayload(data []byte, v interface{}) (TaskMeta, error) {
+	meta := TaskMeta{Version: 1}
+	if len(data) == 0 {
+		// Tasks like the scheduled voice sync used to have no payload at all
+		return meta, nil
+	}
+
+	var envelope struct {
+		Meta *TaskMeta `json:"_meta"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return meta, fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	if envelope.Meta != nil {
+		meta = *envelope.Meta
+	}
+	if meta.Version < 1 || meta.Version > PayloadVersion {
+		// A newer producer is ahead of this worker; a retry may land on an upgraded one
+		return meta, fmt.Errorf("unsupported payload version %d (this worker reads up to %d)", meta.Version, PayloadVersion)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return meta, fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+	return meta, nil
+}
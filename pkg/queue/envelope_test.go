@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// contractMeta is the metadata the contract fixtures were written with
+func contractMeta() TaskMeta {
+	userID := 7
+	return TaskMeta{
+		Version:    PayloadVersion,
+		TraceID:    "4bf92f3577b34da6a3ce929d0e0e4736",
+		EnqueuedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		UserID:     &userID,
+	}
+}
+
+func readContract(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "contracts", name))
+	if err != nil {
+		t.Fatalf("Failed to read contract %s: %v", name, err)
+	}
+	return bytes.TrimSpace(data)
+}
+
+// TestRenderVideoContract pins the render payload is-render reads from Redis.
+// It reads video_id from the top level and ignores other fields; if this test
+// has to change, is-render/src/queue/client.ts must change with it.
+func TestRenderVideoContract(t *testing.T) {
+	got, err := encodePayload(RenderVideoPayload{VideoID: 42}, contractMeta())
+	if err != nil {
+		t.Fatalf("encodePayload returned error: %v", err)
+	}
+	want := readContract(t, "render_video.json")
+	if !bytes.Equal(got, want) {
+		t.Errorf("Render payload changed:\n got: %s\nwant: %s", got, want)
+	}
+
+	var renderer struct {
+		VideoID *float64 `json:"video_id"`
+	}
+	if err := json.Unmarshal(got, &renderer); err != nil || renderer.VideoID == nil || *renderer.VideoID != 42 {
+		t.Errorf("Expected the renderer to read a numeric top-level video_id, got %v (%v)", renderer.VideoID, err)
+	}
+}
+
+// TestRenderVideoMediaContract pins the signed media URLs is-render renders
+// from. It looks scene images up by scene_id and falls back to the URL columns
+// of older videos when a field is missing.
+func TestRenderVideoMediaContract(t *testing.T) {
+	payload := RenderVideoPayload{
+		VideoID:  42,
+		AudioURL: "https://storage.example.com/audio/42.mp3?sig=a",
+		SceneImages: []RenderSceneImage{
+			{SceneID: 7, ImageURL: "https://storage.example.com/images/42/0.png?sig=b"},
+			{SceneID: 8, ImageURL: "https://storage.example.com/images/42/1.png?sig=c"},
+		},
+	}
+	got, err := encodePayload(payload, contractMeta())
+	if err != nil {
+		t.Fatalf("encodePayload returned error: %v", err)
+	}
+	want := readContract(t, "render_video_media.json")
+	if !bytes.Equal(got, want) {
+		t.Errorf("Render payload changed:\n got: %s\nwant: %s", got, want)
+	}
+
+	var renderer struct {
+		AudioURL    string `json:"audio_url"`
+		SceneImages []struct {
+			SceneID  *float64 `json:"scene_id"`
+			ImageURL string   `json:"image_url"`
+		} `json:"scene_images"`
+	}
+	if err := json.Unmarshal(got, &renderer); err != nil {
+		t.Fatalf("Failed to read the payload as the renderer does: %v", err)
+	}
+	if renderer.AudioURL != payload.AudioURL || len(renderer.SceneImages) != 2 || renderer.SceneImages[1].SceneID == nil || *renderer.SceneImages[1].SceneID != 8 || renderer.SceneImages[1].ImageURL != payload.SceneImages[1].ImageURL {
+		t.Errorf("Expected the renderer to read the media URLs, got %+v", renderer)
+	}
+}
+
+// TestVideoCompleteFromRendererContract pins the video_complete payloads
+// is-render enqueues: with the render's metadata, and bare from older builds
+func TestVideoCompleteFromRendererContract(t *testing.T) {
+	tests := []struct {
+		fixture string
+		version int
+		traceID string
+	}{
+		{fixture: "video_complete_from_renderer.json", version: 2, traceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{fixture: "video_complete_from_renderer_v1.json", version: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fixture, func(t *testing.T) {
+			var payload VideoCompletePayload
+			meta, err := DecodePayload(readContract(t, tt.fixture), &payload)
+			if err != nil {
+				t.Fatalf("DecodePayload returned error: %v", err)
+			}
+			if meta.Version != tt.version || meta.TraceID != tt.traceID {
+				t.Errorf("Unexpected metadata: %+v", meta)
+			}
+			if payload.VideoID != 42 || payload.VideoURL != "https://storage.example.com/videos/42.mp4" {
+				t.Errorf("Unexpected payload: %+v", payload)
+			}
+		})
+	}
+}
+
+func TestDecodePayloadVersions(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    []byte
+		version int
+		traceID string
+	}{
+		{name: "current", data: readContract(t, "render_video.json"), version: PayloadVersion, traceID: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "written before versioning", data: readContract(t, "render_video_v1.json"), version: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload RenderVideoPayload
+			meta, err := DecodePayload(tt.data, &payload)
+			if err != nil {
+				t.Fatalf("DecodePayload returned error: %v", err)
+			}
+			if payload.VideoID != 42 || meta.Version != tt.version || meta.TraceID != tt.traceID {
+				t.Errorf("Unexpected decode: payload=%+v meta=%+v", payload, meta)
+			}
+		})
+	}
+
+	var payload SyncVoicesPayload
+	if meta, err := DecodePayload(nil, &payload); err != nil || meta.Version != 1 {
+		t.Errorf("Expected an empty payload to decode as version 1, got %+v (%v)", meta, err)
+	}
+
+	future := []byte(`{"_meta":{"v":99},"video_id":42}`)
+	if _, err := DecodePayload(future, &RenderVideoPayload{}); err == nil || !strings.Contains(err.Error(), "unsupported payload version 99") {
+		t.Errorf("Expected a newer payload version to be rejected, got %v", err)
+	}
+}
+
+func TestEncodePayloadCarriesContext(t *testing.T) {
+	ctx := WithUserID(WithTraceID(context.Background(), "trace-1"), 7)
+	data, err := EncodePayload(ctx, GenerateAudioPayload{VideoID: 3})
+	if err != nil {
+		t.Fatalf("EncodePayload returned error: %v", err)
+	}
+
+	var payload GenerateAudioPayload
+	meta, err := DecodePayload(data, &payload)
+	if err != nil {
+		t.Fatalf("DecodePayload returned error: %v", err)
+	}
+	if meta.TraceID != "trace-1" || meta.UserID == nil || *meta.UserID != 7 || meta.EnqueuedAt.IsZero() {
+		t.Errorf("Expected the context's trace and user, got %+v", meta)
+	}
+
+	// A handler continues the trace of the task it handles
+	next := ContextWithMeta(context.Background(), meta)
+	if TraceIDFromContext(next) != "trace-1" {
+		t.Errorf("Expected the trace to carry over, got %q", TraceIDFromContext(next))
+	}
+
+	// Without a trace in the context, each payload starts one
+	data, _ = EncodePayload(context.Background(), GenerateAudioPayload{VideoID: 3})
+	meta, _ = DecodePayload(data, &payload)
+	if len(meta.TraceID) != 32 || meta.UserID != nil {
+		t.Errorf("Expected a new trace ID and no user, got %+v", meta)
+	}
+}
@@ -9,7 +9,7 @@ package queue
 // 2. Use it in your handlers to enqueue tasks:
 //
 //    // Example: Enqueue a video processing task
-//    err := queueClient.EnqueueProcessVideo(queue.ProcessVideoPayload{
+//    _, err := queue.Enqueue(queueClient, queue.ProcessVideoPayload{
 //        VideoID: "video-123",
 //        UserID:  "user-456",
 //    })
@@ -18,7 +18,7 @@ package queue
 //    }
 //
 //    // Example: Enqueue an email task
-//    err = queueClient.EnqueueSendEmail(queue.SendEmailPayload{
+//    _, err = queue.Enqueue(queueClient, queue.SendEmailPayload{
 //        To:      "user@example.com",
 //        Subject: "Welcome!",
 //        Body:    "Thanks for signing up!",
@@ -0,0 +1,223 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/hibiken/asynq"
+)
+
+// inspectPageSize is how many tasks are listed per request when scanning a queue
+const inspectPageSize = 100
+
+// Inspector finds and removes the queued tasks of a video
+type Inspector struct {
+	inspector *asynq.Inspector
+}
+
+// NewInspector creates an inspector for the Redis instance the client enqueues to
+func NewInspector() *Inspector {
+	return &Inspector{inspector: asynq.NewInspector(redisClientOpt())}
+}
+
+// Close closes the inspector connection
+func (i *Inspector) Close() error {
+	return i.inspector.Close()
+}
+
+// videoTaskPayload holds the fields that tie a task to a video
+type videoTaskPayload struct {
+	VideoID *int `json:"video_id"`
+	SceneID *int `json:"scene_id"`
+}
+
+// belongsToVideo reports whether a task works on videoID or one of its scenes
+func belongsToVideo(task *asynq.TaskInfo, videoID int, sceneIDs map[int]bool) bool {
+	return payloadBelongsToVideo(task.Payload, videoID, sceneIDs)
+}
+
+// payloadBelongsToVideo reports whether a task payload names videoID or one of its scenes
+func payloadBelongsToVideo(data []byte, videoID int, sceneIDs map[int]bool) bool {
+	var payload videoTaskPayload
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return false
+	}
+	if payload.VideoID != nil {
+		return *payload.VideoID == videoID
+	}
+	return payload.SceneID != nil && sceneIDs[*payload.SceneID]
+}
+
+// CancelVideoTasks deletes the pending, scheduled and retrying tasks of a video
+// in every queue and asks workers to stop its running ones. Scene image tasks
+// only carry a scene ID, so the video's scenes are passed in sceneIDs. It
+// returns how many tasks were deleted or signalled.
+func (i *Inspector) CancelVideoTasks(videoID int, sceneIDs []int) (int, error) {
+	scenes := make(map[int]bool, len(sceneIDs))
+	for _, id := range sceneIDs {
+		scenes[id] = true
+	}
+
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	cancelled := 0
+	var errs []error
+	for _, qname := range queues {
+		for _, list := range []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+			i.inspector.ListPendingTasks,
+			i.inspector.ListScheduledTasks,
+			i.inspector.ListRetryTasks,
+		} {
+			// Collect first: deleting while paging would shift the pages
+			tasks, err := listMatching(list, qname, videoID, scenes)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			for _, task := range tasks {
+				if err := i.inspector.DeleteTask(qname, task.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+					errs = append(errs, fmt.Errorf("failed to delete task %s: %w", task.ID, err))
+					continue
+				}
+				log.Printf("Deleted %s task %s for video_id=%d", task.Type, task.ID, videoID)
+				cancelled++
+			}
+		}
+
+		// Running tasks can't be deleted; cancelling their context stops them
+		active, err := listMatching(i.inspector.ListActiveTasks, qname, videoID, scenes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, task := range active {
+			if err := i.inspector.CancelProcessing(task.ID); err != nil {
+				errs = append(errs, fmt.Errorf("failed to cancel task %s: %w", task.ID, err))
+				continue
+			}
+			log.Printf("Cancelled running %s task %s for video_id=%d", task.Type, task.ID, videoID)
+			cancelled++
+		}
+	}
+
+	return cancelled, errors.Join(errs...)
+}
+
+// DeleteArchivedVideoTasks deletes the archived tasks of a video in every queue.
+// An archived task keeps its task ID, so it has to go before a retry can
+// enqueue the same scene or render again. It returns how many tasks were deleted.
+func (i *Inspector) DeleteArchivedVideoTasks(videoID int, sceneIDs []int) (int, error) {
+	scenes := make(map[int]bool, len(sceneIDs))
+	for _, id := range sceneIDs {
+		scenes[id] = true
+	}
+
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	deleted := 0
+	var errs []error
+	for _, qname := range queues {
+		archived, err := listMatching(i.inspector.ListArchivedTasks, qname, videoID, scenes)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, task := range archived {
+			if err := i.inspector.DeleteTask(qname, task.ID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+				errs = append(errs, fmt.Errorf("failed to delete task %s: %w", task.ID, err))
+				continue
+			}
+			log.Printf("Deleted archived %s task %s for video_id=%d", task.Type, task.ID, videoID)
+			deleted++
+		}
+	}
+
+	return deleted, errors.Join(errs...)
+}
+
+// listMatching pages through a task list of qname and keeps the tasks of the video
+func listMatching(list func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error), qname string, videoID int, sceneIDs map[int]bool) ([]*asynq.TaskInfo, error) {
+	var matching []*asynq.TaskInfo
+	for page := 1; ; page++ {
+		tasks, err := list(qname, asynq.PageSize(inspectPageSize), asynq.Page(page))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tasks in queue %s: %w", qname, err)
+		}
+		for _, task := range tasks {
+			if belongsToVideo(task, videoID, sceneIDs) {
+				matching = append(matching, task)
+			}
+		}
+		if len(tasks) < inspectPageSize {
+			return matching, nil
+		}
+	}
+}
+
+// ArchivedTask is a task that exhausted its retries
+type ArchivedTask struct {
+	Type    string
+	SceneID *int
+	LastErr string
+}
+
+// VideoTasks summarizes the tasks a video has in asynq
+type VideoTasks struct {
+	// Live counts pending, scheduled, retrying and running tasks
+	Live     int
+	Archived []ArchivedTask
+}
+
+// VideoTasks lists the tasks of a video in every queue. Like CancelVideoTasks,
+// it needs the video's scene IDs to find its scene image tasks.
+func (i *Inspector) VideoTasks(videoID int, sceneIDs []int) (*VideoTasks, error) {
+	scenes := make(map[int]bool, len(sceneIDs))
+	for _, id := range sceneIDs {
+		scenes[id] = true
+	}
+
+	queues, err := i.inspector.Queues()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list queues: %w", err)
+	}
+
+	result := &VideoTasks{}
+	for _, qname := range queues {
+		for _, list := range []func(string, ...asynq.ListOption) ([]*asynq.TaskInfo, error){
+			i.inspector.ListPendingTasks,
+			i.inspector.ListScheduledTasks,
+			i.inspector.ListRetryTasks,
+			i.inspector.ListActiveTasks,
+		} {
+			tasks, err := listMatching(list, qname, videoID, scenes)
+			if err != nil {
+				return nil, err
+			}
+			result.Live += len(tasks)
+		}
+
+		archived, err := listMatching(i.inspector.ListArchivedTasks, qname, videoID, scenes)
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range archived {
+			result.Archived = append(result.Archived, archivedTask(task))
+		}
+	}
+	return result, nil
+}
+
+// archivedTask keeps the fields of an archived task that explain a stuck video
+func archivedTask(task *asynq.TaskInfo) ArchivedTask {
+	var payload videoTaskPayload
+	_ = json.Unmarshal(task.Payload, &payload)
+	return ArchivedTask{Type: task.Type, SceneID: payload.SceneID, LastErr: task.LastErr}
+}
@@ -0,0 +1,45 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/hibiken/asynq"
+)
+
+func TestBelongsToVideo(t *testing.T) {
+	scenes := map[int]bool{7: true, 8: true}
+	tests := []struct {
+		name     string
+		payload  string
+		expected bool
+	}{
+		{name: "task of the video", payload: `{"video_id":42}`, expected: true},
+		{name: "task of another video", payload: `{"video_id":43}`, expected: false},
+		{name: "image of one of its scenes", payload: `{"scene_id":7}`, expected: true},
+		{name: "image of another scene", payload: `{"scene_id":9}`, expected: false},
+		{name: "task without a video", payload: `{"to":"a@example.com"}`, expected: false},
+		{name: "legacy string video ID", payload: `{"video_id":"42"}`, expected: false},
+		{name: "no payload", payload: ``, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := &asynq.TaskInfo{Payload: []byte(tt.payload)}
+			if got := belongsToVideo(task, 42, scenes); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestArchivedTask(t *testing.T) {
+	image := archivedTask(&asynq.TaskInfo{Type: TypeGenerateSceneImage, Payload: []byte(`{"scene_id":7}`), LastErr: "quota exceeded"})
+	if image.Type != TypeGenerateSceneImage || image.SceneID == nil || *image.SceneID != 7 || image.LastErr != "quota exceeded" {
+		t.Errorf("Unexpected archived image task: %+v", image)
+	}
+
+	render := archivedTask(&asynq.TaskInfo{Type: TypeRenderVideo, Payload: []byte(`{"video_id":42}`)})
+	if render.SceneID != nil {
+		t.Errorf("Expected no scene ID on a render task, got %d", *render.SceneID)
+	}
+}
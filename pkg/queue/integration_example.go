@@ -53,7 +53,7 @@ package queue
 //     // ... create video in database ...
 //     
 //     // Enqueue background processing
-//     err := h.queueClient.EnqueueProcessVideo(queue.ProcessVideoPayload{
+//     _, err := queue.Enqueue(h.queueClient, queue.ProcessVideoPayload{
 //         VideoID: video.ID,
 //         UserID:  userID,
 //     })
@@ -0,0 +1,218 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Outbox relay defaults
+const (
+	DefaultOutboxPollInterval = time.Second
+	DefaultOutboxRetention    = 24 * time.Hour
+	outboxBatchSize           = 100
+	outboxMaxBackoff          = 5 * time.Minute
+)
+
+// OutboxTask is a task written to the outbox table in the same transaction as
+// the state change that calls for it. OutboxRelay enqueues it afterwards, so a
+// committed change always gets its task even if Redis was down at the time.
+type OutboxTask struct {
+	ID           int64
+	TaskType     string
+	Payload      []byte
+	Attempts     int
+	LastError    *string
+	AvailableAt  time.Time
+	DispatchedAt *time.Time
+	CreatedAt    time.Time
+}
+
+// TableName overrides the default table name for GORM
+func (OutboxTask) TableName() string {
+	return "outbox"
+}
+
+// BelongsToVideo reports whether the task works on videoID or one of the
+// scenes in sceneIDs
+func (t OutboxTask) BelongsToVideo(videoID int, sceneIDs []int) bool {
+	scenes := make(map[int]bool, len(sceneIDs))
+	for _, id := range sceneIDs {
+		scenes[id] = true
+	}
+	return payloadBelongsToVideo(t.Payload, videoID, scenes)
+}
+
+// NewOutboxTask encodes payload with the metadata of ctx into a task of
+// taskType ready for the outbox. Its enqueued_at is when the row is written.
+func NewOutboxTask(ctx context.Context, taskType string, payload interface{}) (*OutboxTask, error) {
+	jsonPayload, err := EncodePayload(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &OutboxTask{
+		TaskType:    taskType,
+		Payload:     jsonPayload,
+		AvailableAt: now,
+		CreatedAt:   now,
+	}, nil
+}
+
+// WriteOutbox adds a task to the outbox using tx, which should be the
+// transaction that makes the state change the task follows from. The task
+// metadata comes from the context tx was started with.
+func WriteOutbox(tx *gorm.DB, taskType string, payload interface{}) error {
+	ctx := context.Background()
+	if tx.Statement != nil && tx.Statement.Context != nil {
+		ctx = tx.Statement.Context
+	}
+	task, err := NewOutboxTask(ctx, taskType, payload)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(task).Error; err != nil {
+		return fmt.Errorf("failed to write %s task to outbox: %w", taskType, err)
+	}
+	return nil
+}
+
+// OutboxPollIntervalFromEnv reads how often the relay polls the outbox from
+// OUTBOX_POLL_INTERVAL, defaulting to DefaultOutboxPollInterval
+func OutboxPollIntervalFromEnv() (time.Duration, error) {
+	value := os.Getenv("OUTBOX_POLL_INTERVAL")
+	if value == "" {
+		return DefaultOutboxPollInterval, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil || interval <= 0 {
+		return 0, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL %q", value)
+	}
+	return interval, nil
+}
+
+// TaskEnqueuer enqueues a task from its type and JSON payload
+type TaskEnqueuer interface {
+	Enqueue(taskType string, payload []byte) error
+}
+
+// OutboxRelay moves tasks from the outbox to asynq. A task is marked dispatched
+// only after asynq accepted it, so delivery is at-least-once: if the process dies
+// between the two, the task is enqueued again and handlers must tolerate that.
+// Several relays can run at once; on Postgres each row is locked by one of them.
+type OutboxRelay struct {
+	db        *gorm.DB
+	enqueuer  TaskEnqueuer
+	interval  time.Duration
+	retention time.Duration
+}
+
+// NewOutboxRelay creates a relay that polls db every interval and deletes
+// dispatched tasks once they are older than retention
+func NewOutboxRelay(db *gorm.DB, enqueuer TaskEnqueuer, interval time.Duration, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		db:        db,
+		enqueuer:  enqueuer,
+		interval:  interval,
+		retention: retention,
+	}
+}
+
+// Run relays tasks until ctx is cancelled
+func (r *OutboxRelay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("ERROR: Failed to relay outbox tasks: %v", err)
+		}
+		if time.Since(lastPrune) > time.Hour {
+			if err := r.Prune(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("ERROR: Failed to prune outbox: %v", err)
+			}
+			lastPrune = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce enqueues one batch of due tasks and returns how many were dispatched.
+// Tasks that fail to enqueue are retried later with exponential backoff.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	dispatched := 0
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		query := tx.Where("dispatched_at IS NULL AND available_at <= ?", now).
+			Order("id ASC").
+			Limit(outboxBatchSize)
+		if tx.Dialector.Name() == "postgres" {
+			// Let concurrent relays work on different rows instead of waiting
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+
+		var tasks []OutboxTask
+		if err := query.Find(&tasks).Error; err != nil {
+			return fmt.Errorf("failed to fetch outbox tasks: %w", err)
+		}
+
+		for _, task := range tasks {
+			err := r.enqueuer.Enqueue(task.TaskType, task.Payload)
+			// A unique task that is already queued was delivered by an earlier attempt
+			if err != nil && !errors.Is(err, asynq.ErrDuplicateTask) && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				log.Printf("ERROR: Failed to enqueue outbox task %d (%s): %v", task.ID, task.TaskType, err)
+				if err := tx.Model(&OutboxTask{}).
+					Where("id = ?", task.ID).
+					Updates(map[string]interface{}{
+						"attempts":     task.Attempts + 1,
+						"last_error":   err.Error(),
+						"available_at": now.Add(outboxBackoff(task.Attempts + 1)),
+					}).Error; err != nil {
+					return fmt.Errorf("failed to record outbox task failure: %w", err)
+				}
+				continue
+			}
+
+			if err := tx.Model(&OutboxTask{}).
+				Where("id = ?", task.ID).
+				Update("dispatched_at", now).Error; err != nil {
+				return fmt.Errorf("failed to mark outbox task dispatched: %w", err)
+			}
+			dispatched++
+		}
+		return nil
+	})
+	return dispatched, err
+}
+
+// Prune deletes tasks that were dispatched longer than the retention period ago
+func (r *OutboxRelay) Prune(ctx context.Context) error {
+	return r.db.WithContext(ctx).
+		Where("dispatched_at < ?", time.Now().UTC().Add(-r.retention)).
+		Delete(&OutboxTask{}).Error
+}
+
+// outboxBackoff doubles the wait after every failed attempt, up to outboxMaxBackoff
+func outboxBackoff(attempts int) time.Duration {
+	if attempts > 9 {
+		return outboxMaxBackoff
+	}
+	backoff := time.Second << attempts
+	if backoff > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return backoff
+}
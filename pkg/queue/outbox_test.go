@@ -0,0 +1,154 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/hibiken/asynq"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestOutboxDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&OutboxTask{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return db
+}
+
+// fakeEnqueuer records enqueued tasks and fails with the errors queued in errs
+type fakeEnqueuer struct {
+	enqueued []string
+	payloads [][]byte
+	errs     []error
+}
+
+func (f *fakeEnqueuer) Enqueue(taskType string, payload []byte) error {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	f.enqueued = append(f.enqueued, taskType)
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+func TestWriteOutboxRollsBackWithTransaction(t *testing.T) {
+	db := newTestOutboxDB(t)
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := WriteOutbox(tx, TypeGenerateVideoScript, GenerateVideoScriptPayload{VideoID: 1}); err != nil {
+			return err
+		}
+		return errors.New("state change failed")
+	})
+	if err == nil {
+		t.Fatal("Expected the transaction to fail")
+	}
+
+	var count int64
+	db.Model(&OutboxTask{}).Count(&count)
+	if count != 0 {
+		t.Errorf("Expected no outbox task after rollback, got %d", count)
+	}
+}
+
+func TestOutboxRelay(t *testing.T) {
+	db := newTestOutboxDB(t)
+	ctx := context.Background()
+	for _, id := range []int{1, 2, 3} {
+		if err := WriteOutbox(db, TypeGenerateAudio, GenerateAudioPayload{VideoID: id}); err != nil {
+			t.Fatalf("WriteOutbox returned error: %v", err)
+		}
+	}
+
+	// Redis is down for the second task; the third is a render already queued
+	enqueuer := &fakeEnqueuer{errs: []error{nil, errors.New("connection refused"), asynq.ErrDuplicateTask}}
+	relay := NewOutboxRelay(db, enqueuer, time.Second, time.Hour)
+
+	dispatched, err := relay.RelayOnce(ctx)
+	if err != nil {
+		t.Fatalf("RelayOnce returned error: %v", err)
+	}
+	if dispatched != 2 {
+		t.Errorf("Expected 2 dispatched tasks, got %d", dispatched)
+	}
+	if len(enqueuer.enqueued) != 1 || enqueuer.enqueued[0] != TypeGenerateAudio {
+		t.Fatalf("Unexpected enqueued tasks: %v", enqueuer.enqueued)
+	}
+	var payload GenerateAudioPayload
+	if _, err := DecodePayload(enqueuer.payloads[0], &payload); err != nil || payload.VideoID != 1 {
+		t.Errorf("Expected the payload of video 1, got %+v (%v)", payload, err)
+	}
+
+	var failed OutboxTask
+	if err := db.Where("dispatched_at IS NULL").First(&failed).Error; err != nil {
+		t.Fatalf("Expected the failed task to stay in the outbox: %v", err)
+	}
+	if failed.Attempts != 1 || failed.LastError == nil || !failed.AvailableAt.After(time.Now()) {
+		t.Errorf("Expected the failed task to back off, got %+v", failed)
+	}
+
+	// The failed task isn't due yet, so nothing is relayed until it is
+	if dispatched, _ := relay.RelayOnce(ctx); dispatched != 0 {
+		t.Errorf("Expected the failed task to wait for its backoff, got %d dispatched", dispatched)
+	}
+	db.Model(&OutboxTask{}).Where("id = ?", failed.ID).Update("available_at", time.Now().UTC().Add(-time.Second))
+	if dispatched, _ := relay.RelayOnce(ctx); dispatched != 1 {
+		t.Errorf("Expected the failed task to be retried, got %d dispatched", dispatched)
+	}
+}
+
+func TestOutboxRelayPrune(t *testing.T) {
+	db := newTestOutboxDB(t)
+	old := time.Now().UTC().Add(-2 * time.Hour)
+	recent := time.Now().UTC()
+	tasks := []OutboxTask{
+		{TaskType: TypeGenerateAudio, Payload: []byte(`{}`), DispatchedAt: &old},
+		{TaskType: TypeGenerateAudio, Payload: []byte(`{}`), DispatchedAt: &recent},
+		{TaskType: TypeGenerateAudio, Payload: []byte(`{}`)},
+	}
+	if err := db.Create(&tasks).Error; err != nil {
+		t.Fatalf("Failed to create tasks: %v", err)
+	}
+
+	relay := NewOutboxRelay(db, &fakeEnqueuer{}, time.Second, time.Hour)
+	if err := relay.Prune(context.Background()); err != nil {
+		t.Fatalf("Prune returned error: %v", err)
+	}
+
+	var count int64
+	db.Model(&OutboxTask{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Expected only the old dispatched task to be pruned, got %d left", count)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 2 * time.Second},
+		{4, 16 * time.Second},
+		{9, outboxMaxBackoff},
+		{40, outboxMaxBackoff},
+	}
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts); got != tt.expected {
+			t.Errorf("outboxBackoff(%d): expected %s, got %s", tt.attempts, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package queue
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// Queues the worker consumes, from highest to lowest priority
+const (
+	QueueCritical = "critical"
+	QueueDefault  = "default"
+	QueueLow      = "low"
+)
+
+// TaskPolicy describes how tasks of one type are enqueued. Zero fields keep
+// asynq's defaults: the default queue, 25 retries, a 30 minute timeout, no
+// uniqueness and immediate processing.
+type TaskPolicy struct {
+	Queue    string
+	MaxRetry int
+	Timeout  time.Duration
+	// UniqueTTL rejects a task while an identical one is queued or running.
+	// Identical includes the metadata, so it only catches the same encoded task
+	// enqueued again, like a scheduler's periodic one; TaskIDFromPayload is what
+	// keeps out a second task for the same video.
+	UniqueTTL time.Duration
+	// TaskIDFromPayload names the task after the video or scene in its payload,
+	// so a second task for the same one is rejected while the first is kept
+	TaskIDFromPayload bool
+	// ProcessIn delays every task of the type
+	ProcessIn time.Duration
+}
+
+// options returns the asynq options for the policy; payload is used for the task ID
+func (p TaskPolicy) options(taskType string, payload []byte) []asynq.Option {
+	var opts []asynq.Option
+	if p.Queue != "" {
+		opts = append(opts, asynq.Queue(p.Queue))
+	}
+	if p.MaxRetry > 0 {
+		opts = append(opts, asynq.MaxRetry(p.MaxRetry))
+	}
+	if p.Timeout > 0 {
+		opts = append(opts, asynq.Timeout(p.Timeout))
+	}
+	if p.UniqueTTL > 0 {
+		opts = append(opts, asynq.Unique(p.UniqueTTL))
+	}
+	if p.TaskIDFromPayload {
+		if id := payloadTaskID(taskType, payload); id != "" {
+			opts = append(opts, asynq.TaskID(id))
+		}
+	}
+	if p.ProcessIn > 0 {
+		opts = append(opts, asynq.ProcessIn(p.ProcessIn))
+	}
+	return opts
+}
+
+// payloadTaskID derives a task ID from the video or scene ID in payload
+func payloadTaskID(taskType string, payload []byte) string {
+	var ids videoTaskPayload
+	if err := json.Unmarshal(payload, &ids); err != nil {
+		return ""
+	}
+	switch {
+	case ids.VideoID != nil:
+		return fmt.Sprintf("%s:video:%d", taskType, *ids.VideoID)
+	case ids.SceneID != nil:
+		return fmt.Sprintf("%s:scene:%d", taskType, *ids.SceneID)
+	}
+	return ""
+}
+
+var (
+	policiesMu sync.RWMutex
+	policies   = map[string]TaskPolicy{
+		// Every pipeline step is named after its video, so a task redelivered by
+		// the outbox relay, or queued again by a retry or the reaper, doesn't pay
+		// the provider twice while the first is still queued or retrying
+		TypeGenerateVideoScript: {Queue: QueueDefault, MaxRetry: 3, Timeout: 5 * time.Minute, TaskIDFromPayload: true},
+		// Narration and captions stream whole audio files
+		TypeGenerateAudio:    {Queue: QueueDefault, MaxRetry: 3, Timeout: 10 * time.Minute, TaskIDFromPayload: true},
+		TypeGenerateCaptions: {Queue: QueueDefault, MaxRetry: 3, Timeout: 10 * time.Minute, TaskIDFromPayload: true},
+		TypeGenerateScenes:   {Queue: QueueDefault, MaxRetry: 3, Timeout: 5 * time.Minute, TaskIDFromPayload: true},
+		// Image providers fail over to each other, so a retry is cheap to try.
+		// Each is named after its scene rather than the video.
+		TypeGenerateSceneImage: {Queue: QueueDefault, MaxRetry: 5, Timeout: 3 * time.Minute, TaskIDFromPayload: true},
+		// is-render consumes renders from the default queue directly; the timeout
+		// covers the HTTP renderer's 15 minutes. Every render payload differs in
+		// its metadata and signed URLs, so a video can't have two renders queued
+		// only because the task is named after it.
+		TypeRenderVideo:   {Queue: QueueDefault, MaxRetry: 2, Timeout: 16 * time.Minute, TaskIDFromPayload: true},
+		TypeVideoComplete: {Queue: QueueCritical, MaxRetry: 5, Timeout: time.Minute, TaskIDFromPayload: true},
+		TypeSyncVoices:    {Queue: QueueLow, MaxRetry: 3, Timeout: 2 * time.Minute, UniqueTTL: time.Hour},
+		// The next tick retries a failed run
+		TypeReapStuckVideos: {Queue: QueueLow, MaxRetry: 1, Timeout: 5 * time.Minute, UniqueTTL: time.Minute},
+	}
+)
+
+// RegisterTaskPolicy sets the policy for tasks of taskType, replacing any existing one
+func RegisterTaskPolicy(taskType string, policy TaskPolicy) {
+	policiesMu.Lock()
+	defer policiesMu.Unlock()
+	policies[taskType] = policy
+}
+
+// TaskPolicyFor returns the policy for tasks of taskType
+func TaskPolicyFor(taskType string) TaskPolicy {
+	policiesMu.RLock()
+	defer policiesMu.RUnlock()
+	return policies[taskType]
+}
+
+// TaskOptions returns the asynq options for a task of taskType with payload
+func TaskOptions(taskType string, payload []byte) []asynq.Option {
+	return TaskPolicyFor(taskType).options(taskType, payload)
+}
@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// optionValues maps each option in opts to its value
+func optionValues(opts []asynq.Option) map[asynq.OptionType]interface{} {
+	values := make(map[asynq.OptionType]interface{}, len(opts))
+	for _, opt := range opts {
+		values[opt.Type()] = opt.Value()
+	}
+	return values
+}
+
+func TestTaskOptions(t *testing.T) {
+	render := optionValues(TaskOptions(TypeRenderVideo, []byte(`{"video_id":42}`)))
+	if render[asynq.QueueOpt] != QueueDefault || render[asynq.MaxRetryOpt] != 2 || render[asynq.TaskIDOpt] != "video:render:video:42" {
+		t.Errorf("Unexpected render options: %v", render)
+	}
+
+	complete := optionValues(TaskOptions(TypeVideoComplete, []byte(`{"video_id":42,"video_url":"https://example.com/v.mp4"}`)))
+	if complete[asynq.TaskIDOpt] != "video:complete:video:42" {
+		t.Errorf("Expected a task ID derived from the video, got %v", complete[asynq.TaskIDOpt])
+	}
+
+	for _, taskType := range []string{TypeGenerateVideoScript, TypeGenerateAudio, TypeGenerateCaptions, TypeGenerateScenes} {
+		step := optionValues(TaskOptions(taskType, []byte(`{"video_id":42}`)))
+		if step[asynq.TaskIDOpt] != taskType+":video:42" {
+			t.Errorf("Expected the %s task to be named after the video, got %v", taskType, step[asynq.TaskIDOpt])
+		}
+	}
+
+	image := optionValues(TaskOptions(TypeGenerateSceneImage, []byte(`{"scene_id":7}`)))
+	if image[asynq.TaskIDOpt] != "video:generate_scene_image:scene:7" {
+		t.Errorf("Expected a task ID derived from the scene, got %v", image[asynq.TaskIDOpt])
+	}
+
+	if opts := TaskOptions("unknown:type", nil); len(opts) != 0 {
+		t.Errorf("Expected asynq's defaults for a type without a policy, got %v", opts)
+	}
+}
+
+func TestRegisterTaskPolicy(t *testing.T) {
+	const taskType = "test:delayed"
+	RegisterTaskPolicy(taskType, TaskPolicy{Queue: QueueLow, Timeout: time.Minute, ProcessIn: time.Hour, TaskIDFromPayload: true})
+
+	opts := optionValues(TaskOptions(taskType, []byte(`{"scene_id":7}`)))
+	if opts[asynq.QueueOpt] != QueueLow || opts[asynq.TimeoutOpt] != time.Minute || opts[asynq.ProcessInOpt] != time.Hour {
+		t.Errorf("Unexpected options: %v", opts)
+	}
+	if opts[asynq.TaskIDOpt] != "test:delayed:scene:7" {
+		t.Errorf("Expected a task ID derived from the scene, got %v", opts[asynq.TaskIDOpt])
+	}
+	if _, ok := opts[asynq.MaxRetryOpt]; ok {
+		t.Errorf("Expected asynq's default retries when MaxRetry is unset, got %v", opts[asynq.MaxRetryOpt])
+	}
+}
+
+func TestPayloadTaskID(t *testing.T) {
+	tests := []struct {
+		payload  string
+		expected string
+	}{
+		{`{"video_id":42}`, "video:render:video:42"},
+		{`{"scene_id":7}`, "video:render:scene:7"},
+		{`{}`, ""},
+		{`not json`, ""},
+	}
+	for _, tt := range tests {
+		if got := payloadTaskID(TypeRenderVideo, []byte(tt.payload)); got != tt.expected {
+			t.Errorf("payloadTaskID(%s): expected %q, got %q", tt.payload, tt.expected, got)
+		}
+	}
+}
+
+func TestPayloadTaskTypes(t *testing.T) {
+	tests := []struct {
+		payload  Payload
+		expected string
+	}{
+		{GenerateVideoScriptPayload{}, TypeGenerateVideoScript},
+		{GenerateSceneImagePayload{}, TypeGenerateSceneImage},
+		{RenderVideoPayload{}, TypeRenderVideo},
+		{SyncVoicesPayload{}, TypeSyncVoices},
+		{ReapStuckVideosPayload{}, TypeReapStuckVideos},
+	}
+	for _, tt := range tests {
+		if got := tt.payload.TaskType(); got != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, got)
+		}
+	}
+}
+
+func TestRenderTaskIDIgnoresMeta(t *testing.T) {
+	// Two renders of a video never have the same payload: each carries its own
+	// trace, enqueue time and signed URLs
+	first, err := EncodePayload(context.Background(), RenderVideoPayload{VideoID: 42, AudioURL: "https://example.com/a.mp3?sig=1"})
+	if err != nil {
+		t.Fatalf("EncodePayload returned error: %v", err)
+	}
+	second, err := EncodePayload(context.Background(), RenderVideoPayload{VideoID: 42, AudioURL: "https://example.com/a.mp3?sig=2"})
+	if err != nil {
+		t.Fatalf("EncodePayload returned error: %v", err)
+	}
+	if bytes.Equal(first, second) {
+		t.Fatal("Expected the payloads to differ")
+	}
+
+	firstID := optionValues(TaskOptions(TypeRenderVideo, first))[asynq.TaskIDOpt]
+	secondID := optionValues(TaskOptions(TypeRenderVideo, second))[asynq.TaskIDOpt]
+	if firstID == nil || firstID != secondID {
+		t.Errorf("Expected both renders to get the same task ID, got %v and %v", firstID, secondID)
+	}
+}
@@ -1,6 +1,7 @@
 package queue
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -19,6 +20,8 @@ const (
 	TypeGenerateSceneImage  = "video:generate_scene_image"
 	TypeRenderVideo         = "video:render"
 	TypeVideoComplete       = "video:complete"
+	TypeSyncVoices          = "voices:sync"
+	TypeReapStuckVideos     = "videos:reap_stuck"
 	// Add more task types as needed
 )
 
@@ -53,9 +56,19 @@ type GenerateSceneImagePayload struct {
 	SceneID int `json:"scene_id"`
 }
 
-// RenderVideoPayload represents the payload for video rendering tasks
+// RenderVideoPayload represents the payload for video rendering tasks. The
+// renderer service can't sign storage URLs, so the task carries signed URLs
+// for the audio and every scene image; older tasks have neither.
 type RenderVideoPayload struct {
-	VideoID int `json:"video_id"`
+	VideoID     int                `json:"video_id"`
+	AudioURL    string             `json:"audio_url,omitempty"`
+	SceneImages []RenderSceneImage `json:"scene_images,omitempty"`
+}
+
+// RenderSceneImage is the signed image URL of a scene in a render task
+type RenderSceneImage struct {
+	SceneID  int    `json:"scene_id"`
+	ImageURL string `json:"image_url"`
 }
 
 // VideoCompletePayload represents the payload for video completion tasks
@@ -71,157 +84,83 @@ type SendEmailPayload struct {
 	Body    string `json:"body"`
 }
 
-// EnqueueProcessVideo enqueues a video processing task
-func (c *Client) EnqueueProcessVideo(payload ProcessVideoPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeProcessVideo, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
-}
-
-// EnqueueSendEmail enqueues an email sending task
-func (c *Client) EnqueueSendEmail(payload SendEmailPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeSendEmail, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
-}
-
-// EnqueueGenerateVideoScript enqueues a video script generation task
-func (c *Client) EnqueueGenerateVideoScript(payload GenerateVideoScriptPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeGenerateVideoScript, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
-}
-
-// EnqueueGenerateAudio enqueues an audio generation task
-func (c *Client) EnqueueGenerateAudio(payload GenerateAudioPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
+// SyncVoicesPayload represents the payload for voice catalog sync tasks
+type SyncVoicesPayload struct{}
 
-	task := asynq.NewTask(TypeGenerateAudio, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
+// ReapStuckVideosPayload represents the payload for stuck video reaper tasks
+type ReapStuckVideosPayload struct{}
 
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+// Payload is a task payload that knows the task type it belongs to
+type Payload interface {
+	TaskType() string
 }
 
-// EnqueueGenerateCaptions enqueues a caption generation task
-func (c *Client) EnqueueGenerateCaptions(payload GenerateCaptionsPayload) error {
-	jsonPayload, err := json.Marshal(payload)
+// TaskType implementations tie each payload to its task type
+func (ProcessVideoPayload) TaskType() string        { return TypeProcessVideo }
+func (SendEmailPayload) TaskType() string           { return TypeSendEmail }
+func (GenerateVideoScriptPayload) TaskType() string { return TypeGenerateVideoScript }
+func (GenerateAudioPayload) TaskType() string       { return TypeGenerateAudio }
+func (GenerateCaptionsPayload) TaskType() string    { return TypeGenerateCaptions }
+func (GenerateScenesPayload) TaskType() string      { return TypeGenerateScenes }
+func (GenerateSceneImagePayload) TaskType() string  { return TypeGenerateSceneImage }
+func (RenderVideoPayload) TaskType() string         { return TypeRenderVideo }
+func (VideoCompletePayload) TaskType() string       { return TypeVideoComplete }
+func (SyncVoicesPayload) TaskType() string          { return TypeSyncVoices }
+func (ReapStuckVideosPayload) TaskType() string     { return TypeReapStuckVideos }
+
+// NewTask builds the task for payload, with the metadata of ctx and the options
+// of its type's policy followed by opts. Use it where asynq takes a task
+// directly; the scheduler takes NewScheduledTask instead.
+func NewTask[T Payload](ctx context.Context, payload T, opts ...asynq.Option) (*asynq.Task, error) {
+	jsonPayload, err := EncodePayload(ctx, payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+		return nil, err
 	}
-
-	task := asynq.NewTask(TypeGenerateCaptions, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+	taskType := payload.TaskType()
+	return asynq.NewTask(taskType, jsonPayload, append(TaskOptions(taskType, jsonPayload), opts...)...), nil
 }
 
-// EnqueueGenerateScenes enqueues a scene generation task
-func (c *Client) EnqueueGenerateScenes(payload GenerateScenesPayload) error {
+// NewScheduledTask builds a task for the scheduler, which enqueues the same
+// task on every run. It carries no metadata, since a trace ID and enqueue time
+// fixed at registration would be shared by every run; the tasks its handler
+// enqueues start their own traces.
+func NewScheduledTask[T Payload](payload T, opts ...asynq.Option) (*asynq.Task, error) {
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeGenerateScenes, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
 	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+	taskType := payload.TaskType()
+	return asynq.NewTask(taskType, jsonPayload, append(TaskOptions(taskType, jsonPayload), opts...)...), nil
 }
 
-// EnqueueGenerateSceneImage enqueues a scene image generation task
-func (c *Client) EnqueueGenerateSceneImage(payload GenerateSceneImagePayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeGenerateSceneImage, jsonPayload)
-	info, err := c.client.Enqueue(task)
+// Enqueue enqueues a task for payload with the options of its type's policy.
+// opts are applied last, so a caller can override the policy or delay a single
+// task with asynq.ProcessIn or asynq.ProcessAt. The returned TaskInfo carries
+// the task ID. A task rejected by its Unique TTL or TaskID returns an error
+// wrapping asynq.ErrDuplicateTask or asynq.ErrTaskIDConflict.
+func Enqueue[T Payload](ctx context.Context, c *Client, payload T, opts ...asynq.Option) (*asynq.TaskInfo, error) {
+	task, err := NewTask(ctx, payload, opts...)
 	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
+		return nil, err
 	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+	return c.enqueue(task)
 }
 
-// EnqueueRenderVideo enqueues a video rendering task
-func (c *Client) EnqueueRenderVideo(payload RenderVideoPayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeRenderVideo, jsonPayload)
-	info, err := c.client.Enqueue(task)
-	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
-	}
-
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+// Enqueue enqueues a task from its type and already-marshalled payload, with
+// the options of its type's policy. The outbox relay uses it to publish stored tasks.
+func (c *Client) Enqueue(taskType string, payload []byte) error {
+	_, err := c.enqueue(asynq.NewTask(taskType, payload, TaskOptions(taskType, payload)...))
+	return err
 }
 
-// EnqueueVideoComplete enqueues a video completion task
-func (c *Client) EnqueueVideoComplete(payload VideoCompletePayload) error {
-	jsonPayload, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
-	}
-
-	task := asynq.NewTask(TypeVideoComplete, jsonPayload)
+func (c *Client) enqueue(task *asynq.Task) (*asynq.TaskInfo, error) {
 	info, err := c.client.Enqueue(task)
 	if err != nil {
-		return fmt.Errorf("failed to enqueue task: %w", err)
+		return nil, fmt.Errorf("failed to enqueue %s task: %w", task.Type(), err)
 	}
 
-	log.Printf("Enqueued task: id=%s queue=%s", info.ID, info.Queue)
-	return nil
+	log.Printf("Enqueued task: type=%s id=%s queue=%s", info.Type, info.ID, info.Queue)
+	return info, nil
 }
 
 // Task handlers
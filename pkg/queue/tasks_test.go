@@ -0,0 +1,77 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// startRedis points the client at a throwaway Redis container
+func startRedis(t *testing.T) {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	redis, err := testcontainers.GenericContainer(context.Background(), testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7-alpine",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForListeningPort("6379/tcp"),
+		},
+		Started: true,
+	})
+	testcontainers.CleanupContainer(t, redis)
+	if err != nil {
+		t.Fatalf("could not start redis container: %v", err)
+	}
+
+	host, err := redis.Host(context.Background())
+	if err != nil {
+		t.Fatalf("could not get redis host: %v", err)
+	}
+	port, err := redis.MappedPort(context.Background(), "6379/tcp")
+	if err != nil {
+		t.Fatalf("could not get redis port: %v", err)
+	}
+	t.Setenv("REDIS_HOST", host)
+	t.Setenv("REDIS_PORT", port.Port())
+}
+
+func TestEnqueueRejectsSecondRender(t *testing.T) {
+	startRedis(t)
+	client := NewClient()
+	defer client.Close()
+
+	// Each enqueue starts its own trace, so the payloads differ
+	ctx := context.Background()
+	if _, err := Enqueue(ctx, client, RenderVideoPayload{VideoID: 42}); err != nil {
+		t.Fatalf("Enqueue returned error: %v", err)
+	}
+	_, err := Enqueue(ctx, client, RenderVideoPayload{VideoID: 42})
+	if !errors.Is(err, asynq.ErrDuplicateTask) && !errors.Is(err, asynq.ErrTaskIDConflict) {
+		t.Errorf("Expected the second render of the video to be rejected, got %v", err)
+	}
+
+	if _, err := Enqueue(ctx, client, RenderVideoPayload{VideoID: 43}); err != nil {
+		t.Errorf("Expected the render of another video to be queued, got %v", err)
+	}
+}
+
+func TestNewScheduledTask(t *testing.T) {
+	first, err := NewScheduledTask(ReapStuckVideosPayload{})
+	if err != nil {
+		t.Fatalf("NewScheduledTask returned error: %v", err)
+	}
+	if first.Type() != TypeReapStuckVideos || string(first.Payload()) != `{}` {
+		t.Errorf("Expected a bare reaper task, got %s %s", first.Type(), first.Payload())
+	}
+
+	// Without metadata a run can't carry the trace of the one before it
+	meta, err := DecodePayload(first.Payload(), &ReapStuckVideosPayload{})
+	if err != nil || meta.TraceID != "" || !meta.EnqueuedAt.IsZero() {
+		t.Errorf("Expected no task metadata, got %+v (%v)", meta, err)
+	}
+}
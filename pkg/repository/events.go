@@ -0,0 +1,193 @@
+package repository
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"instashorts-be/pkg/events"
+	"instashorts-be/pkg/models"
+)
+
+// publishingStore is a VideoStore that publishes an event for every status
+// change and pipeline step it records
+type publishingStore struct {
+	VideoStore
+	events events.Publisher
+	// steps holds started steps until they finish, since FinishPipelineStep
+	// only has the ID
+	steps *sync.Map
+	// pending collects the events of a transaction until it commits; it is
+	// nil outside one
+	pending *[]events.Event
+}
+
+// WithEvents wraps store so the status changes and pipeline steps it records
+// are published once committed. Publishing is best-effort: failures are
+// logged and never fail the write.
+func WithEvents(store VideoStore, publisher events.Publisher) VideoStore {
+	return &publishingStore{VideoStore: store, events: publisher, steps: &sync.Map{}}
+}
+
+func (s *publishingStore) Transaction(ctx context.Context, fn func(tx VideoStore) error) error {
+	var pending []events.Event
+	if s.pending != nil {
+		// A nested transaction's events commit with the outer one, unless it fails
+		pending = *s.pending
+		err := s.VideoStore.Transaction(ctx, func(tx VideoStore) error {
+			return fn(&publishingStore{VideoStore: tx, events: s.events, steps: s.steps, pending: s.pending})
+		})
+		if err != nil {
+			*s.pending = pending
+		}
+		return err
+	}
+
+	err := s.VideoStore.Transaction(ctx, func(tx VideoStore) error {
+		return fn(&publishingStore{VideoStore: tx, events: s.events, steps: s.steps, pending: &pending})
+	})
+	if err != nil {
+		return err
+	}
+	for _, event := range pending {
+		s.publish(ctx, event)
+	}
+	return nil
+}
+
+func (s *publishingStore) Transition(ctx context.Context, id int, from, to models.VideoStatus, reason string) error {
+	_, err := s.moveStatus(ctx, id, statusChange{from: from, to: to, reason: reason})
+	return err
+}
+
+// moveStatus applies change and publishes it only when the video moved, not
+// when it was already in change.to
+func (s *publishingStore) moveStatus(ctx context.Context, id int, change statusChange) (bool, error) {
+	var moved bool
+	var err error
+	if mover, ok := s.VideoStore.(statusMover); ok {
+		moved, err = mover.moveStatus(ctx, id, change)
+	} else {
+		moved, err = s.compareAndMove(ctx, id, change)
+	}
+	if moved {
+		s.statusChanged(ctx, id, change.reason)
+	}
+	return moved, err
+}
+
+// compareAndMove applies change through a store from outside this package,
+// telling whether the video moved from its status before the change
+func (s *publishingStore) compareAndMove(ctx context.Context, id int, change statusChange) (bool, error) {
+	before, err := s.VideoStore.GetVideo(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if change.to == models.VideoStatusCompleted {
+		err = s.VideoStore.CompleteVideo(ctx, id, change.videoURL)
+	} else {
+		err = s.VideoStore.Transition(ctx, id, change.from, change.to, change.reason)
+	}
+	return err == nil && before.Status != change.to, err
+}
+
+func (s *publishingStore) MarkVideoFailed(ctx context.Context, id int, reason string, from ...models.VideoStatus) (bool, error) {
+	moved, err := s.VideoStore.MarkVideoFailed(ctx, id, reason, from...)
+	if moved {
+		s.statusChanged(ctx, id, reason)
+	}
+	return moved, err
+}
+
+func (s *publishingStore) CancelVideo(ctx context.Context, id int, reason string) (bool, error) {
+	moved, err := s.VideoStore.CancelVideo(ctx, id, reason)
+	if moved {
+		s.statusChanged(ctx, id, reason)
+	}
+	return moved, err
+}
+
+func (s *publishingStore) CompleteVideo(ctx context.Context, id int, videoURL string) error {
+	_, err := s.moveStatus(ctx, id, renderCompletion(videoURL))
+	return err
+}
+
+func (s *publishingStore) ClaimRender(ctx context.Context, id int) (bool, error) {
+	claimed, err := s.VideoStore.ClaimRender(ctx, id)
+	if claimed {
+		s.statusChanged(ctx, id, renderClaim.reason)
+	}
+	return claimed, err
+}
+
+func (s *publishingStore) CreatePipelineStep(ctx context.Context, step *models.PipelineStep) error {
+	if err := s.VideoStore.CreatePipelineStep(ctx, step); err != nil {
+		return err
+	}
+	s.steps.Store(step.ID, *step)
+	s.emit(ctx, stepEvent(*step))
+	return nil
+}
+
+func (s *publishingStore) FinishPipelineStep(ctx context.Context, id int, outcome models.PipelineStepOutcome, stepErr *string) error {
+	if err := s.VideoStore.FinishPipelineStep(ctx, id, outcome, stepErr); err != nil {
+		return err
+	}
+	started, ok := s.steps.LoadAndDelete(id)
+	if !ok {
+		// Started before a restart, so its video is unknown
+		return nil
+	}
+	step := started.(models.PipelineStep)
+	step.Outcome = outcome
+	step.Error = stepErr
+	s.emit(ctx, stepEvent(step))
+	return nil
+}
+
+// stepEvent describes a pipeline step attempt
+func stepEvent(step models.PipelineStep) events.Event {
+	return events.Event{
+		Type:    events.TypeStep,
+		VideoID: step.VideoID,
+		Step:    step.Step,
+		SceneID: step.SceneID,
+		Attempt: step.Attempt,
+		Outcome: step.Outcome,
+		Error:   step.Error,
+	}
+}
+
+// statusChanged emits the status event of a video that moved
+func (s *publishingStore) statusChanged(ctx context.Context, id int, reason string) {
+	s.emit(ctx, events.Event{Type: events.TypeStatus, VideoID: id, Reason: reason})
+}
+
+// emit publishes event, or holds it until the surrounding transaction commits
+func (s *publishingStore) emit(ctx context.Context, event events.Event) {
+	event.At = time.Now().UTC()
+	if s.pending != nil {
+		*s.pending = append(*s.pending, event)
+		return
+	}
+	s.publish(ctx, event)
+}
+
+// publish fills in the owner and current status of the event's video and
+// publishes it
+func (s *publishingStore) publish(ctx context.Context, event events.Event) {
+	// The write has happened even if the caller's context is done by now
+	ctx = context.WithoutCancel(ctx)
+
+	video, err := s.VideoStore.GetVideo(ctx, event.VideoID)
+	if err != nil {
+		log.Printf("ERROR: Failed to fetch video %d for its %s event: %v", event.VideoID, event.Type, err)
+		return
+	}
+	event.UserID = video.UserID
+	event.Status = video.Status
+	if err := s.events.Publish(ctx, event); err != nil {
+		log.Printf("ERROR: Failed to publish %s event for video_id=%d: %v", event.Type, event.VideoID, err)
+	}
+}
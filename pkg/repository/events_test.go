@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"instashorts-be/pkg/events"
+	"instashorts-be/pkg/models"
+)
+
+// recordingPublisher keeps the events published to it
+type recordingPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (p *recordingPublisher) Publish(ctx context.Context, event events.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+func (p *recordingPublisher) published() []events.Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]events.Event(nil), p.events...)
+}
+
+func TestWithEventsPublishesStatusChanges(t *testing.T) {
+	memory := NewMemoryVideoStore()
+	memory.AddVideo(models.Video{ID: 1, UserID: 7, Status: models.VideoStatusPending})
+	publisher := &recordingPublisher{}
+	store := WithEvents(memory, publisher)
+	ctx := context.Background()
+
+	if err := store.Transition(ctx, 1, models.VideoStatusPending, models.VideoStatusGeneratingScript, "script queued"); err != nil {
+		t.Fatalf("Transition returned error: %v", err)
+	}
+	// Repeating a move the video already made changes nothing and publishes nothing
+	if err := store.Transition(ctx, 1, models.VideoStatusPending, models.VideoStatusGeneratingScript, "script queued"); err != nil {
+		t.Fatalf("Expected the repeated transition to succeed, got %v", err)
+	}
+	// A move the video can't make changes nothing and publishes nothing
+	if err := store.Transition(ctx, 1, models.VideoStatusPending, models.VideoStatusCancelled, "cancelled"); !errors.Is(err, ErrStatusChanged) {
+		t.Fatalf("Expected ErrStatusChanged, got %v", err)
+	}
+	if _, err := store.MarkVideoFailed(ctx, 1, "provider down"); err != nil {
+		t.Fatalf("MarkVideoFailed returned error: %v", err)
+	}
+
+	published := publisher.published()
+	if len(published) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", published)
+	}
+	first := published[0]
+	if first.Type != events.TypeStatus || first.UserID != 7 || first.VideoID != 1 || first.Status != models.VideoStatusGeneratingScript || first.Reason != "script queued" {
+		t.Errorf("Unexpected first event %+v", first)
+	}
+	if second := published[1]; second.Status != models.VideoStatusFailed || second.Reason != "provider down" {
+		t.Errorf("Expected the failure to be published, got %+v", second)
+	}
+}
+
+func TestWithEventsPublishesAfterCommit(t *testing.T) {
+	memory := NewMemoryVideoStore()
+	memory.AddVideo(models.Video{ID: 1, UserID: 7, Status: models.VideoStatusGeneratingScript})
+	publisher := &recordingPublisher{}
+	store := WithEvents(memory, publisher)
+	ctx := context.Background()
+
+	rollback := errors.New("rollback")
+	err := store.Transaction(ctx, func(tx VideoStore) error {
+		if err := tx.Transition(ctx, 1, models.VideoStatusGeneratingScript, models.VideoStatusGeneratingAudio, "script saved"); err != nil {
+			return err
+		}
+		return rollback
+	})
+	if !errors.Is(err, rollback) {
+		t.Fatalf("Expected the transaction to fail, got %v", err)
+	}
+	if published := publisher.published(); len(published) != 0 {
+		t.Fatalf("Expected nothing to be published for a rolled back transaction, got %+v", published)
+	}
+
+	err = store.Transaction(ctx, func(tx VideoStore) error {
+		if err := tx.Transition(ctx, 1, models.VideoStatusGeneratingScript, models.VideoStatusGeneratingAudio, "script saved"); err != nil {
+			return err
+		}
+		if len(publisher.published()) != 0 {
+			t.Error("Expected the event to wait for the commit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transaction returned error: %v", err)
+	}
+	if published := publisher.published(); len(published) != 1 || published[0].Status != models.VideoStatusGeneratingAudio {
+		t.Errorf("Expected the committed status change to be published, got %+v", published)
+	}
+}
+
+func TestWithEventsPublishesPipelineSteps(t *testing.T) {
+	memory := NewMemoryVideoStore()
+	memory.AddVideo(models.Video{ID: 1, UserID: 7, Status: models.VideoStatusGeneratingAudio})
+	publisher := &recordingPublisher{}
+	store := WithEvents(memory, publisher)
+	ctx := context.Background()
+
+	step := models.PipelineStep{VideoID: 1, Step: "audio:generate", Attempt: 2, Outcome: models.PipelineStepRunning}
+	if err := store.CreatePipelineStep(ctx, &step); err != nil {
+		t.Fatalf("CreatePipelineStep returned error: %v", err)
+	}
+	if err := store.FinishPipelineStep(ctx, step.ID, models.PipelineStepFailed, strPtr("quota exceeded")); err != nil {
+		t.Fatalf("FinishPipelineStep returned error: %v", err)
+	}
+
+	published := publisher.published()
+	if len(published) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", published)
+	}
+	if started := published[0]; started.Type != events.TypeStep || started.Outcome != models.PipelineStepRunning || started.Attempt != 2 {
+		t.Errorf("Expected the start of the step, got %+v", started)
+	}
+	finished := published[1]
+	if finished.Step != "audio:generate" || finished.UserID != 7 || finished.Outcome != models.PipelineStepFailed || deref(finished.Error) != "quota exceeded" {
+		t.Errorf("Expected the failure of the step, got %+v", finished)
+	}
+}
+
+func TestWithEventsComparesStatusOfOtherStores(t *testing.T) {
+	memory := NewMemoryVideoStore()
+	memory.AddVideo(models.Video{ID: 1, UserID: 7, Status: models.VideoStatusRendering})
+	publisher := &recordingPublisher{}
+	// Embedding the interface hides how the memory store reports moves
+	store := WithEvents(struct{ VideoStore }{memory}, publisher)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if err := store.CompleteVideo(ctx, 1, "https://example.com/1.mp4"); err != nil {
+			t.Fatalf("CompleteVideo returned error: %v", err)
+		}
+	}
+
+	if published := publisher.published(); len(published) != 1 || published[0].Status != models.VideoStatusCompleted {
+		t.Errorf("Expected one completion event, got %+v", published)
+	}
+}
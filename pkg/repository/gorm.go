@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormVideoStore is the VideoStore backed by the database
+type GormVideoStore struct {
+	db *gorm.DB
+}
+
+// NewGormVideoStore creates a VideoStore on db
+func NewGormVideoStore(db *gorm.DB) *GormVideoStore {
+	return &GormVideoStore{db: db}
+}
+
+// notFound maps GORM's missing record error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *GormVideoStore) Transaction(ctx context.Context, fn func(tx VideoStore) error) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormVideoStore{db: tx})
+	})
+}
+
+func (s *GormVideoStore) GetVideo(ctx context.Context, id int) (*models.Video, error) {
+	var video models.Video
+	if err := s.db.WithContext(ctx).Unscoped().Take(&video, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &video, nil
+}
+
+func (s *GormVideoStore) ListVideosInStatus(ctx context.Context, statuses ...models.VideoStatus) ([]models.Video, error) {
+	var videos []models.Video
+	err := s.db.WithContext(ctx).
+		Where("status IN ?", statuses).
+		Order("id ASC").
+		Find(&videos).Error
+	return videos, err
+}
+
+func (s *GormVideoStore) Transition(ctx context.Context, id int, from, to models.VideoStatus, reason string) error {
+	_, err := s.moveStatus(ctx, id, statusChange{from: from, to: to, reason: reason})
+	return err
+}
+
+func (s *GormVideoStore) moveStatus(ctx context.Context, id int, change statusChange) (bool, error) {
+	return transition(ctx, s, id, change)
+}
+
+func (s *GormVideoStore) MarkVideoFailed(ctx context.Context, id int, reason string, from ...models.VideoStatus) (bool, error) {
+	if len(from) == 0 {
+		from = models.TransitionsTo(models.VideoStatusFailed)
+	}
+	return transitionFromAny(ctx, s, id, from, models.VideoStatusFailed, reason)
+}
+
+func (s *GormVideoStore) CancelVideo(ctx context.Context, id int, reason string) (bool, error) {
+	return transitionFromAny(ctx, s, id, models.TransitionsTo(models.VideoStatusCancelled), models.VideoStatusCancelled, reason)
+}
+
+// swapStatus is the compare-and-swap UPDATE behind every status change. The
+// transition is recorded in the same transaction.
+func (s *GormVideoStore) swapStatus(ctx context.Context, id int, change statusChange) (bool, error) {
+	updates := map[string]interface{}{"status": change.to}
+	switch {
+	case change.to == models.VideoStatusFailed:
+		updates["failure_reason"] = change.reason
+	case change.retries():
+		updates["failure_reason"] = nil
+	}
+	if change.to == models.VideoStatusCompleted {
+		updates["completed_at"] = time.Now().UTC()
+		if change.videoURL != "" {
+			updates["video_url"] = change.videoURL
+		}
+	}
+
+	moved := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Video{}).
+			Where("id = ? AND status = ?", id, change.from).
+			Updates(updates)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		moved = true
+		return recordTransition(tx, id, change)
+	})
+	return moved && err == nil, err
+}
+
+// recordTransition adds a status change to the video's history
+func recordTransition(tx *gorm.DB, id int, change statusChange) error {
+	return tx.Create(&models.VideoStatusTransition{
+		VideoID:    id,
+		FromStatus: change.from,
+		ToStatus:   change.to,
+		Reason:     change.reason,
+	}).Error
+}
+
+func (s *GormVideoStore) SaveScript(ctx context.Context, id int, script string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.Video{}).
+		Where("id = ?", id).
+		Update("script", script).Error
+}
+
+func (s *GormVideoStore) SaveAudio(ctx context.Context, id int, bucket, key string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.Video{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"audio_bucket": bucket,
+			"audio_key":    key,
+			"audio_url":    nil,
+		}).Error
+}
+
+func (s *GormVideoStore) SaveCaptions(ctx context.Context, id int, captions string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.Video{}).
+		Where("id = ?", id).
+		Update("captions", captions).Error
+}
+
+func (s *GormVideoStore) CompleteVideo(ctx context.Context, id int, videoURL string) error {
+	_, err := s.moveStatus(ctx, id, renderCompletion(videoURL))
+	return err
+}
+
+// ClaimRender checks the prerequisites and changes the status in a single
+// conditional UPDATE, so exactly one concurrent caller gets true
+func (s *GormVideoStore) ClaimRender(ctx context.Context, id int) (bool, error) {
+	claimed := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Video{}).
+			Where("id = ? AND status = ?", id, models.VideoStatusGeneratingImages).
+			Where("captions IS NOT NULL AND captions <> ''").
+			Where("EXISTS (SELECT 1 FROM video_scenes WHERE video_scenes.video_id = videos.id)").
+			Where("NOT EXISTS (SELECT 1 FROM video_scenes WHERE video_scenes.video_id = videos.id AND (video_scenes.status <> ? OR (video_scenes.image_key IS NULL AND video_scenes.image_url IS NULL)))", models.SceneStatusCompleted).
+			Update("status", models.VideoStatusReadyToRender)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		claimed = true
+		return recordTransition(tx, id, renderClaim)
+	})
+	return claimed && err == nil, err
+}
+
+func (s *GormVideoStore) ListStatusTransitions(ctx context.Context, videoID int) ([]models.VideoStatusTransition, error) {
+	var transitions []models.VideoStatusTransition
+	err := s.db.WithContext(ctx).
+		Where("video_id = ?", videoID).
+		Order("id ASC").
+		Find(&transitions).Error
+	return transitions, err
+}
+
+func (s *GormVideoStore) GetScene(ctx context.Context, id int) (*models.VideoScene, error) {
+	var scene models.VideoScene
+	if err := s.db.WithContext(ctx).Take(&scene, id).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &scene, nil
+}
+
+func (s *GormVideoStore) ListScenes(ctx context.Context, videoID int) ([]models.VideoScene, error) {
+	var scenes []models.VideoScene
+	err := s.db.WithContext(ctx).
+		Where("video_id = ?", videoID).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "index"}}).
+		Find(&scenes).Error
+	return scenes, err
+}
+
+// UpsertScene relies on the unique (video_id, index) constraint. The no-op
+// update on conflict keeps the existing row and still returns its ID.
+func (s *GormVideoStore) UpsertScene(ctx context.Context, scene *models.VideoScene) error {
+	return s.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "video_id"}, {Name: "index"}},
+			DoUpdates: clause.AssignmentColumns([]string{"index"}),
+		}).
+		Create(scene).Error
+}
+
+func (s *GormVideoStore) SetSceneStatus(ctx context.Context, id int, status string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.VideoScene{}).
+		Where("id = ?", id).
+		Update("status", status).Error
+}
+
+func (s *GormVideoStore) SaveSceneImage(ctx context.Context, id int, bucket, key string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.VideoScene{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"image_bucket": bucket,
+			"image_key":    key,
+			"image_url":    nil,
+		}).Error
+}
+
+func (s *GormVideoStore) CreatePipelineStep(ctx context.Context, step *models.PipelineStep) error {
+	return s.db.WithContext(ctx).Create(step).Error
+}
+
+func (s *GormVideoStore) FinishPipelineStep(ctx context.Context, id int, outcome models.PipelineStepOutcome, stepErr *string) error {
+	return s.db.WithContext(ctx).
+		Model(&models.PipelineStep{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"outcome":     outcome,
+			"error":       stepErr,
+			"finished_at": time.Now().UTC(),
+		}).Error
+}
+
+func (s *GormVideoStore) LatestPipelineStep(ctx context.Context, videoID int) (*models.PipelineStep, error) {
+	var step models.PipelineStep
+	if err := s.db.WithContext(ctx).
+		Where("video_id = ?", videoID).
+		Order("started_at DESC, id DESC").
+		Take(&step).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return &step, nil
+}
+
+func (s *GormVideoStore) CountPipelineSteps(ctx context.Context, videoID int, step string, outcome models.PipelineStepOutcome, since time.Time) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).
+		Model(&models.PipelineStep{}).
+		Where("video_id = ? AND step = ? AND outcome = ? AND started_at >= ?", videoID, step, outcome, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (s *GormVideoStore) WriteOutbox(ctx context.Context, taskType string, payload interface{}) error {
+	return queue.WriteOutbox(s.db.WithContext(ctx), taskType, payload)
+}
+
+func (s *GormVideoStore) CountPendingOutboxTasks(ctx context.Context, videoID int, sceneIDs []int) (int, error) {
+	// The relay keeps the pending tasks few, so they are matched here rather
+	// than by decoding every payload in SQL
+	var pending []queue.OutboxTask
+	err := s.db.WithContext(ctx).
+		Select("id", "payload").
+		Where("dispatched_at IS NULL").
+		Find(&pending).Error
+	if err != nil {
+		return 0, err
+	}
+	return countVideoTasks(pending, videoID, sceneIDs), nil
+}
@@ -0,0 +1,411 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+)
+
+// MemoryVideoStore is a VideoStore kept in memory, for tests of code that
+// would otherwise need a database. A failed transaction rolls everything back.
+type MemoryVideoStore struct {
+	mu   *sync.Mutex
+	data *memoryData
+	// inTx is set on the store handed to a transaction, which already holds mu
+	inTx bool
+}
+
+// memoryData is everything a MemoryVideoStore holds
+type memoryData struct {
+	videos      map[int]models.Video
+	scenes      map[int]models.VideoScene
+	steps       []models.PipelineStep
+	transitions []models.VideoStatusTransition
+	outbox      []queue.OutboxTask
+	nextID      int
+}
+
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		videos:      cloneMap(d.videos),
+		scenes:      cloneMap(d.scenes),
+		steps:       slices.Clone(d.steps),
+		transitions: slices.Clone(d.transitions),
+		outbox:      slices.Clone(d.outbox),
+		nextID:      d.nextID,
+	}
+}
+
+func cloneMap[T any](m map[int]T) map[int]T {
+	cloned := make(map[int]T, len(m))
+	for k, v := range m {
+		cloned[k] = v
+	}
+	return cloned
+}
+
+// NewMemoryVideoStore creates an empty MemoryVideoStore
+func NewMemoryVideoStore() *MemoryVideoStore {
+	return &MemoryVideoStore{
+		mu: &sync.Mutex{},
+		data: &memoryData{
+			videos: make(map[int]models.Video),
+			scenes: make(map[int]models.VideoScene),
+		},
+	}
+}
+
+func (s *MemoryVideoStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+func (s *MemoryVideoStore) newID() int {
+	s.data.nextID++
+	return s.data.nextID
+}
+
+// AddVideo stores video as is and returns its ID, assigning one when it has none
+func (s *MemoryVideoStore) AddVideo(video models.Video) int {
+	defer s.lock()()
+	if video.ID == 0 {
+		video.ID = s.newID()
+	}
+	s.data.nextID = max(s.data.nextID, video.ID)
+	video.Scenes = nil
+	s.data.videos[video.ID] = video
+	return video.ID
+}
+
+// AddScene stores scene as is and returns its ID, assigning one when it has none
+func (s *MemoryVideoStore) AddScene(scene models.VideoScene) int {
+	defer s.lock()()
+	if scene.ID == 0 {
+		scene.ID = s.newID()
+	}
+	s.data.nextID = max(s.data.nextID, scene.ID)
+	s.data.scenes[scene.ID] = scene
+	return scene.ID
+}
+
+// PipelineSteps returns the pipeline steps recorded for a video
+func (s *MemoryVideoStore) PipelineSteps(videoID int) []models.PipelineStep {
+	defer s.lock()()
+	var steps []models.PipelineStep
+	for _, step := range s.data.steps {
+		if step.VideoID == videoID {
+			steps = append(steps, step)
+		}
+	}
+	return steps
+}
+
+// OutboxTasks returns the tasks written to the outbox in order
+func (s *MemoryVideoStore) OutboxTasks() []queue.OutboxTask {
+	defer s.lock()()
+	return slices.Clone(s.data.outbox)
+}
+
+func (s *MemoryVideoStore) Transaction(ctx context.Context, fn func(tx VideoStore) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := s.data.clone()
+	if err := fn(&MemoryVideoStore{mu: s.mu, data: s.data, inTx: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+func (s *MemoryVideoStore) GetVideo(ctx context.Context, id int) (*models.Video, error) {
+	defer s.lock()()
+	video, ok := s.data.videos[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &video, nil
+}
+
+func (s *MemoryVideoStore) ListVideosInStatus(ctx context.Context, statuses ...models.VideoStatus) ([]models.Video, error) {
+	defer s.lock()()
+	var videos []models.Video
+	for _, video := range s.data.videos {
+		if !video.DeletedAt.Valid && slices.Contains(statuses, video.Status) {
+			videos = append(videos, video)
+		}
+	}
+	sort.Slice(videos, func(i, j int) bool { return videos[i].ID < videos[j].ID })
+	return videos, nil
+}
+
+// updateVideo applies update to the video with id if it exists and isn't deleted
+func (s *MemoryVideoStore) updateVideo(id int, update func(*models.Video)) {
+	defer s.lock()()
+	video, ok := s.data.videos[id]
+	if !ok || video.DeletedAt.Valid {
+		return
+	}
+	update(&video)
+	video.UpdatedAt = time.Now().UTC()
+	s.data.videos[id] = video
+}
+
+func (s *MemoryVideoStore) Transition(ctx context.Context, id int, from, to models.VideoStatus, reason string) error {
+	_, err := s.moveStatus(ctx, id, statusChange{from: from, to: to, reason: reason})
+	return err
+}
+
+func (s *MemoryVideoStore) moveStatus(ctx context.Context, id int, change statusChange) (bool, error) {
+	return transition(ctx, s, id, change)
+}
+
+func (s *MemoryVideoStore) MarkVideoFailed(ctx context.Context, id int, reason string, from ...models.VideoStatus) (bool, error) {
+	if len(from) == 0 {
+		from = models.TransitionsTo(models.VideoStatusFailed)
+	}
+	return transitionFromAny(ctx, s, id, from, models.VideoStatusFailed, reason)
+}
+
+func (s *MemoryVideoStore) CancelVideo(ctx context.Context, id int, reason string) (bool, error) {
+	return transitionFromAny(ctx, s, id, models.TransitionsTo(models.VideoStatusCancelled), models.VideoStatusCancelled, reason)
+}
+
+func (s *MemoryVideoStore) swapStatus(ctx context.Context, id int, change statusChange) (bool, error) {
+	defer s.lock()()
+	return s.swapStatusLocked(id, change), nil
+}
+
+// swapStatusLocked applies and records change with the store locked
+func (s *MemoryVideoStore) swapStatusLocked(id int, change statusChange) bool {
+	video, ok := s.data.videos[id]
+	if !ok || video.DeletedAt.Valid || video.Status != change.from {
+		return false
+	}
+
+	now := time.Now().UTC()
+	video.Status = change.to
+	switch {
+	case change.to == models.VideoStatusFailed:
+		video.FailureReason = &change.reason
+	case change.retries():
+		video.FailureReason = nil
+	}
+	if change.to == models.VideoStatusCompleted {
+		video.CompletedAt = now
+		if change.videoURL != "" {
+			video.VideoURL = &change.videoURL
+		}
+	}
+	video.UpdatedAt = now
+	s.data.videos[id] = video
+
+	s.data.transitions = append(s.data.transitions, models.VideoStatusTransition{
+		ID:         s.newID(),
+		VideoID:    id,
+		FromStatus: change.from,
+		ToStatus:   change.to,
+		Reason:     change.reason,
+		CreatedAt:  now,
+	})
+	return true
+}
+
+func (s *MemoryVideoStore) SaveScript(ctx context.Context, id int, script string) error {
+	s.updateVideo(id, func(v *models.Video) { v.Script = &script })
+	return nil
+}
+
+func (s *MemoryVideoStore) SaveAudio(ctx context.Context, id int, bucket, key string) error {
+	s.updateVideo(id, func(v *models.Video) {
+		v.AudioBucket, v.AudioKey, v.AudioURL = &bucket, &key, nil
+	})
+	return nil
+}
+
+func (s *MemoryVideoStore) SaveCaptions(ctx context.Context, id int, captions string) error {
+	s.updateVideo(id, func(v *models.Video) { v.Captions = &captions })
+	return nil
+}
+
+func (s *MemoryVideoStore) CompleteVideo(ctx context.Context, id int, videoURL string) error {
+	_, err := s.moveStatus(ctx, id, renderCompletion(videoURL))
+	return err
+}
+
+func (s *MemoryVideoStore) ClaimRender(ctx context.Context, id int) (bool, error) {
+	defer s.lock()()
+	video, ok := s.data.videos[id]
+	if !ok || video.DeletedAt.Valid || video.Status != models.VideoStatusGeneratingImages || video.Captions == nil || *video.Captions == "" {
+		return false, nil
+	}
+
+	scenes := 0
+	for _, scene := range s.data.scenes {
+		if scene.VideoID != id {
+			continue
+		}
+		if scene.Status != models.SceneStatusCompleted || (scene.ImageKey == nil && scene.ImageURL == nil) {
+			return false, nil
+		}
+		scenes++
+	}
+	if scenes == 0 {
+		return false, nil
+	}
+	return s.swapStatusLocked(id, renderClaim), nil
+}
+
+func (s *MemoryVideoStore) ListStatusTransitions(ctx context.Context, videoID int) ([]models.VideoStatusTransition, error) {
+	defer s.lock()()
+	var transitions []models.VideoStatusTransition
+	for _, transition := range s.data.transitions {
+		if transition.VideoID == videoID {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions, nil
+}
+
+func (s *MemoryVideoStore) GetScene(ctx context.Context, id int) (*models.VideoScene, error) {
+	defer s.lock()()
+	scene, ok := s.data.scenes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &scene, nil
+}
+
+func (s *MemoryVideoStore) ListScenes(ctx context.Context, videoID int) ([]models.VideoScene, error) {
+	defer s.lock()()
+	var scenes []models.VideoScene
+	for _, scene := range s.data.scenes {
+		if scene.VideoID == videoID {
+			scenes = append(scenes, scene)
+		}
+	}
+	sort.Slice(scenes, func(i, j int) bool { return scenes[i].Index < scenes[j].Index })
+	return scenes, nil
+}
+
+func (s *MemoryVideoStore) UpsertScene(ctx context.Context, scene *models.VideoScene) error {
+	defer s.lock()()
+	for _, existing := range s.data.scenes {
+		if existing.VideoID == scene.VideoID && existing.Index == scene.Index {
+			scene.ID = existing.ID
+			return nil
+		}
+	}
+	scene.ID = s.newID()
+	now := time.Now().UTC()
+	scene.CreatedAt, scene.UpdatedAt = now, now
+	s.data.scenes[scene.ID] = *scene
+	return nil
+}
+
+// updateScene applies update to the scene with id if it exists
+func (s *MemoryVideoStore) updateScene(id int, update func(*models.VideoScene)) {
+	defer s.lock()()
+	scene, ok := s.data.scenes[id]
+	if !ok {
+		return
+	}
+	update(&scene)
+	scene.UpdatedAt = time.Now().UTC()
+	s.data.scenes[id] = scene
+}
+
+func (s *MemoryVideoStore) SetSceneStatus(ctx context.Context, id int, status string) error {
+	s.updateScene(id, func(scene *models.VideoScene) { scene.Status = status })
+	return nil
+}
+
+func (s *MemoryVideoStore) SaveSceneImage(ctx context.Context, id int, bucket, key string) error {
+	s.updateScene(id, func(scene *models.VideoScene) {
+		scene.ImageBucket, scene.ImageKey, scene.ImageURL = &bucket, &key, nil
+	})
+	return nil
+}
+
+func (s *MemoryVideoStore) CreatePipelineStep(ctx context.Context, step *models.PipelineStep) error {
+	defer s.lock()()
+	step.ID = s.newID()
+	step.CreatedAt = time.Now().UTC()
+	s.data.steps = append(s.data.steps, *step)
+	return nil
+}
+
+func (s *MemoryVideoStore) FinishPipelineStep(ctx context.Context, id int, outcome models.PipelineStepOutcome, stepErr *string) error {
+	defer s.lock()()
+	for i := range s.data.steps {
+		if s.data.steps[i].ID == id {
+			now := time.Now().UTC()
+			s.data.steps[i].Outcome = outcome
+			s.data.steps[i].Error = stepErr
+			s.data.steps[i].FinishedAt = &now
+		}
+	}
+	return nil
+}
+
+func (s *MemoryVideoStore) LatestPipelineStep(ctx context.Context, videoID int) (*models.PipelineStep, error) {
+	defer s.lock()()
+	var latest *models.PipelineStep
+	for i := range s.data.steps {
+		step := s.data.steps[i]
+		if step.VideoID != videoID {
+			continue
+		}
+		if latest == nil || step.StartedAt.After(latest.StartedAt) || (step.StartedAt.Equal(latest.StartedAt) && step.ID > latest.ID) {
+			latest = &step
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	return latest, nil
+}
+
+func (s *MemoryVideoStore) CountPipelineSteps(ctx context.Context, videoID int, step string, outcome models.PipelineStepOutcome, since time.Time) (int64, error) {
+	defer s.lock()()
+	var count int64
+	for _, recorded := range s.data.steps {
+		if recorded.VideoID == videoID && recorded.Step == step && recorded.Outcome == outcome && !recorded.StartedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *MemoryVideoStore) WriteOutbox(ctx context.Context, taskType string, payload interface{}) error {
+	task, err := queue.NewOutboxTask(ctx, taskType, payload)
+	if err != nil {
+		return err
+	}
+	defer s.lock()()
+	task.ID = int64(len(s.data.outbox) + 1)
+	s.data.outbox = append(s.data.outbox, *task)
+	return nil
+}
+
+func (s *MemoryVideoStore) CountPendingOutboxTasks(ctx context.Context, videoID int, sceneIDs []int) (int, error) {
+	defer s.lock()()
+	var pending []queue.OutboxTask
+	for _, task := range s.data.outbox {
+		if task.DispatchedAt == nil {
+			pending = append(pending, task)
+		}
+	}
+	return countVideoTasks(pending, videoID, sceneIDs), nil
+}
@@ -0,0 +1,104 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+)
+
+var (
+	// ErrNotFound is returned when a video, scene or pipeline step does not exist
+	ErrNotFound = errors.New("repository: record not found")
+	// ErrIllegalTransition is returned for a status change the transition table
+	// in pkg/models doesn't allow
+	ErrIllegalTransition = errors.New("repository: illegal video status transition")
+	// ErrStatusChanged is returned when a video is no longer in the status a
+	// transition expected it in, because another writer moved it first
+	ErrStatusChanged = errors.New("repository: video status changed")
+)
+
+// VideoStore reads and writes videos, their scenes and their pipeline history.
+// Reads include deleted videos, so callers can tell them from missing ones;
+// writes never touch a deleted video. Every status change is checked against
+// the transition table in pkg/models, applied only if the video is still in
+// the status the caller saw, and recorded with its reason.
+type VideoStore interface {
+	// Transaction runs fn with a store whose writes, outbox tasks included,
+	// commit together when fn returns nil
+	Transaction(ctx context.Context, fn func(tx VideoStore) error) error
+
+	// GetVideo returns a video without its scenes
+	GetVideo(ctx context.Context, id int) (*models.Video, error)
+	// ListVideosInStatus returns the videos in any of statuses, oldest first
+	ListVideosInStatus(ctx context.Context, statuses ...models.VideoStatus) ([]models.Video, error)
+	// Transition moves a video from one status to another and records reason.
+	// It returns ErrIllegalTransition for a move the table doesn't allow and
+	// ErrStatusChanged when the video is no longer in from. A video already in
+	// to is left as is, so a redelivered task can make the same move again.
+	Transition(ctx context.Context, id int, from, to models.VideoStatus, reason string) error
+	// MarkVideoFailed moves a video to failed with reason. When from is given the
+	// video must be in one of those statuses, otherwise in any status that may
+	// fail; it reports whether the video moved.
+	MarkVideoFailed(ctx context.Context, id int, reason string, from ...models.VideoStatus) (bool, error)
+	// CancelVideo moves a video to cancelled from any status that may be
+	// cancelled, reporting whether it moved
+	CancelVideo(ctx context.Context, id int, reason string) (bool, error)
+	// SaveScript records the generated script of a video
+	SaveScript(ctx context.Context, id int, script string) error
+	// SaveAudio records where the audio of a video is stored
+	SaveAudio(ctx context.Context, id int, bucket, key string) error
+	// SaveCaptions records the captions of a video as a JSON array of words
+	SaveCaptions(ctx context.Context, id int, captions string) error
+	// CompleteVideo records the rendered video and moves it from rendering to
+	// completed. Like Transition it returns ErrStatusChanged when the video is no
+	// longer rendering, unless it is already completed.
+	CompleteVideo(ctx context.Context, id int, videoURL string) error
+	// ClaimRender moves a video from generating_images to ready_to_render when
+	// its captions exist and every scene has a completed image. Exactly one of
+	// several concurrent callers gets true.
+	ClaimRender(ctx context.Context, id int) (bool, error)
+	// ListStatusTransitions returns the status changes of a video in order
+	ListStatusTransitions(ctx context.Context, videoID int) ([]models.VideoStatusTransition, error)
+
+	// GetScene returns a scene
+	GetScene(ctx context.Context, id int) (*models.VideoScene, error)
+	// ListScenes returns the scenes of a video in order
+	ListScenes(ctx context.Context, videoID int) ([]models.VideoScene, error)
+	// UpsertScene creates scene and sets its ID. When the video already has a
+	// scene at its index, that scene is kept and its ID is set instead.
+	UpsertScene(ctx context.Context, scene *models.VideoScene) error
+	// SetSceneStatus moves a scene to status
+	SetSceneStatus(ctx context.Context, id int, status string) error
+	// SaveSceneImage records where the image of a scene is stored
+	SaveSceneImage(ctx context.Context, id int, bucket, key string) error
+
+	// CreatePipelineStep records a pipeline step attempt and sets its ID
+	CreatePipelineStep(ctx context.Context, step *models.PipelineStep) error
+	// FinishPipelineStep records the outcome of a pipeline step attempt
+	FinishPipelineStep(ctx context.Context, id int, outcome models.PipelineStepOutcome, stepErr *string) error
+	// LatestPipelineStep returns the last started pipeline step of a video
+	LatestPipelineStep(ctx context.Context, videoID int) (*models.PipelineStep, error)
+	// CountPipelineSteps counts the attempts of step on a video with outcome
+	// that started at or after since
+	CountPipelineSteps(ctx context.Context, videoID int, step string, outcome models.PipelineStepOutcome, since time.Time) (int64, error)
+
+	// WriteOutbox adds a task to the outbox, committed with the surrounding transaction
+	WriteOutbox(ctx context.Context, taskType string, payload interface{}) error
+	// CountPendingOutboxTasks counts the outbox tasks of a video or of its
+	// scenes in sceneIDs that haven't been handed to asynq yet
+	CountPendingOutboxTasks(ctx context.Context, videoID int, sceneIDs []int) (int, error)
+}
+
+// countVideoTasks counts the tasks that work on videoID or one of sceneIDs
+func countVideoTasks(tasks []queue.OutboxTask, videoID int, sceneIDs []int) int {
+	count := 0
+	for _, task := range tasks {
+		if task.BelongsToVideo(videoID, sceneIDs) {
+			count++
+		}
+	}
+	return count
+}
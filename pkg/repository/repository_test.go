@@ -0,0 +1,516 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"instashorts-be/pkg/models"
+	"instashorts-be/pkg/queue"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func newTestGormStore(t *testing.T) *GormVideoStore {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Video{}, &models.VideoScene{}, &models.PipelineStep{}, &models.VideoStatusTransition{}, &queue.OutboxTask{}); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	return NewGormVideoStore(db)
+}
+
+// forEachStore runs test against every VideoStore, each seeded with videos and scenes
+func forEachStore(t *testing.T, videos []models.Video, scenes []models.VideoScene, test func(t *testing.T, store VideoStore)) {
+	t.Run("gorm", func(t *testing.T) {
+		store := newTestGormStore(t)
+		for _, video := range videos {
+			if err := store.db.Create(&video).Error; err != nil {
+				t.Fatalf("Failed to create video: %v", err)
+			}
+		}
+		for _, scene := range scenes {
+			if err := store.db.Create(&scene).Error; err != nil {
+				t.Fatalf("Failed to create scene: %v", err)
+			}
+		}
+		test(t, store)
+	})
+	t.Run("memory", func(t *testing.T) {
+		store := NewMemoryVideoStore()
+		for _, video := range videos {
+			store.AddVideo(video)
+		}
+		for _, scene := range scenes {
+			store.AddScene(scene)
+		}
+		test(t, store)
+	})
+}
+
+// outboxTasks returns what a store wrote to the outbox
+func outboxTasks(t *testing.T, store VideoStore) []queue.OutboxTask {
+	t.Helper()
+	switch s := store.(type) {
+	case *GormVideoStore:
+		var tasks []queue.OutboxTask
+		if err := s.db.Order("id").Find(&tasks).Error; err != nil {
+			t.Fatalf("Failed to fetch outbox: %v", err)
+		}
+		return tasks
+	case *MemoryVideoStore:
+		return s.OutboxTasks()
+	}
+	t.Fatalf("Unknown store %T", store)
+	return nil
+}
+
+func getVideo(t *testing.T, store VideoStore, id int) *models.Video {
+	t.Helper()
+	video, err := store.GetVideo(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetVideo returned error: %v", err)
+	}
+	return video
+}
+
+func TestClaimRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		video    models.Video
+		scenes   []models.VideoScene
+		expected bool
+	}{
+		{
+			name:     "all prerequisites met",
+			video:    models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed", ImageKey: strPtr("images/1/a.png")}},
+			expected: true,
+		},
+		{
+			name:     "image stored before keys were recorded",
+			video:    models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed", ImageURL: strPtr("https://example.com/a.png")}},
+			expected: true,
+		},
+		{
+			name:     "completed scene without image",
+			video:    models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed"}},
+			expected: false,
+		},
+		{
+			name:     "captions missing",
+			video:    models.Video{ID: 1, Status: "generating_images"},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed", ImageKey: strPtr("images/1/a.png")}},
+			expected: false,
+		},
+		{
+			name:  "scene still generating",
+			video: models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")},
+			scenes: []models.VideoScene{
+				{VideoID: 1, Index: 0, Status: "completed", ImageKey: strPtr("images/1/a.png")},
+				{VideoID: 1, Index: 1, Status: "generating"},
+			},
+			expected: false,
+		},
+		{
+			name:     "no scenes yet",
+			video:    models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")},
+			expected: false,
+		},
+		{
+			name:     "scene branch not finished",
+			video:    models.Video{ID: 1, Status: "generating_scenes", Captions: strPtr("[]")},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed", ImageKey: strPtr("images/1/a.png")}},
+			expected: false,
+		},
+		{
+			name:     "already claimed",
+			video:    models.Video{ID: 1, Status: "ready_to_render", Captions: strPtr("[]")},
+			scenes:   []models.VideoScene{{VideoID: 1, Status: "completed", ImageKey: strPtr("images/1/a.png")}},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			forEachStore(t, []models.Video{tt.video}, tt.scenes, func(t *testing.T, store VideoStore) {
+				claimed, err := store.ClaimRender(context.Background(), tt.video.ID)
+				if err != nil {
+					t.Fatalf("ClaimRender returned error: %v", err)
+				}
+				if claimed != tt.expected {
+					t.Errorf("Expected claimed=%v, got %v", tt.expected, claimed)
+				}
+			})
+		})
+	}
+}
+
+func TestClaimRenderConcurrent(t *testing.T) {
+	const sceneCount = 8
+	scenes := make([]models.VideoScene, sceneCount)
+	for i := range scenes {
+		scenes[i] = models.VideoScene{VideoID: 1, Index: i, Status: "completed", ImageKey: strPtr("images/1/a.png")}
+	}
+	video := models.Video{ID: 1, Status: "generating_images", Captions: strPtr("[]")}
+
+	forEachStore(t, []models.Video{video}, scenes, func(t *testing.T, store VideoStore) {
+		// Every scene image handler and the captions handler finish at once
+		var wg sync.WaitGroup
+		var claims atomic.Int32
+		start := make(chan struct{})
+		for i := 0; i < sceneCount+1; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				claimed, err := store.ClaimRender(context.Background(), 1)
+				if err != nil {
+					t.Errorf("ClaimRender returned error: %v", err)
+					return
+				}
+				if claimed {
+					claims.Add(1)
+				}
+			}()
+		}
+		close(start)
+		wg.Wait()
+
+		if got := claims.Load(); got != 1 {
+			t.Errorf("Expected exactly one caller to claim the render, got %d", got)
+		}
+		if video := getVideo(t, store, 1); video.Status != models.VideoStatusReadyToRender {
+			t.Errorf("Expected status ready_to_render, got %s", video.Status)
+		}
+		if transitions, err := store.ListStatusTransitions(context.Background(), 1); err != nil || len(transitions) != 1 {
+			t.Errorf("Expected the claim to be recorded once, got %+v (%v)", transitions, err)
+		}
+	})
+}
+
+func TestTransition(t *testing.T) {
+	videos := []models.Video{
+		{ID: 1, Status: models.VideoStatusGeneratingScript},
+		{ID: 2, Status: models.VideoStatusCancelled},
+		{ID: 3, Status: models.VideoStatusFailed, FailureReason: strPtr("provider down")},
+	}
+	forEachStore(t, videos, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		if err := store.Transition(ctx, 1, models.VideoStatusGeneratingScript, models.VideoStatusCompleted, "skip ahead"); !errors.Is(err, ErrIllegalTransition) {
+			t.Errorf("Expected ErrIllegalTransition, got %v", err)
+		}
+		if err := store.Transition(ctx, 1, models.VideoStatusGeneratingScript, models.VideoStatusGeneratingAudio, "script saved"); err != nil {
+			t.Fatalf("Transition returned error: %v", err)
+		}
+		// A redelivered task makes the same move again
+		if err := store.Transition(ctx, 1, models.VideoStatusGeneratingScript, models.VideoStatusGeneratingAudio, "script saved"); err != nil {
+			t.Errorf("Expected a repeated transition to succeed, got %v", err)
+		}
+		if err := store.Transition(ctx, 2, models.VideoStatusPending, models.VideoStatusGeneratingScript, "script started"); !errors.Is(err, ErrStatusChanged) {
+			t.Errorf("Expected ErrStatusChanged for a cancelled video, got %v", err)
+		}
+		if err := store.Transition(ctx, 4, models.VideoStatusPending, models.VideoStatusGeneratingScript, "script started"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a missing video, got %v", err)
+		}
+		if err := store.Transition(ctx, 3, models.VideoStatusFailed, models.VideoStatusGeneratingAudio, "retried"); err != nil {
+			t.Fatalf("Transition returned error: %v", err)
+		}
+
+		expected := map[int]models.VideoStatus{
+			1: models.VideoStatusGeneratingAudio,
+			2: models.VideoStatusCancelled,
+			3: models.VideoStatusGeneratingAudio,
+		}
+		for id, status := range expected {
+			if video := getVideo(t, store, id); video.Status != status {
+				t.Errorf("Video %d: expected status %s, got %s", id, status, video.Status)
+			}
+		}
+		if video := getVideo(t, store, 3); video.FailureReason != nil {
+			t.Errorf("Expected a retried video to lose its failure reason, got %q", *video.FailureReason)
+		}
+
+		transitions, err := store.ListStatusTransitions(ctx, 1)
+		if err != nil || len(transitions) != 1 {
+			t.Fatalf("Expected one recorded transition, got %+v (%v)", transitions, err)
+		}
+		if got := transitions[0]; got.FromStatus != models.VideoStatusGeneratingScript || got.ToStatus != models.VideoStatusGeneratingAudio || got.Reason != "script saved" {
+			t.Errorf("Expected the script transition to be recorded, got %+v", got)
+		}
+	})
+}
+
+func TestMarkVideoFailed(t *testing.T) {
+	videos := []models.Video{
+		{ID: 1, Status: models.VideoStatusGeneratingAudio},
+		{ID: 2, Status: models.VideoStatusCancelled},
+		{ID: 3, Status: models.VideoStatusGeneratingImages},
+		{ID: 4, Status: models.VideoStatusCompleted},
+	}
+	forEachStore(t, videos, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		for _, id := range []int{2, 4} {
+			if failed, err := store.MarkVideoFailed(ctx, id, "too late"); err != nil || failed {
+				t.Errorf("Video %d: expected a finished video not to fail, got %v (%v)", id, failed, err)
+			}
+		}
+		if failed, err := store.MarkVideoFailed(ctx, 3, "stuck", models.VideoStatusRendering); err != nil || failed {
+			t.Errorf("Expected a video in another status not to fail, got %v (%v)", failed, err)
+		}
+		if failed, err := store.MarkVideoFailed(ctx, 1, "provider down"); err != nil || !failed {
+			t.Fatalf("Expected the video to fail, got %v (%v)", failed, err)
+		}
+
+		expected := map[int]models.VideoStatus{
+			1: models.VideoStatusFailed,
+			2: models.VideoStatusCancelled,
+			3: models.VideoStatusGeneratingImages,
+			4: models.VideoStatusCompleted,
+		}
+		for id, status := range expected {
+			if video := getVideo(t, store, id); video.Status != status {
+				t.Errorf("Video %d: expected status %s, got %s", id, status, video.Status)
+			}
+		}
+		if video := getVideo(t, store, 1); video.FailureReason == nil || *video.FailureReason != "provider down" {
+			t.Errorf("Expected the failure reason to be recorded, got %v", video.FailureReason)
+		}
+		transitions, err := store.ListStatusTransitions(ctx, 1)
+		if err != nil || len(transitions) != 1 || transitions[0].FromStatus != models.VideoStatusGeneratingAudio || transitions[0].Reason != "provider down" {
+			t.Errorf("Expected the failure to be recorded, got %+v (%v)", transitions, err)
+		}
+	})
+}
+
+func TestCancelVideo(t *testing.T) {
+	videos := []models.Video{
+		{ID: 1, Status: models.VideoStatusFailed, FailureReason: strPtr("provider down")},
+		{ID: 2, Status: models.VideoStatusCompleted},
+		{ID: 3, Status: models.VideoStatusRendering},
+	}
+	forEachStore(t, videos, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		expected := map[int]bool{1: true, 2: false, 3: true}
+		for id, cancellable := range expected {
+			if cancelled, err := store.CancelVideo(ctx, id, "cancelled by the user"); err != nil || cancelled != cancellable {
+				t.Errorf("Video %d: expected cancelled=%v, got %v (%v)", id, cancellable, cancelled, err)
+			}
+		}
+		if cancelled, err := store.CancelVideo(ctx, 3, "cancelled by the user"); err != nil || cancelled {
+			t.Errorf("Expected a second cancellation to do nothing, got %v (%v)", cancelled, err)
+		}
+		if video := getVideo(t, store, 1); video.Status != models.VideoStatusCancelled || deref(video.FailureReason) != "provider down" {
+			t.Errorf("Expected the failed video to be cancelled with its failure reason, got %+v", video)
+		}
+	})
+}
+
+func TestCompleteVideo(t *testing.T) {
+	videos := []models.Video{
+		{ID: 1, Status: models.VideoStatusRendering},
+		{ID: 2, Status: models.VideoStatusCancelled},
+	}
+	forEachStore(t, videos, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		for i := 0; i < 2; i++ {
+			if err := store.CompleteVideo(ctx, 1, "https://example.com/1.mp4"); err != nil {
+				t.Fatalf("CompleteVideo returned error: %v", err)
+			}
+		}
+		video := getVideo(t, store, 1)
+		if video.Status != models.VideoStatusCompleted || video.VideoURL == nil || *video.VideoURL != "https://example.com/1.mp4" || video.CompletedAt.IsZero() {
+			t.Errorf("Expected the video to be completed, got %+v", video)
+		}
+
+		if err := store.CompleteVideo(ctx, 2, "https://example.com/2.mp4"); !errors.Is(err, ErrStatusChanged) {
+			t.Errorf("Expected ErrStatusChanged for a cancelled video, got %v", err)
+		}
+		if video := getVideo(t, store, 2); video.Status != models.VideoStatusCancelled || video.VideoURL != nil {
+			t.Errorf("Expected the cancelled video to stay as is, got %+v", video)
+		}
+	})
+}
+
+func TestDeletedVideos(t *testing.T) {
+	deleted := models.Video{ID: 1, Status: models.VideoStatusGeneratingAudio, DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}
+	forEachStore(t, []models.Video{deleted}, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		if err := store.Transition(ctx, 1, models.VideoStatusGeneratingAudio, models.VideoStatusGeneratingScenes, "scenes started"); !errors.Is(err, ErrStatusChanged) {
+			t.Errorf("Expected ErrStatusChanged for a deleted video, got %v", err)
+		}
+		video := getVideo(t, store, 1)
+		if !video.DeletedAt.Valid || video.Status != models.VideoStatusGeneratingAudio {
+			t.Errorf("Expected the deleted video to be read but not written, got %+v", video)
+		}
+
+		videos, err := store.ListVideosInStatus(ctx, models.VideoStatusGeneratingAudio)
+		if err != nil || len(videos) != 0 {
+			t.Errorf("Expected deleted videos not to be listed, got %v (%v)", videos, err)
+		}
+		if _, err := store.GetVideo(ctx, 2); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound for a missing video, got %v", err)
+		}
+	})
+}
+
+func TestUpsertSceneKeepsExistingScene(t *testing.T) {
+	existing := models.VideoScene{ID: 5, VideoID: 1, Index: 0, Prompt: "a reef", Status: "completed"}
+	forEachStore(t, []models.Video{{ID: 1}}, []models.VideoScene{existing}, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		again := models.VideoScene{VideoID: 1, Index: 0, Prompt: "another reef", Status: "pending"}
+		if err := store.UpsertScene(ctx, &again); err != nil {
+			t.Fatalf("UpsertScene returned error: %v", err)
+		}
+		next := models.VideoScene{VideoID: 1, Index: 1, Prompt: "an octopus", Status: "pending"}
+		if err := store.UpsertScene(ctx, &next); err != nil {
+			t.Fatalf("UpsertScene returned error: %v", err)
+		}
+
+		if again.ID != existing.ID {
+			t.Errorf("Expected the existing scene %d, got %d", existing.ID, again.ID)
+		}
+		scenes, err := store.ListScenes(ctx, 1)
+		if err != nil || len(scenes) != 2 {
+			t.Fatalf("Expected 2 scenes, got %v (%v)", scenes, err)
+		}
+		if scenes[0].Status != "completed" || scenes[0].Prompt != "a reef" || scenes[1].ID != next.ID {
+			t.Errorf("Expected the existing scene to be kept and the new one added, got %+v", scenes)
+		}
+	})
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	forEachStore(t, []models.Video{{ID: 1, Status: models.VideoStatusGeneratingScript}}, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		failure := errors.New("outbox unavailable")
+		err := store.Transaction(ctx, func(tx VideoStore) error {
+			if err := tx.SaveScript(ctx, 1, "script"); err != nil {
+				return err
+			}
+			if err := tx.WriteOutbox(ctx, queue.TypeGenerateAudio, queue.GenerateAudioPayload{VideoID: 1}); err != nil {
+				return err
+			}
+			return failure
+		})
+		if !errors.Is(err, failure) {
+			t.Fatalf("Expected the transaction's error, got %v", err)
+		}
+		if video := getVideo(t, store, 1); video.Script != nil {
+			t.Errorf("Expected the script to roll back, got %q", *video.Script)
+		}
+		if tasks := outboxTasks(t, store); len(tasks) != 0 {
+			t.Errorf("Expected the outbox write to roll back, got %+v", tasks)
+		}
+
+		if err := store.Transaction(ctx, func(tx VideoStore) error {
+			return tx.WriteOutbox(queue.WithTraceID(ctx, "trace-1"), queue.TypeGenerateAudio, queue.GenerateAudioPayload{VideoID: 1})
+		}); err != nil {
+			t.Fatalf("Transaction returned error: %v", err)
+		}
+		tasks := outboxTasks(t, store)
+		if len(tasks) != 1 {
+			t.Fatalf("Expected one outbox task, got %+v", tasks)
+		}
+		var payload queue.GenerateAudioPayload
+		meta, err := queue.DecodePayload(tasks[0].Payload, &payload)
+		if err != nil || payload.VideoID != 1 || meta.TraceID != "trace-1" {
+			t.Errorf("Expected the audio task of video 1 in trace-1, got %+v %+v (%v)", payload, meta, err)
+		}
+	})
+}
+
+func TestPipelineSteps(t *testing.T) {
+	forEachStore(t, []models.Video{{ID: 1}}, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		if _, err := store.LatestPipelineStep(ctx, 1); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound before any step, got %v", err)
+		}
+
+		started := time.Now().UTC().Add(-time.Minute)
+		first := models.PipelineStep{VideoID: 1, Step: queue.TypeGenerateAudio, Attempt: 1, Outcome: models.PipelineStepRunning, StartedAt: started}
+		second := models.PipelineStep{VideoID: 1, Step: queue.TypeGenerateAudio, Attempt: 2, Outcome: models.PipelineStepRunning, StartedAt: started.Add(time.Second)}
+		for _, step := range []*models.PipelineStep{&first, &second} {
+			if err := store.CreatePipelineStep(ctx, step); err != nil {
+				t.Fatalf("CreatePipelineStep returned error: %v", err)
+			}
+		}
+		if err := store.FinishPipelineStep(ctx, first.ID, models.PipelineStepFailed, strPtr("timeout")); err != nil {
+			t.Fatalf("FinishPipelineStep returned error: %v", err)
+		}
+		if err := store.FinishPipelineStep(ctx, second.ID, models.PipelineStepSucceeded, nil); err != nil {
+			t.Fatalf("FinishPipelineStep returned error: %v", err)
+		}
+
+		latest, err := store.LatestPipelineStep(ctx, 1)
+		if err != nil || latest.ID != second.ID || latest.FinishedAt == nil || latest.Outcome != models.PipelineStepSucceeded {
+			t.Errorf("Expected the finished second attempt, got %+v (%v)", latest, err)
+		}
+		if count, err := store.CountPipelineSteps(ctx, 1, queue.TypeGenerateAudio, models.PipelineStepFailed, time.Time{}); err != nil || count != 1 {
+			t.Errorf("Expected one failed attempt, got %d (%v)", count, err)
+		}
+		if count, err := store.CountPipelineSteps(ctx, 1, queue.TypeGenerateAudio, models.PipelineStepSucceeded, started.Add(time.Second)); err != nil || count != 1 {
+			t.Errorf("Expected the second attempt to count from when it started, got %d (%v)", count, err)
+		}
+		if count, err := store.CountPipelineSteps(ctx, 1, queue.TypeGenerateAudio, models.PipelineStepFailed, started.Add(time.Second)); err != nil || count != 0 {
+			t.Errorf("Expected the earlier failed attempt not to count, got %d (%v)", count, err)
+		}
+	})
+}
+
+func TestCountPendingOutboxTasks(t *testing.T) {
+	forEachStore(t, []models.Video{{ID: 1}, {ID: 2}}, nil, func(t *testing.T, store VideoStore) {
+		ctx := context.Background()
+		writes := []struct {
+			taskType string
+			payload  interface{}
+		}{
+			{queue.TypeGenerateAudio, queue.GenerateAudioPayload{VideoID: 1}},
+			{queue.TypeGenerateSceneImage, queue.GenerateSceneImagePayload{SceneID: 10}},
+			{queue.TypeGenerateSceneImage, queue.GenerateSceneImagePayload{SceneID: 20}},
+			{queue.TypeGenerateAudio, queue.GenerateAudioPayload{VideoID: 2}},
+		}
+		for _, write := range writes {
+			if err := store.WriteOutbox(ctx, write.taskType, write.payload); err != nil {
+				t.Fatalf("WriteOutbox returned error: %v", err)
+			}
+		}
+
+		if count, err := store.CountPendingOutboxTasks(ctx, 1, []int{10}); err != nil || count != 2 {
+			t.Errorf("Expected the audio and scene image tasks of video 1, got %d (%v)", count, err)
+		}
+
+		// Dispatched tasks are in asynq already
+		if gorm, ok := store.(*GormVideoStore); ok {
+			if err := gorm.db.Model(&queue.OutboxTask{}).Where("id = ?", 1).Update("dispatched_at", time.Now().UTC()).Error; err != nil {
+				t.Fatalf("Failed to dispatch task: %v", err)
+			}
+			if count, err := store.CountPendingOutboxTasks(ctx, 1, []int{10}); err != nil || count != 1 {
+				t.Errorf("Expected only the scene image task to be pending, got %d (%v)", count, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"instashorts-be/pkg/models"
+)
+
+// maxTransitionAttempts is how often a move from any of several statuses reads
+// the video again after another writer moved it first
+const maxTransitionAttempts = 3
+
+// statusChange is a status transition and the fields that change with it
+type statusChange struct {
+	from, to models.VideoStatus
+	reason   string
+	// videoURL is recorded when the video moves to completed
+	videoURL string
+}
+
+// retries reports whether the change resumes a failed video
+func (c statusChange) retries() bool {
+	return c.from == models.VideoStatusFailed && c.to != models.VideoStatusCancelled
+}
+
+// renderClaim is the transition ClaimRender makes
+var renderClaim = statusChange{
+	from:   models.VideoStatusGeneratingImages,
+	to:     models.VideoStatusReadyToRender,
+	reason: "captions and every scene image are ready",
+}
+
+// renderCompletion is the transition CompleteVideo makes
+func renderCompletion(videoURL string) statusChange {
+	return statusChange{
+		from:     models.VideoStatusRendering,
+		to:       models.VideoStatusCompleted,
+		reason:   "video rendered",
+		videoURL: videoURL,
+	}
+}
+
+// statusSwapper is implemented by each store. swapStatus applies a change if
+// the video still exists in change.from, records it and reports whether it did.
+// Moving to failed sets the failure reason, and retrying a failed video clears it.
+type statusSwapper interface {
+	GetVideo(ctx context.Context, id int) (*models.Video, error)
+	swapStatus(ctx context.Context, id int, change statusChange) (bool, error)
+}
+
+// statusMover is implemented by each store. moveStatus applies a guarded
+// status change the way Transition does and reports whether the video moved,
+// which it doesn't when it was already in change.to.
+type statusMover interface {
+	moveStatus(ctx context.Context, id int, change statusChange) (bool, error)
+}
+
+// transition applies a single guarded status change and reports whether the
+// video moved. A video already in change.to stays put without an error.
+func transition(ctx context.Context, s statusSwapper, id int, change statusChange) (bool, error) {
+	if !models.CanTransition(change.from, change.to) {
+		return false, fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, change.from, change.to)
+	}
+
+	moved, err := s.swapStatus(ctx, id, change)
+	if err != nil {
+		return false, fmt.Errorf("failed to update video status: %w", err)
+	}
+	if moved {
+		return true, nil
+	}
+
+	video, err := s.GetVideo(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if !video.DeletedAt.Valid && video.Status == change.to {
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: video %d is %s, not %s", ErrStatusChanged, id, video.Status, change.from)
+}
+
+// transitionFromAny moves a video to `to` from whichever of from it is in,
+// reporting whether it moved. A video in none of them, or deleted, stays put.
+func transitionFromAny(ctx context.Context, s statusSwapper, id int, from []models.VideoStatus, to models.VideoStatus, reason string) (bool, error) {
+	for attempt := 0; attempt < maxTransitionAttempts; attempt++ {
+		video, err := s.GetVideo(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if video.DeletedAt.Valid || !slices.Contains(from, video.Status) {
+			return false, nil
+		}
+		if !models.CanTransition(video.Status, to) {
+			return false, fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, video.Status, to)
+		}
+
+		moved, err := s.swapStatus(ctx, id, statusChange{from: video.Status, to: to, reason: reason})
+		if err != nil {
+			return false, fmt.Errorf("failed to update video status: %w", err)
+		}
+		if moved {
+			return true, nil
+		}
+	}
+	return false, fmt.Errorf("%w: video %d kept changing status", ErrStatusChanged, id)
+}
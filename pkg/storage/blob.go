@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBackend is used when STORAGE_BACKEND is not set
+const DefaultBackend = "gcs"
+
+// DefaultSignedURLTTL is used when SIGNED_URL_TTL is not set
+const DefaultSignedURLTTL = time.Hour
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	Updated     time.Time
+}
+
+// BlobStore stores media objects under slash-separated keys. Objects are
+// streamed in and out, so memory use doesn't grow with object size.
+type BlobStore interface {
+	// Bucket names the bucket objects are stored in; empty for the local backend
+	Bucket() string
+	// Put stores everything read from r under key; an empty r is an error
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the object at key; the caller must close the reader
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	// SignURL returns a URL that grants read access to key until expires has passed
+	SignURL(ctx context.Context, key string, expires time.Duration) (string, error)
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+}
+
+// ErrEmptyObject is returned when Put is given a reader with no data
+var ErrEmptyObject = errors.New("storage: data cannot be empty")
+
+// nonEmpty returns a reader equivalent to r, or ErrEmptyObject if r has no data.
+// Only the first byte is read ahead, so the rest of r is still streamed.
+func nonEmpty(r io.Reader) (io.Reader, error) {
+	first := make([]byte, 1)
+	if _, err := io.ReadFull(r, first); err == io.EOF {
+		return nil, ErrEmptyObject
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read data: %w", err)
+	}
+	return io.MultiReader(bytes.NewReader(first), r), nil
+}
+
+// SignObject signs a read URL for an object recorded with its bucket and key.
+// It refuses objects that were stored in a different bucket than store's.
+func SignObject(ctx context.Context, store BlobStore, bucket string, key string, expires time.Duration) (string, error) {
+	if bucket != store.Bucket() {
+		return "", fmt.Errorf("object %q is in bucket %q, but storage is configured for bucket %q", key, bucket, store.Bucket())
+	}
+	return store.SignURL(ctx, key, expires)
+}
+
+// SignedURLTTLFromEnv returns how long signed URLs stay valid, from SIGNED_URL_TTL
+// (a Go duration such as "30m")
+func SignedURLTTLFromEnv() (time.Duration, error) {
+	value := os.Getenv("SIGNED_URL_TTL")
+	if value == "" {
+		return DefaultSignedURLTTL, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("invalid SIGNED_URL_TTL %q", value)
+	}
+	return ttl, nil
+}
+
+// BlobStoreFactory creates a BlobStore from its environment configuration
+type BlobStoreFactory func(ctx context.Context) (BlobStore, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BlobStoreFactory{}
+)
+
+// RegisterBackend makes a BlobStore backend available under name
+func RegisterBackend(name string, factory BlobStoreFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	if _, exists := backends[name]; exists {
+		panic(fmt.Sprintf("storage: backend %q registered twice", name))
+	}
+	backends[name] = factory
+}
+
+// Backends returns the names of every registered backend
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewBlobStore creates the BlobStore backend registered under name
+func NewBlobStore(ctx context.Context, name string) (BlobStore, error) {
+	backendsMu.RLock()
+	factory, ok := backends[name]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q (available: %s)", name, strings.Join(Backends(), ", "))
+	}
+
+	store, err := factory(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s storage backend: %w", name, err)
+	}
+	return store, nil
+}
+
+// Signer signs read URLs for objects recorded by bucket and key, with a fixed TTL
+type Signer struct {
+	store BlobStore
+	ttl   time.Duration
+}
+
+// NewSigner creates a Signer whose URLs stay valid for ttl
+func NewSigner(store BlobStore, ttl time.Duration) *Signer {
+	return &Signer{store: store, ttl: ttl}
+}
+
+// SignURL returns a fresh read URL for the object at bucket and key
+func (s *Signer) SignURL(ctx context.Context, bucket string, key string) (string, error) {
+	return SignObject(ctx, s.store, bucket, key, s.ttl)
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// gcsChunkSize is how much of an upload the GCS writer buffers before sending it.
+// The client default is 16 MiB per upload; a smaller chunk keeps memory flat
+// when many uploads run at once, at the cost of a few more requests.
+const gcsChunkSize = 1 << 20
+
+func init() {
+	RegisterBackend("gcs", func(ctx context.Context) (BlobStore, error) {
+		return NewGCSClient(ctx)
+	})
+}
+
+// GCSClient stores objects in a Google Cloud Storage bucket
+type GCSClient struct {
+	client *storage.Client
+	bucket string
+}
+
+// NewGCSClient creates a new GCS service
+func NewGCSClient(ctx context.Context) (*GCSClient, error) {
+	// Auth is automatic!
+	// The client will find the GOOGLE_APPLICATION_CREDENTIALS
+	// environment variable set by your docker-compose.yml.
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCS client: %w", err)
+	}
+
+	bucketName := os.Getenv("GCS_BUCKET_NAME")
+	if bucketName == "" {
+		return nil, fmt.Errorf("GCS_BUCKET_NAME environment variable not set")
+	}
+
+	return &GCSClient{
+		client: client,
+		bucket: bucketName,
+	}, nil
+}
+
+// Bucket returns the bucket objects are stored in
+func (c *GCSClient) Bucket() string {
+	return c.bucket
+}
+
+// SignURL generates a V4 signed URL for a GCS object.
+// This is the secure way to grant temporary access.
+func (c *GCSClient) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	opts := &storage.SignedURLOptions{
+		Scheme:  storage.SigningSchemeV4,
+		Method:  "GET",
+		Expires: time.Now().Add(expires),
+	}
+
+	url, err := c.client.Bucket(c.bucket).SignedURL(key, opts)
+	if err != nil {
+		return "", fmt.Errorf("Bucket.SignedURL: %w", err)
+	}
+	return url, nil
+}
+
+// Put streams r to key
+func (c *GCSClient) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	r, err := nonEmpty(r)
+	if err != nil {
+		return err
+	}
+
+	// Get a handle to the GCS object
+	obj := c.client.Bucket(c.bucket).Object(key)
+
+	// Cancelling the writer's context aborts the upload if the copy fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Create a writer
+	wc := obj.NewWriter(ctx)
+	wc.ContentType = contentType
+	wc.ChunkSize = gcsChunkSize
+
+	// Stream the data into the writer
+	if _, err := io.Copy(wc, r); err != nil {
+		cancel()
+		wc.Close()
+		return fmt.Errorf("io.Copy: %w", err)
+	}
+
+	// Close the writer to finalize the upload
+	if err := wc.Close(); err != nil {
+		return fmt.Errorf("Writer.Close: %w", err)
+	}
+	return nil
+}
+
+// Get opens the object at key for reading
+func (c *GCSClient) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	rc, err := c.client.Bucket(c.bucket).Object(key).NewReader(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Object.NewReader: %w", err)
+	}
+	return rc, nil
+}
+
+// Delete removes the object at key
+func (c *GCSClient) Delete(ctx context.Context, key string) error {
+	err := c.client.Bucket(c.bucket).Object(key).Delete(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("Object.Delete: %w", err)
+	}
+	return nil
+}
+
+// Stat returns the size, content type and modification time of the object at key
+func (c *GCSClient) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	attrs, err := c.client.Bucket(c.bucket).Object(key).Attrs(ctx)
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Object.Attrs: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:         key,
+		Size:        attrs.Size,
+		ContentType: attrs.ContentType,
+		Updated:     attrs.Updated,
+	}, nil
+}
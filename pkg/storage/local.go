@@ -0,0 +1,269 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func init() {
+	RegisterBackend("local", func(ctx context.Context) (BlobStore, error) {
+		return NewLocalStore()
+	})
+}
+
+// contentTypeSuffix names the sidecar file that records an object's content type
+const contentTypeSuffix = ".content-type"
+
+// LocalStore keeps objects on the local disk for development and CI.
+// Its signed URLs point at Handler, which must be served at baseURL.
+type LocalStore struct {
+	root    string
+	baseURL string
+	secret  []byte
+}
+
+// NewLocalStore creates a store from LOCAL_STORAGE_DIR, LOCAL_STORAGE_URL and
+// LOCAL_STORAGE_SECRET. Without a secret a random one is generated, so signed
+// URLs stop working when the process restarts.
+func NewLocalStore() (*LocalStore, error) {
+	root := os.Getenv("LOCAL_STORAGE_DIR")
+	if root == "" {
+		root = "./data/storage"
+	}
+
+	baseURL := os.Getenv("LOCAL_STORAGE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:5100/files"
+	}
+
+	secret := []byte(os.Getenv("LOCAL_STORAGE_SECRET"))
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, fmt.Errorf("failed to generate signing secret: %w", err)
+		}
+	}
+
+	return NewLocalStoreWithConfig(root, baseURL, secret)
+}
+
+// NewLocalStoreWithConfig creates a store rooted at root whose URLs start with baseURL
+func NewLocalStoreWithConfig(root string, baseURL string, secret []byte) (*LocalStore, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve storage directory: %w", err)
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return &LocalStore{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		secret:  secret,
+	}, nil
+}
+
+// Bucket returns an empty name; local objects are identified by key alone
+func (s *LocalStore) Bucket() string {
+	return ""
+}
+
+// path maps key to a file under root. Keys with empty, hidden or ".." segments
+// are rejected, which keeps them inside root and away from the sidecar files.
+func (s *LocalStore) path(key string) (string, error) {
+	segments := strings.Split(key, "/")
+	for _, segment := range segments {
+		if segment == "" || strings.HasPrefix(segment, ".") {
+			return "", fmt.Errorf("invalid object key %q", key)
+		}
+	}
+	return filepath.Join(s.root, filepath.Join(segments...)), nil
+}
+
+// contentTypePath returns the sidecar file for the object stored at path
+func contentTypePath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+contentTypeSuffix)
+}
+
+// Put streams r to key, replacing any existing object atomically
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	r, err = nonEmpty(r)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := writeFileAtomic(contentTypePath(path), strings.NewReader(contentType)); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, r)
+}
+
+// writeFileAtomic copies r to a temporary file and renames it over path
+func writeFileAtomic(path string, r io.Reader) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to rename file: %w", err)
+	}
+	return nil
+}
+
+// Get opens the object at key for reading
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	return f, nil
+}
+
+// Delete removes the object at key
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to remove file: %w", err)
+	}
+	os.Remove(contentTypePath(path))
+	return nil
+}
+
+// Stat returns the size, content type and modification time of the object at key
+func (s *LocalStore) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file: %w", err)
+	}
+
+	info := &ObjectInfo{
+		Key:         key,
+		Size:        fi.Size(),
+		ContentType: "application/octet-stream",
+		Updated:     fi.ModTime(),
+	}
+	if contentType, err := os.ReadFile(contentTypePath(path)); err == nil && len(contentType) > 0 {
+		info.ContentType = string(contentType)
+	}
+	return info, nil
+}
+
+// SignURL returns a Handler URL for key that stops working once expires has passed
+func (s *LocalStore) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+
+	expiresAt := strconv.FormatInt(time.Now().Add(expires).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expiresAt)
+	query.Set("signature", s.sign(key, expiresAt))
+
+	return s.baseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + query.Encode(), nil
+}
+
+// sign returns the hex HMAC of key and its expiry time
+func (s *LocalStore) sign(key string, expiresAt string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(key + "\n" + expiresAt))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// URLPath returns the path of the base URL, where Handler should be mounted
+func (s *LocalStore) URLPath() string {
+	u, err := url.Parse(s.baseURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// Handler serves objects to holders of a valid signed URL. Mount it at the path
+// of LOCAL_STORAGE_URL with that prefix stripped.
+func (s *LocalStore) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		key := strings.TrimPrefix(r.URL.Path, "/")
+		expiresAt := r.URL.Query().Get("expires")
+		expires, err := strconv.ParseInt(expiresAt, 10, 64)
+		if err != nil || time.Now().Unix() > expires ||
+			!hmac.Equal([]byte(r.URL.Query().Get("signature")), []byte(s.sign(key, expiresAt))) {
+			http.Error(w, "invalid or expired signature", http.StatusForbidden)
+			return
+		}
+
+		info, err := s.Stat(r.Context(), key)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		path, _ := s.path(key)
+		f, err := os.Open(path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		w.Header().Set("Content-Type", info.ContentType)
+		http.ServeContent(w, r, "", info.Updated, f)
+	})
+}
@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocalStore(t *testing.T) (*LocalStore, *httptest.Server) {
+	t.Helper()
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	store, err := NewLocalStoreWithConfig(t.TempDir(), srv.URL+"/files", []byte("secret"))
+	if err != nil {
+		t.Fatalf("NewLocalStoreWithConfig returned error: %v", err)
+	}
+	mux.Handle("/files/", http.StripPrefix("/files", store.Handler()))
+	return store, srv
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "audio/42/1.mp3", strings.NewReader("narration"), "audio/mpeg"); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	rc, err := store.Get(ctx, "audio/42/1.mp3")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "narration" {
+		t.Errorf("Expected stored data, got %q (err: %v)", data, err)
+	}
+
+	info, err := store.Stat(ctx, "audio/42/1.mp3")
+	if err != nil {
+		t.Fatalf("Stat returned error: %v", err)
+	}
+	if info.Size != int64(len("narration")) || info.ContentType != "audio/mpeg" {
+		t.Errorf("Unexpected object info: %+v", info)
+	}
+
+	if err := store.Delete(ctx, "audio/42/1.mp3"); err != nil {
+		t.Fatalf("Delete returned error: %v", err)
+	}
+	if _, err := store.Get(ctx, "audio/42/1.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound after delete, got %v", err)
+	}
+	if err := store.Delete(ctx, "audio/42/1.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound deleting a missing object, got %v", err)
+	}
+}
+
+func TestLocalStoreRejectsEscapingKeys(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+
+	for _, key := range []string{"../secret", "images/../../secret", "/abs", "images//a.png", "images/.a.png.content-type"} {
+		if err := store.Put(context.Background(), key, strings.NewReader("x"), "text/plain"); err == nil {
+			t.Errorf("Expected Put to reject key %q", key)
+		}
+	}
+}
+
+func TestLocalStoreRejectsEmptyObjects(t *testing.T) {
+	store, _ := newTestLocalStore(t)
+
+	if err := store.Put(context.Background(), "audio/42/1.mp3", strings.NewReader(""), "audio/mpeg"); !errors.Is(err, ErrEmptyObject) {
+		t.Errorf("Expected ErrEmptyObject, got %v", err)
+	}
+	if _, err := store.Stat(context.Background(), "audio/42/1.mp3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected no object to be written, got %v", err)
+	}
+}
+
+func TestLocalStoreSignedURL(t *testing.T) {
+	store, srv := newTestLocalStore(t)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "images/42/scene 0.png", strings.NewReader("png"), "image/png"); err != nil {
+		t.Fatalf("Put returned error: %v", err)
+	}
+
+	signedURL, err := store.SignURL(ctx, "images/42/scene 0.png", time.Minute)
+	if err != nil {
+		t.Fatalf("SignURL returned error: %v", err)
+	}
+	if !strings.HasPrefix(signedURL, srv.URL+"/files/images/42/scene%200.png?") {
+		t.Errorf("Unexpected signed URL: %s", signedURL)
+	}
+
+	resp, err := http.Get(signedURL)
+	if err != nil {
+		t.Fatalf("GET signed URL failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "png" || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("Expected the image, got status %d, type %q, body %q", resp.StatusCode, resp.Header.Get("Content-Type"), body)
+	}
+
+	expired, _ := store.SignURL(ctx, "images/42/scene 0.png", -time.Minute)
+	tampered := strings.Replace(signedURL, "images/42", "images/43", 1)
+	for name, u := range map[string]string{"expired": expired, "tampered": tampered, "unsigned": srv.URL + "/files/images/42/scene%200.png"} {
+		resp, err := http.Get(u)
+		if err != nil {
+			t.Fatalf("GET %s URL failed: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Expected 403 for %s URL, got %d", name, resp.StatusCode)
+		}
+	}
+}
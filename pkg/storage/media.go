@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"time"
+)
+
+// Media uploads pipeline artifacts to a BlobStore under the worker's key layout.
+// Uploads stream their data and return the object key; URLs are signed when
+// the object is read.
+type Media struct {
+	store BlobStore
+}
+
+// NewMedia creates a Media uploader backed by store
+func NewMedia(store BlobStore) *Media {
+	return &Media{store: store}
+}
+
+// audioExtensions maps supported audio content types to file extensions
+var audioExtensions = map[string]string{
+	"audio/mpeg": "mp3",
+	"audio/wav":  "wav",
+}
+
+// UploadAudio uploads an audio file and returns its key
+func (m *Media) UploadAudio(ctx context.Context, r io.Reader, videoID int, contentType string) (string, error) {
+	ext, ok := audioExtensions[contentType]
+	if !ok {
+		return "", fmt.Errorf("unsupported audio content type %q", contentType)
+	}
+
+	timestamp := time.Now().Unix()
+	key := fmt.Sprintf("audio/%d/%d.%s", videoID, timestamp, ext)
+
+	if err := m.store.Put(ctx, key, r, contentType); err != nil {
+		return "", fmt.Errorf("failed to upload audio: %w", err)
+	}
+	return key, nil
+}
+
+// UploadImage uploads an image file and returns its key
+func (m *Media) UploadImage(ctx context.Context, r io.Reader, videoID int, sceneIndex int) (string, error) {
+	timestamp := time.Now().Unix()
+	key := fmt.Sprintf("images/%d/scene_%d_%d.png", videoID, sceneIndex, timestamp)
+
+	if err := m.store.Put(ctx, key, r, "image/png"); err != nil {
+		return "", fmt.Errorf("failed to upload image: %w", err)
+	}
+	return key, nil
+}
+
+// UploadVideo uploads a video file and returns its key
+func (m *Media) UploadVideo(ctx context.Context, r io.Reader, videoID int) (string, error) {
+	timestamp := time.Now().Unix()
+	key := fmt.Sprintf("videos/%d/%d.mp4", videoID, timestamp)
+
+	if err := m.store.Put(ctx, key, r, "video/mp4"); err != nil {
+		return "", fmt.Errorf("failed to upload video: %w", err)
+	}
+	return key, nil
+}
+
+// UploadFile is a generic method to upload any file, returning its key
+func (m *Media) UploadFile(ctx context.Context, r io.Reader, filePath string, contentType string) (string, error) {
+	key := path.Clean(filePath)
+
+	if err := m.store.Put(ctx, key, r, contentType); err != nil {
+		return "", fmt.Errorf("failed to upload file: %w", err)
+	}
+	return key, nil
+}
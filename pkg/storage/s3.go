@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// s3PartSize is the smallest part S3 accepts in a multipart upload. Uploads of
+// unknown length are buffered one part at a time, so this bounds their memory.
+const s3PartSize = 5 << 20
+
+func init() {
+	RegisterBackend("s3", func(ctx context.Context) (BlobStore, error) {
+		return NewS3Client(ctx)
+	})
+}
+
+// S3Client stores objects in an S3 bucket or an S3-compatible server such as MinIO
+type S3Client struct {
+	client    *s3.Client
+	uploader  *transfermanager.Client
+	presigner *s3.PresignClient
+	bucket    string
+}
+
+// NewS3Client creates a client for S3_BUCKET_NAME. Credentials and AWS_REGION
+// come from the standard AWS environment. S3_ENDPOINT points the client at an
+// S3-compatible server and switches to path-style addressing, which MinIO needs.
+func NewS3Client(ctx context.Context) (*S3Client, error) {
+	bucketName := os.Getenv("S3_BUCKET_NAME")
+	if bucketName == "" {
+		return nil, fmt.Errorf("S3_BUCKET_NAME environment variable not set")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	return NewS3ClientWithClient(client, bucketName), nil
+}
+
+// NewS3ClientWithClient creates a store for an explicit S3 client and bucket
+func NewS3ClientWithClient(client *s3.Client, bucket string) *S3Client {
+	return &S3Client{
+		client: client,
+		uploader: transfermanager.New(client, func(o *transfermanager.Options) {
+			o.PartSizeBytes = s3PartSize
+			o.MultipartUploadThreshold = s3PartSize
+			o.Concurrency = 1
+		}),
+		presigner: s3.NewPresignClient(client),
+		bucket:    bucket,
+	}
+}
+
+// Bucket returns the bucket objects are stored in
+func (c *S3Client) Bucket() string {
+	return c.bucket
+}
+
+// Put streams r to key. Objects larger than one part are sent as a multipart upload.
+func (c *S3Client) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	r, err := nonEmpty(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.uploader.UploadObject(ctx, &transfermanager.UploadObjectInput{
+		Bucket:      aws.String(c.bucket),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("UploadObject: %w", err)
+	}
+	return nil
+}
+
+// Get opens the object at key for reading
+func (c *S3Client) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GetObject: %w", err)
+	}
+	return out.Body, nil
+}
+
+// Delete removes the object at key. S3 doesn't report whether the object
+// existed, so deleting a missing key succeeds.
+func (c *S3Client) Delete(ctx context.Context, key string) error {
+	_, err := c.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("DeleteObject: %w", err)
+	}
+	return nil
+}
+
+// SignURL returns a presigned GET URL for key
+func (c *S3Client) SignURL(ctx context.Context, key string, expires time.Duration) (string, error) {
+	req, err := c.presigner.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	}, s3.WithPresignExpires(expires))
+	if err != nil {
+		return "", fmt.Errorf("PresignGetObject: %w", err)
+	}
+	return req.URL, nil
+}
+
+// Stat returns the size, content type and modification time of the object at key
+func (c *S3Client) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	out, err := c.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if isS3NotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("HeadObject: %w", err)
+	}
+
+	return &ObjectInfo{
+		Key:         key,
+		Size:        aws.ToInt64(out.ContentLength),
+		ContentType: aws.ToString(out.ContentType),
+		Updated:     aws.ToTime(out.LastModified),
+	}, nil
+}
+
+// isS3NotFound reports whether err means the object doesn't exist.
+// HeadObject has no body, so it only returns a generic NotFound error.
+func isS3NotFound(err error) bool {
+	var noSuchKey *types.NoSuchKey
+	var notFound *types.NotFound
+	return errors.As(err, &noSuchKey) || errors.As(err, &notFound)
+}